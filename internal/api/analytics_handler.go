@@ -0,0 +1,188 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+)
+
+// maxVolumePeriods bounds the number of periods a volume request can span.
+const maxVolumePeriods = 400
+
+// AnalyticsHandler handles the requests summing up the training of a user.
+type AnalyticsHandler struct {
+	analyticsStore store.AnalyticsStore
+	logger         *log.Logger
+}
+
+// NewAnalyticsHandler creates a new instance of AnalyticsHandler.
+func NewAnalyticsHandler(analyticsStore store.AnalyticsStore, logger *log.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsStore: analyticsStore,
+		logger:         logger,
+	}
+}
+
+// HandleGetVolume handles the GET request returning the training volume of
+// the current user per day, week or month. Without a range it covers the last
+// twelve periods. Tonnages are in the units of the request.
+func (ah *AnalyticsHandler) HandleGetVolume(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	filter := store.VolumeFilter{
+		UserID:  middleware.GetUser(r).ID,
+		Period:  qs.Get("period"),
+		GroupBy: qs.Get("group_by"),
+		Window:  4,
+	}
+	if filter.Period == "" {
+		filter.Period = "week"
+	}
+	if filter.GroupBy == "" {
+		filter.GroupBy = "total"
+	}
+
+	fieldErrors := map[string]string{}
+	if !slices.Contains(store.VolumePeriods, filter.Period) {
+		fieldErrors["period"] = "must be one of " + strings.Join(store.VolumePeriods, ", ")
+	}
+	if !slices.Contains(store.VolumeGroupings, filter.GroupBy) {
+		fieldErrors["group_by"] = "must be one of " + strings.Join(store.VolumeGroupings, ", ")
+	}
+	window, err := utils.ReadIntQuery(r, "window")
+	if err != nil {
+		fieldErrors["window"] = err.Error()
+	} else if window != nil {
+		if *window < 1 || *window > 52 {
+			fieldErrors["window"] = "must be between 1 and 52"
+		}
+		filter.Window = *window
+	}
+	from, err := utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		fieldErrors["from"] = err.Error()
+	}
+	to, err := utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		fieldErrors["to"] = err.Error()
+	}
+	u := readUnitsQuery(r, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+
+	periodLength := volumePeriodLength(filter.Period)
+	filter.To = time.Now()
+	if to != nil {
+		filter.To = *to
+	}
+	filter.From = filter.To.Add(-12 * periodLength)
+	if from != nil {
+		filter.From = *from
+	}
+	if !filter.From.Before(filter.To) {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", map[string]string{"from": "must be before to"})
+		return
+	}
+	if filter.To.Sub(filter.From) > maxVolumePeriods*periodLength {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", map[string]string{"from": "the range must not cover more than 400 periods"})
+		return
+	}
+
+	series, err := ah.analyticsStore.GetVolume(filter)
+	if err != nil {
+		storeErrorResponse(ah.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"volume": utils.Envelope{
+			"period":   filter.Period,
+			"group_by": filter.GroupBy,
+			"from":     filter.From,
+			"to":       filter.To,
+			"window":   filter.Window,
+			"series":   volumeInUnits(series, u),
+		},
+		"units": u,
+	})
+}
+
+// maxLoadDays bounds the number of days a load request can span.
+const maxLoadDays = 366
+
+// HandleGetLoad handles the GET request returning the daily training load of
+// the current user with its acute and chronic loads, acute:chronic workload
+// ratio, monotony and strain. Both ends of the range are days and included,
+// without a range it covers the last eight weeks.
+func (ah *AnalyticsHandler) HandleGetLoad(w http.ResponseWriter, r *http.Request) {
+	fieldErrors := map[string]string{}
+	from, err := utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		fieldErrors["from"] = err.Error()
+	}
+	to, err := utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		fieldErrors["to"] = err.Error()
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if to != nil {
+		end = to.UTC().Truncate(24 * time.Hour)
+	}
+	start := end.AddDate(0, 0, -55)
+	if from != nil {
+		start = from.UTC().Truncate(24 * time.Hour)
+	}
+	if start.After(end) {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", map[string]string{"from": "must not be after to"})
+		return
+	}
+	if end.Sub(start) >= maxLoadDays*24*time.Hour {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", map[string]string{"from": "the range must not cover more than 366 days"})
+		return
+	}
+
+	// the chronic load of the first day looks back 27 more days
+	sessions, err := ah.analyticsStore.GetSessionLoads(middleware.GetUser(r).ID, start.AddDate(0, 0, -27), end.AddDate(0, 0, 1))
+	if err != nil {
+		storeErrorResponse(ah.logger, w, r, err)
+		return
+	}
+	inRange := []*store.SessionLoad{}
+	for _, session := range sessions {
+		if !session.CreatedAt.Before(start) {
+			inRange = append(inRange, session)
+		}
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"load": utils.Envelope{
+			"from":     start,
+			"to":       end,
+			"sessions": inRange,
+			"series":   store.BuildLoadSeries(sessions, start, end),
+		},
+	})
+}
+
+// volumePeriodLength is the shortest length of a period, used to bound the
+// range of a request.
+func volumePeriodLength(period string) time.Duration {
+	switch period {
+	case "day":
+		return 24 * time.Hour
+	case "week":
+		return 7 * 24 * time.Hour
+	default:
+		return 28 * 24 * time.Hour
+	}
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// BodyHandler handles the requests on the body measurements and body goals of
+// the current user.
+type BodyHandler struct {
+	bodyStore store.BodyMeasurementStore
+	logger    *log.Logger
+}
+
+// NewBodyHandler creates a new instance of BodyHandler.
+func NewBodyHandler(bodyStore store.BodyMeasurementStore, logger *log.Logger) *BodyHandler {
+	return &BodyHandler{
+		bodyStore: bodyStore,
+		logger:    logger,
+	}
+}
+
+type measurementRequest struct {
+	MeasuredAt     *time.Time `json:"measured_at"`
+	BodyWeight     *float64   `json:"body_weight"`
+	BodyFatPercent *float64   `json:"body_fat_percent"`
+	Waist          *float64   `json:"waist"`
+	Hips           *float64   `json:"hips"`
+	Chest          *float64   `json:"chest"`
+	Neck           *float64   `json:"neck"`
+	Arm            *float64   `json:"arm"`
+	Thigh          *float64   `json:"thigh"`
+	Notes          *string    `json:"notes"`
+}
+
+// apply replaces the metrics and notes of measurement with those of the
+// request, metrics left out are cleared. The time is only replaced when
+// given. The body weight is given in u.
+func (req *measurementRequest) apply(measurement *store.BodyMeasurement, u units.Units) {
+	if req.MeasuredAt != nil {
+		measurement.MeasuredAt = *req.MeasuredAt
+	}
+	measurement.BodyWeight = weightFromUnits(req.BodyWeight, measurement.BodyWeight, u)
+	measurement.BodyFatPercent = req.BodyFatPercent
+	measurement.Waist = req.Waist
+	measurement.Hips = req.Hips
+	measurement.Chest = req.Chest
+	measurement.Neck = req.Neck
+	measurement.Arm = req.Arm
+	measurement.Thigh = req.Thigh
+	measurement.Notes = ""
+	if req.Notes != nil {
+		measurement.Notes = strings.TrimSpace(*req.Notes)
+	}
+}
+
+// HandleListMeasurements handles the GET request listing the body
+// measurements of the current user from from up to, but not including, to,
+// oldest first. Every measurement carries the trend of each metric up to it,
+// smoothed with the factor alpha over the whole history. Body weights are shown
+// in the units of the request.
+func (bh *BodyHandler) HandleListMeasurements(w http.ResponseWriter, r *http.Request) {
+	fieldErrors := map[string]string{}
+	u := readUnitsQuery(r, fieldErrors)
+	from, err := utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		fieldErrors["from"] = err.Error()
+	}
+	to, err := utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		fieldErrors["to"] = err.Error()
+	}
+	alpha, err := utils.ReadFloatQuery(r, "alpha")
+	if err != nil {
+		fieldErrors["alpha"] = err.Error()
+	} else if alpha != nil && (*alpha <= 0 || *alpha > 1) {
+		fieldErrors["alpha"] = "must be greater than 0 and at most 1"
+	}
+	if from != nil && to != nil && from.After(*to) {
+		fieldErrors["from"] = "must not be after to"
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+	if alpha == nil {
+		defaultAlpha := store.DefaultTrendAlpha
+		alpha = &defaultAlpha
+	}
+
+	// the trend at from depends on the measurements before it
+	measurements, err := bh.bodyStore.ListMeasurements(middleware.GetUser(r).ID, to)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	store.SmoothTrends(measurements, *alpha)
+	inRange := []*store.BodyMeasurement{}
+	for _, measurement := range measurements {
+		if from == nil || !measurement.MeasuredAt.Before(*from) {
+			inRange = append(inRange, measurement)
+		}
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurements": measurementsInUnits(inRange, u), "units": u})
+}
+
+// HandleGetMeasurementByID handles the GET request to retrieve one of the
+// current user's body measurements by its ID.
+func (bh *BodyHandler) HandleGetMeasurementByID(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	measurement, ok := bh.readOwnMeasurement(w, r)
+	if !ok {
+		return
+	}
+	if writeNotModified(w, r, utils.ETagIn(measurement.Version, u.Weight)) {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": measurementInUnits(measurement, u), "units": u})
+}
+
+// HandleCreateMeasurement handles the POST request logging a body measurement
+// of the current user, taken now unless measured_at says otherwise.
+func (bh *BodyHandler) HandleCreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	var req measurementRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	measurement := &store.BodyMeasurement{UserID: middleware.GetUser(r).ID, MeasuredAt: time.Now()}
+	req.apply(measurement, u)
+
+	v := validator.New()
+	store.ValidateBodyMeasurement(v, measurement)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = bh.bodyStore.CreateMeasurement(measurement)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(measurement.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"measurement": measurementInUnits(measurement, u), "units": u})
+}
+
+// HandleUpdateMeasurement handles the PUT request replacing one of the
+// current user's body measurements.
+func (bh *BodyHandler) HandleUpdateMeasurement(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	measurement, ok := bh.readOwnMeasurement(w, r)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(measurement.Version, u.Weight)) {
+		return
+	}
+
+	var req measurementRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.apply(measurement, u)
+
+	v := validator.New()
+	store.ValidateBodyMeasurement(v, measurement)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = bh.bodyStore.UpdateMeasurement(measurement)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(measurement.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": measurementInUnits(measurement, u), "units": u})
+}
+
+// HandleDeleteMeasurement handles the DELETE request removing one of the
+// current user's body measurements.
+func (bh *BodyHandler) HandleDeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	measurement, ok := bh.readOwnMeasurement(w, r)
+	if !ok {
+		return
+	}
+	err := bh.bodyStore.DeleteMeasurement(int64(measurement.ID))
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// HandleListGoals handles the GET request listing the body goals of the
+// current user with their progress, a body weight goal in the units of the
+// request.
+func (bh *BodyHandler) HandleListGoals(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	userID := middleware.GetUser(r).ID
+	goals, err := bh.bodyStore.ListGoals(userID)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	err = bh.trackGoals(userID, goals...)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goals": goalsInUnits(goals, u), "units": u})
+}
+
+// HandleSetGoal handles the PUT request setting the goal of the current user
+// on the metric named in the URL, a body weight target is given in the units
+// of the request.
+func (bh *BodyHandler) HandleSetGoal(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Target     *float64 `json:"target"`
+		TargetDate *string  `json:"target_date"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	goal := &store.BodyGoal{Metric: chi.URLParam(r, "metric")}
+	v := validator.New()
+	if req.Target == nil {
+		v.AddError("target", "must be provided")
+	} else {
+		goal.Target = *req.Target
+		if goal.Metric == store.MetricBodyWeight {
+			goal.Target = u.ToKilograms(goal.Target)
+		}
+		store.ValidateBodyGoal(v, goal)
+	}
+	if req.TargetDate != nil {
+		targetDate, err := time.Parse(time.DateOnly, *req.TargetDate)
+		v.Check(err == nil, "target_date", "must be a YYYY-MM-DD date")
+		goal.TargetDate = &targetDate
+	}
+	if !slices.Contains(store.BodyMetrics, goal.Metric) {
+		storeErrorResponse(bh.logger, w, r, store.ErrNotFound)
+		return
+	}
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID := middleware.GetUser(r).ID
+	err = bh.bodyStore.SetGoal(userID, goal)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	err = bh.trackGoals(userID, goal)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goal": goalInUnits(goal, u), "units": u})
+}
+
+// HandleDeleteGoal handles the DELETE request removing the goal of the
+// current user on the metric named in the URL.
+func (bh *BodyHandler) HandleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	err := bh.bodyStore.DeleteGoal(middleware.GetUser(r).ID, chi.URLParam(r, "metric"))
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// trackGoals fills in the progress of goals from the trends of every
+// measurement of the user.
+func (bh *BodyHandler) trackGoals(userID int, goals ...*store.BodyGoal) error {
+	if len(goals) == 0 {
+		return nil
+	}
+	measurements, err := bh.bodyStore.ListMeasurements(userID, nil)
+	if err != nil {
+		return err
+	}
+	store.SmoothTrends(measurements, store.DefaultTrendAlpha)
+	for _, goal := range goals {
+		goal.Track(measurements)
+	}
+	return nil
+}
+
+// readOwnMeasurement loads the body measurement named in the URL when it
+// belongs to the current user, and writes the error response otherwise.
+// Measurements of other users answer 404.
+func (bh *BodyHandler) readOwnMeasurement(w http.ResponseWriter, r *http.Request) (*store.BodyMeasurement, bool) {
+	measurementID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid measurement ID")
+		return nil, false
+	}
+	measurement, err := bh.bodyStore.GetMeasurementByID(measurementID)
+	if err != nil {
+		storeErrorResponse(bh.logger, w, r, err)
+		return nil, false
+	}
+	if measurement.UserID != middleware.GetUser(r).ID {
+		storeErrorResponse(bh.logger, w, r, store.ErrNotFound)
+		return nil, false
+	}
+	return measurement, true
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/makhammatovb/femProject/internal/utils"
+)
+
+// writeNotModified sets the ETag of a record and answers 304 when the client
+// already holds that version. It reports whether the response was written.
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") != "" && utils.IfNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch requires the If-Match header to name the current ETag of a
+// record, answering 428 when it is missing and 412 when it is stale.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if r.Header.Get("If-Match") == "" {
+		errorResponse(w, r, http.StatusPreconditionRequired, "The If-Match header with the current ETag of the resource is required")
+		return false
+	}
+	if !utils.IfMatch(r, etag) {
+		preconditionFailedResponse(w, r)
+		return false
+	}
+	return true
+}
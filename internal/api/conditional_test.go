@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalRequestsInUnits(t *testing.T) {
+	kg := utils.ETagIn(3, units.Of(units.Metric).Weight)
+	lb := utils.ETagIn(3, units.Of(units.Imperial).Weight)
+	assert.Equal(t, `"3-kg"`, kg)
+	assert.Equal(t, `"3-lb"`, lb)
+
+	// a version fetched in lb is not current for a client reading kg
+	r := httptest.NewRequest(http.MethodGet, "/workouts/1", nil)
+	r.Header.Set("If-None-Match", lb)
+	rr := httptest.NewRecorder()
+	assert.False(t, writeNotModified(rr, r, kg))
+	assert.Equal(t, kg, rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	assert.True(t, writeNotModified(rr, r, lb))
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	r = httptest.NewRequest(http.MethodPut, "/workouts/1", nil)
+	r.Header.Set("If-Match", lb)
+	rr = httptest.NewRecorder()
+	assert.False(t, checkIfMatch(rr, r, kg))
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.True(t, checkIfMatch(httptest.NewRecorder(), r, lb))
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+)
+
+// errorResponse writes an application/problem+json body with the given status
+// and detail.
+func errorResponse(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	utils.WriteProblem(w, r, status, detail, nil)
+}
+
+// failedValidationResponse answers 422 listing every invalid field at once.
+func failedValidationResponse(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) {
+	utils.WriteProblem(w, r, http.StatusUnprocessableEntity, "One or more fields are invalid", fieldErrors)
+}
+
+// preconditionFailedResponse answers 412 when the record changed since the
+// client fetched it.
+func preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	errorResponse(w, r, http.StatusPreconditionFailed, "The resource has been modified since it was last fetched, fetch it again and retry")
+}
+
+// serverErrorResponse logs the unexpected error and answers 500 without
+// leaking its details to the client.
+func serverErrorResponse(logger *log.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	logger.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	errorResponse(w, r, http.StatusInternalServerError, "The server encountered a problem and could not process your request")
+}
+
+// storeErrorResponse turns an error returned by one of the stores into the
+// matching HTTP status so every handler answers the same way.
+func storeErrorResponse(logger *log.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	var conflictErr *store.ConflictError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		errorResponse(w, r, http.StatusNotFound, "The requested resource could not be found")
+	case errors.As(err, &conflictErr):
+		message := "is already taken"
+		if conflictErr.Reason != "" {
+			message = conflictErr.Reason
+		}
+		utils.WriteProblem(w, r, http.StatusConflict, conflictErr.Error(), map[string]string{conflictErr.Field: message})
+	case errors.Is(err, store.ErrEditConflict):
+		errorResponse(w, r, http.StatusConflict, "Unable to update the record due to an edit conflict, please try again")
+	case errors.Is(err, store.ErrInvalidData):
+		errorResponse(w, r, http.StatusUnprocessableEntity, "The record is invalid")
+	default:
+		serverErrorResponse(logger, w, r, err)
+	}
+}
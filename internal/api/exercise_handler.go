@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// ExerciseHandler handles the requests on the exercise catalog.
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	logger        *log.Logger
+}
+
+// NewExerciseHandler creates a new instance of ExerciseHandler.
+func NewExerciseHandler(exerciseStore store.ExerciseStore, logger *log.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+type exerciseRequest struct {
+	Name             *string  `json:"name"`
+	Aliases          []string `json:"aliases"`
+	PrimaryMuscles   []string `json:"primary_muscles"`
+	SecondaryMuscles []string `json:"secondary_muscles"`
+	Equipment        *string  `json:"equipment"`
+	MovementPattern  *string  `json:"movement_pattern"`
+	Type             *string  `json:"type"`
+}
+
+// apply copies the fields present in the request onto exercise.
+func (req *exerciseRequest) apply(exercise *store.Exercise) {
+	if req.Name != nil {
+		exercise.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Aliases != nil {
+		exercise.Aliases = req.Aliases
+	}
+	if req.PrimaryMuscles != nil {
+		exercise.PrimaryMuscles = req.PrimaryMuscles
+	}
+	if req.SecondaryMuscles != nil {
+		exercise.SecondaryMuscles = req.SecondaryMuscles
+	}
+	if req.Equipment != nil {
+		exercise.Equipment = *req.Equipment
+	}
+	if req.MovementPattern != nil {
+		exercise.MovementPattern = *req.MovementPattern
+	}
+	if req.Type != nil {
+		exercise.Type = *req.Type
+	}
+}
+
+// HandleSearchExercises handles the GET request searching the built-in
+// exercises and the current user's own exercises.
+func (eh *ExerciseHandler) HandleSearchExercises(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	filter := store.ExerciseFilter{
+		UserID:          middleware.GetUser(r).ID,
+		Query:           strings.TrimSpace(qs.Get("q")),
+		Muscle:          qs.Get("muscle"),
+		Equipment:       qs.Get("equipment"),
+		MovementPattern: qs.Get("movement_pattern"),
+		Type:            qs.Get("type"),
+		Limit:           25,
+	}
+
+	fieldErrors := map[string]string{}
+	limit, err := utils.ReadIntQuery(r, "limit")
+	if err != nil {
+		fieldErrors["limit"] = err.Error()
+	} else if limit != nil {
+		if *limit < 1 || *limit > 100 {
+			fieldErrors["limit"] = "must be between 1 and 100"
+		}
+		filter.Limit = *limit
+	}
+	if filter.Muscle != "" && !slices.Contains(store.MuscleGroups, filter.Muscle) {
+		fieldErrors["muscle"] = "must be one of " + strings.Join(store.MuscleGroups, ", ")
+	}
+	if filter.Equipment != "" && !slices.Contains(store.Equipment, filter.Equipment) {
+		fieldErrors["equipment"] = "must be one of " + strings.Join(store.Equipment, ", ")
+	}
+	if filter.MovementPattern != "" && !slices.Contains(store.MovementPatterns, filter.MovementPattern) {
+		fieldErrors["movement_pattern"] = "must be one of " + strings.Join(store.MovementPatterns, ", ")
+	}
+	if filter.Type != "" && filter.Type != store.ExerciseTypeReps && filter.Type != store.ExerciseTypeTimed {
+		fieldErrors["type"] = "must be reps or timed"
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+
+	exercises, err := eh.exerciseStore.SearchExercises(filter)
+	if err != nil {
+		storeErrorResponse(eh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+// HandleGetExerciseByID handles the GET request to retrieve an exercise by its
+// ID. Exercises of other users answer 404.
+func (eh *ExerciseHandler) HandleGetExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exercise, ok := eh.readVisibleExercise(w, r)
+	if !ok {
+		return
+	}
+	if writeNotModified(w, r, utils.ETag(exercise.Version)) {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+// HandleCreateExercise handles the POST request adding an exercise of the
+// current user to the catalog.
+func (eh *ExerciseHandler) HandleCreateExercise(w http.ResponseWriter, r *http.Request) {
+	var req exerciseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	exercise := &store.Exercise{UserID: &currentUser.ID}
+	req.apply(exercise)
+
+	v := validator.New()
+	store.ValidateExercise(v, exercise)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = eh.exerciseStore.CreateExercise(exercise)
+	if err != nil {
+		storeErrorResponse(eh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETag(exercise.Version))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"exercise": exercise})
+}
+
+// HandleUpdateExercise handles the PUT request changing one of the current
+// user's exercises, built-in exercises cannot be changed.
+func (eh *ExerciseHandler) HandleUpdateExercise(w http.ResponseWriter, r *http.Request) {
+	exercise, ok := eh.readOwnExercise(w, r)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETag(exercise.Version)) {
+		return
+	}
+
+	var req exerciseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.apply(exercise)
+
+	v := validator.New()
+	store.ValidateExercise(v, exercise)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = eh.exerciseStore.UpdateExercise(exercise)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(eh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETag(exercise.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+// HandleDeleteExercise handles the DELETE request removing one of the current
+// user's exercises.
+func (eh *ExerciseHandler) HandleDeleteExercise(w http.ResponseWriter, r *http.Request) {
+	exercise, ok := eh.readOwnExercise(w, r)
+	if !ok {
+		return
+	}
+	err := eh.exerciseStore.DeleteExercise(int64(exercise.ID))
+	if err != nil {
+		storeErrorResponse(eh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// readVisibleExercise loads the exercise named in the URL when it is built in
+// or belongs to the current user, and writes the error response otherwise.
+func (eh *ExerciseHandler) readVisibleExercise(w http.ResponseWriter, r *http.Request) (*store.Exercise, bool) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid exercise ID")
+		return nil, false
+	}
+	exercise, err := eh.exerciseStore.GetExerciseByID(exerciseID)
+	if err != nil {
+		storeErrorResponse(eh.logger, w, r, err)
+		return nil, false
+	}
+	if !exercise.IsBuiltIn() && *exercise.UserID != middleware.GetUser(r).ID {
+		storeErrorResponse(eh.logger, w, r, store.ErrNotFound)
+		return nil, false
+	}
+	return exercise, true
+}
+
+// readOwnExercise is readVisibleExercise for changes, which built-in
+// exercises do not allow.
+func (eh *ExerciseHandler) readOwnExercise(w http.ResponseWriter, r *http.Request) (*store.Exercise, bool) {
+	exercise, ok := eh.readVisibleExercise(w, r)
+	if !ok {
+		return nil, false
+	}
+	if exercise.IsBuiltIn() {
+		errorResponse(w, r, http.StatusForbidden, "Built-in exercises cannot be changed")
+		return nil, false
+	}
+	return exercise, true
+}
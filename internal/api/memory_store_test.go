@@ -0,0 +1,329 @@
+package api
+
+import (
+	"bytes"
+	"slices"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/tokens"
+)
+
+// memoryUserStore is an in-memory store.UserStore looking tokens up in a
+// memoryTokenStore.
+type memoryUserStore struct {
+	store.UserStore
+	users  []*store.User
+	tokens *memoryTokenStore
+}
+
+func newMemoryUserStore(tokens *memoryTokenStore) *memoryUserStore {
+	return &memoryUserStore{tokens: tokens}
+}
+
+func (m *memoryUserStore) CreateUser(user *store.User) error {
+	user.ID = len(m.users) + 1
+	user.Version = 1
+	copied := *user
+	m.users = append(m.users, &copied)
+	return nil
+}
+
+func (m *memoryUserStore) GetUserByID(id int64) (*store.User, error) {
+	for _, user := range m.users {
+		if int64(user.ID) == id {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (m *memoryUserStore) GetUserByEmail(email string) (*store.User, error) {
+	for _, user := range m.users {
+		if user.Email == email {
+			return m.GetUserByID(int64(user.ID))
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (m *memoryUserStore) UpdateUser(user *store.User) error {
+	for i, stored := range m.users {
+		if stored.ID == user.ID {
+			if stored.Version != user.Version {
+				return store.ErrEditConflict
+			}
+			user.Version++
+			copied := *user
+			m.users[i] = &copied
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (m *memoryUserStore) GetUserToken(scope, tokenPlainText string) (*store.User, error) {
+	token := m.tokens.find(scope, tokenPlainText)
+	if token == nil || !token.Expiry.After(time.Now()) {
+		return nil, store.ErrNotFound
+	}
+	return m.GetUserByID(token.UserID)
+}
+
+// memoryTokenStore is an in-memory store.TokenStore following the rules of
+// the Postgres one, so handlers can be tested without a database.
+type memoryTokenStore struct {
+	store.TokenStore
+	tokens []*memoryToken
+}
+
+type memoryToken struct {
+	*tokens.Token
+	used bool
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{}
+}
+
+func (m *memoryTokenStore) Insert(token *tokens.Token) error {
+	m.tokens = append(m.tokens, &memoryToken{Token: token})
+	return nil
+}
+
+func (m *memoryTokenStore) CreateNewToken(userID int64, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	return token, m.Insert(token)
+}
+
+func (m *memoryTokenStore) DeleteAllTokenForUser(userID int64, scope string) error {
+	m.delete(func(token *memoryToken) bool {
+		return token.UserID == userID && token.Scope == scope
+	})
+	return nil
+}
+
+func (m *memoryTokenStore) CreateSession(userID int64, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	family, err := tokens.GenerateFamily()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.insertPair(userID, userAgent, family)
+}
+
+func (m *memoryTokenStore) RotateRefreshToken(plainText, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	token := m.find(tokens.ScopeRefresh, plainText)
+	if token == nil {
+		return nil, nil, store.ErrInvalidToken
+	}
+	if token.used {
+		m.delete(func(other *memoryToken) bool { return other.Family == token.Family })
+		return nil, nil, store.ErrTokenReused
+	}
+	if token.Expiry.Before(time.Now()) {
+		return nil, nil, store.ErrInvalidToken
+	}
+
+	token.used = true
+	m.delete(func(other *memoryToken) bool {
+		return other.Family == token.Family && other.Scope == tokens.ScopeAuthentication
+	})
+	return m.insertPair(token.UserID, userAgent, token.Family)
+}
+
+// find returns the token of scope with the given plain text, expired or not.
+func (m *memoryTokenStore) find(scope, plainText string) *memoryToken {
+	hash := tokens.Hash(plainText)
+	for _, token := range m.tokens {
+		if token.Scope == scope && bytes.Equal(token.Hash, hash) {
+			return token
+		}
+	}
+	return nil
+}
+
+func (m *memoryTokenStore) delete(match func(*memoryToken) bool) {
+	kept := m.tokens[:0]
+	for _, token := range m.tokens {
+		if !match(token) {
+			kept = append(kept, token)
+		}
+	}
+	m.tokens = kept
+}
+
+func (m *memoryTokenStore) insertPair(userID int64, userAgent, family string) (*tokens.Token, *tokens.Token, error) {
+	access, err := tokens.GenerateToken(userID, tokens.AuthenticationTTL, tokens.ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh, err := tokens.GenerateToken(userID, tokens.RefreshTTL, tokens.ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, token := range []*tokens.Token{access, refresh} {
+		token.UserAgent = userAgent
+		token.Family = family
+		m.Insert(token)
+	}
+	return access, refresh, nil
+}
+
+// memoryWorkoutStore is an in-memory store.WorkoutStore holding workouts by
+// ID, versioned like the Postgres one.
+type memoryWorkoutStore struct {
+	store.WorkoutStore
+	workouts map[int64]*store.Workout
+}
+
+func newMemoryWorkoutStore(workouts ...*store.Workout) *memoryWorkoutStore {
+	m := &memoryWorkoutStore{workouts: map[int64]*store.Workout{}}
+	for _, workout := range workouts {
+		workout.Version = 1
+		m.workouts[int64(workout.ID)] = workout
+	}
+	return m
+}
+
+func (m *memoryWorkoutStore) GetWorkoutByID(id int64) (*store.Workout, error) {
+	workout, ok := m.workouts[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *workout
+	copied.Entries = slices.Clone(workout.Entries)
+	return &copied, nil
+}
+
+func (m *memoryWorkoutStore) GetWorkoutOwner(id int64) (int, error) {
+	workout, ok := m.workouts[id]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	return workout.UserID, nil
+}
+
+func (m *memoryWorkoutStore) AddWorkoutEntry(workoutID int64, version int, entry *store.WorkoutEntry) (int, error) {
+	return m.change(workoutID, version, func(workout *store.Workout) error {
+		entry.ID = len(workout.Entries) + 1
+		entry.OrderIndex = len(workout.Entries) + 1
+		workout.Entries = append(workout.Entries, *entry)
+		return nil
+	})
+}
+
+func (m *memoryWorkoutStore) DeleteWorkoutEntry(workoutID int64, version int, entryID int64) (int, error) {
+	return m.change(workoutID, version, func(workout *store.Workout) error {
+		for i, entry := range workout.Entries {
+			if int64(entry.ID) == entryID {
+				workout.Entries = slices.Delete(workout.Entries, i, i+1)
+				return nil
+			}
+		}
+		return store.ErrNotFound
+	})
+}
+
+// change applies fn to the workout when it is still at version and bumps the
+// version, like the entry changes of the Postgres store.
+func (m *memoryWorkoutStore) change(workoutID int64, version int, fn func(*store.Workout) error) (int, error) {
+	workout, ok := m.workouts[workoutID]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	if workout.Version != version {
+		return 0, store.ErrEditConflict
+	}
+	err := fn(workout)
+	if err != nil {
+		return 0, err
+	}
+	workout.Version++
+	return workout.Version, nil
+}
+
+func (m *memoryWorkoutStore) CreateWorkout(workout *store.Workout) (*store.Workout, error) {
+	workout.ID = len(m.workouts) + 1
+	workout.Version = 1
+	for i := range workout.Entries {
+		workout.Entries[i].ID = i + 1
+	}
+	m.workouts[int64(workout.ID)] = workout
+	return m.GetWorkoutByID(int64(workout.ID))
+}
+
+// memoryTemplateStore is an in-memory store.TemplateStore holding templates
+// by ID.
+type memoryTemplateStore struct {
+	store.TemplateStore
+	templates map[int64]*store.WorkoutTemplate
+}
+
+func newMemoryTemplateStore(templates ...*store.WorkoutTemplate) *memoryTemplateStore {
+	m := &memoryTemplateStore{templates: map[int64]*store.WorkoutTemplate{}}
+	for _, template := range templates {
+		template.Version = 1
+		m.templates[int64(template.ID)] = template
+	}
+	return m
+}
+
+func (m *memoryTemplateStore) GetTemplateByID(id int64) (*store.WorkoutTemplate, error) {
+	template, ok := m.templates[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *template
+	copied.Entries = slices.Clone(template.Entries)
+	return &copied, nil
+}
+
+func (m *memoryTemplateStore) CreateTemplate(template *store.WorkoutTemplate) error {
+	template.ID = len(m.templates) + 1
+	template.Version = 1
+	copied := *template
+	copied.Entries = slices.Clone(template.Entries)
+	m.templates[int64(template.ID)] = &copied
+	return nil
+}
+
+func (m *memoryTemplateStore) UpdateTemplate(template *store.WorkoutTemplate) error {
+	stored, ok := m.templates[int64(template.ID)]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if stored.Version != template.Version {
+		return store.ErrEditConflict
+	}
+	template.Version++
+	copied := *template
+	copied.Entries = slices.Clone(template.Entries)
+	m.templates[int64(template.ID)] = &copied
+	return nil
+}
+
+// memorySuggestionStore is an in-memory store.SuggestionStore holding the
+// history of exercises by name.
+type memorySuggestionStore map[string]*store.ExerciseHistory
+
+func (m memorySuggestionStore) GetExerciseHistory(userID int, exerciseID *int, name string) (*store.ExerciseHistory, error) {
+	if history, ok := m[name]; ok {
+		return history, nil
+	}
+	return &store.ExerciseHistory{ExerciseName: name}, nil
+}
+
+// memoryScheduleStore is an in-memory store.ScheduleStore listing every
+// planned workout it holds, whatever the filter.
+type memoryScheduleStore struct {
+	store.ScheduleStore
+	schedule []*store.PlannedWorkout
+}
+
+func (m *memoryScheduleStore) ListSchedule(filter store.ScheduleFilter) ([]*store.PlannedWorkout, error) {
+	return m.schedule, nil
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/makhammatovb/femProject/internal/mailer"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/tokens"
+	"github.com/makhammatovb/femProject/internal/utils"
+)
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// PasswordResetHandler struct to handle account recovery requests
+type PasswordResetHandler struct {
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	logger     *log.Logger
+}
+
+// NewPasswordResetHandler creates a new instance of PasswordResetHandler.
+func NewPasswordResetHandler(userStore store.UserStore, tokenStore store.TokenStore, mailer mailer.Mailer, logger *log.Logger) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     mailer,
+		logger:     logger,
+	}
+}
+
+// HandleRequestPasswordReset emails a single-use password reset token to the
+// account registered with the given address. The response is the same whether
+// or not the account exists so it cannot be used to probe for emails.
+func (ph *PasswordResetHandler) HandleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req requestPasswordResetRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Email == "" {
+		ph.logger.Println("error while decoding password reset request:", err)
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	response := utils.Envelope{"message": "If an account with that email exists, password reset instructions have been sent"}
+
+	user, err := ph.userStore.GetUserByEmail(req.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteJSON(w, http.StatusAccepted, response)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+
+	// only the most recently requested reset token stays valid
+	err = ph.tokenStore.DeleteAllTokenForUser(int64(user.ID), tokens.ScopePasswordReset)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	token, err := ph.tokenStore.CreateNewToken(int64(user.ID), tokens.PasswordResetTTL, tokens.ScopePasswordReset)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+
+	err = ph.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSend a PUT request to /password-reset with the token below and your new password to reset it:\n\n%s\n\nThe token expires in %s. If you did not ask for a reset you can ignore this email.\n",
+			user.Username, token.PlainText, tokens.PasswordResetTTL),
+	})
+	if err != nil {
+		serverErrorResponse(ph.logger, w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, response)
+}
+
+// HandleResetPassword consumes a password reset token, sets the new password
+// and logs the user out everywhere.
+func (ph *PasswordResetHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" || req.Password == "" {
+		ph.logger.Println("error while decoding password reset:", err)
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := ph.userStore.GetUserToken(tokens.ScopePasswordReset, req.Token)
+	if errors.Is(err, store.ErrNotFound) {
+		errorResponse(w, r, http.StatusUnprocessableEntity, "Invalid or expired password reset token")
+		return
+	}
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+
+	err = user.PasswordHash.Set(req.Password)
+	if err != nil {
+		serverErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	err = ph.userStore.UpdateUser(user)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+
+	for _, scope := range []string{tokens.ScopePasswordReset, tokens.ScopeAuthentication, tokens.ScopeRefresh} {
+		err = ph.tokenStore.DeleteAllTokenForUser(int64(user.ID), scope)
+		if err != nil {
+			storeErrorResponse(ph.logger, w, r, err)
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Your password was reset successfully"})
+}
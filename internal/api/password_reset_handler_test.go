@@ -0,0 +1,130 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/mailer"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type passwordResetTest struct {
+	handler    *PasswordResetHandler
+	userStore  *memoryUserStore
+	tokenStore *memoryTokenStore
+	mailer     *mailer.MemoryMailer
+	user       *store.User
+}
+
+func newPasswordResetTest(t *testing.T) *passwordResetTest {
+	t.Helper()
+	tokenStore := newMemoryTokenStore()
+	userStore := newMemoryUserStore(tokenStore)
+	memoryMailer := mailer.NewMemoryMailer()
+
+	user := &store.User{Username: "tester", Email: "tester@example.com", Activated: true}
+	require.NoError(t, user.PasswordHash.Set("old-password"))
+	require.NoError(t, userStore.CreateUser(user))
+
+	return &passwordResetTest{
+		handler:    NewPasswordResetHandler(userStore, tokenStore, memoryMailer, log.New(io.Discard, "", 0)),
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     memoryMailer,
+		user:       user,
+	}
+}
+
+// requestReset asks for a reset of email and returns the token of the email
+// sent, if any.
+func (pt *passwordResetTest) requestReset(t *testing.T, email string) string {
+	t.Helper()
+	sent := len(pt.mailer.Messages())
+	rr := httptest.NewRecorder()
+	pt.handler.HandleRequestPasswordReset(rr, httptest.NewRequest(http.MethodPost, "/password-reset", strings.NewReader(`{"email": "`+email+`"}`)))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	messages := pt.mailer.Messages()
+	if len(messages) == sent {
+		return ""
+	}
+	// the token is the paragraph following the instructions
+	paragraphs := strings.Split(messages[len(messages)-1].Body, "\n\n")
+	require.GreaterOrEqual(t, len(paragraphs), 3)
+	return paragraphs[2]
+}
+
+func (pt *passwordResetTest) resetPassword(token, password string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	body := `{"token": "` + token + `", "password": "` + password + `"}`
+	pt.handler.HandleResetPassword(rr, httptest.NewRequest(http.MethodPut, "/password-reset", strings.NewReader(body)))
+	return rr
+}
+
+func (pt *passwordResetTest) passwordMatches(t *testing.T, password string) bool {
+	t.Helper()
+	user, err := pt.userStore.GetUserByID(int64(pt.user.ID))
+	require.NoError(t, err)
+	matches, err := user.PasswordHash.Matches(password)
+	require.NoError(t, err)
+	return matches
+}
+
+func TestResetPassword(t *testing.T) {
+	pt := newPasswordResetTest(t)
+	_, _, err := pt.tokenStore.CreateSession(int64(pt.user.ID), "phone")
+	require.NoError(t, err)
+	_, _, err = pt.tokenStore.CreateSession(int64(pt.user.ID), "laptop")
+	require.NoError(t, err)
+
+	token := pt.requestReset(t, pt.user.Email)
+	require.NotEmpty(t, token)
+	assert.Equal(t, pt.user.Email, pt.mailer.Messages()[0].To)
+
+	rr := pt.resetPassword(token, "new-password")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, pt.passwordMatches(t, "new-password"))
+
+	// every session is logged out and the token cannot be used again
+	assert.Empty(t, pt.tokenStore.tokens)
+	rr = pt.resetPassword(token, "another-password")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.True(t, pt.passwordMatches(t, "new-password"))
+}
+
+func TestResetPasswordExpiredToken(t *testing.T) {
+	pt := newPasswordResetTest(t)
+
+	token := pt.requestReset(t, pt.user.Email)
+	found := pt.tokenStore.find(tokens.ScopePasswordReset, token)
+	require.NotNil(t, found)
+	assert.WithinDuration(t, time.Now().Add(tokens.PasswordResetTTL), found.Expiry, time.Minute)
+	found.Expiry = time.Now().Add(-time.Second)
+
+	rr := pt.resetPassword(token, "new-password")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.True(t, pt.passwordMatches(t, "old-password"))
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	pt := newPasswordResetTest(t)
+
+	// unknown addresses get the same answer but no email
+	assert.Empty(t, pt.requestReset(t, "nobody@example.com"))
+	assert.Empty(t, pt.mailer.Messages())
+
+	// only the latest token stays valid
+	first := pt.requestReset(t, pt.user.Email)
+	second := pt.requestReset(t, pt.user.Email)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, http.StatusUnprocessableEntity, pt.resetPassword(first, "new-password").Code)
+	assert.Equal(t, http.StatusOK, pt.resetPassword(second, "new-password").Code)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+// applyPatch applies patch to the JSON document original, reading it as a
+// JSON merge patch or a JSON patch depending on contentType. Plain
+// application/json is treated as a merge patch. On failure it also returns
+// the status to answer with.
+func applyPatch(contentType string, original, patch []byte) ([]byte, int, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch mediaType {
+	case mergePatchMediaType, "application/json":
+		patched, err := jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("The merge patch is not a valid JSON object")
+		}
+		return patched, 0, nil
+	case jsonPatchMediaType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("The JSON patch is not a valid list of operations")
+		}
+		patched, err := operations.Apply(original)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, http.StatusConflict, errors.New("A test operation of the JSON patch failed")
+		}
+		if err != nil {
+			return nil, http.StatusUnprocessableEntity, errors.New("The JSON patch could not be applied: " + err.Error())
+		}
+		return patched, 0, nil
+	default:
+		return nil, http.StatusUnsupportedMediaType, errors.New("The Content-Type must be " + mergePatchMediaType + " or " + jsonPatchMediaType)
+	}
+}
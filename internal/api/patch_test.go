@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPatch(t *testing.T) {
+	original := []byte(`{"title":"Leg Day","entries":[{"exercise_name":"Squat","reps":5},{"exercise_name":"Plank","duration_seconds":60}]}`)
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        string
+		status      int
+	}{
+		{
+			name:        "Merge Patch Clears Field",
+			contentType: "application/merge-patch+json",
+			patch:       `{"title":null}`,
+			want:        `{"entries":[{"exercise_name":"Squat","reps":5},{"exercise_name":"Plank","duration_seconds":60}]}`,
+		},
+		{
+			name:        "JSON Patch On Entry By Index",
+			contentType: "application/json-patch+json; charset=utf-8",
+			patch:       `[{"op":"replace","path":"/entries/0/reps","value":8},{"op":"remove","path":"/entries/1"}]`,
+			want:        `{"title":"Leg Day","entries":[{"exercise_name":"Squat","reps":8}]}`,
+		},
+		{
+			name:        "Failed Test Operation",
+			contentType: "application/json-patch+json",
+			patch:       `[{"op":"test","path":"/title","value":"Push Day"}]`,
+			status:      http.StatusConflict,
+		},
+		{
+			name:        "Missing Entry",
+			contentType: "application/json-patch+json",
+			patch:       `[{"op":"replace","path":"/entries/5/reps","value":8}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "Unsupported Media Type",
+			contentType: "text/plain",
+			patch:       `{}`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, status, err := applyPatch(tt.contentType, original, []byte(tt.patch))
+			if tt.status != 0 {
+				assert.Error(t, err)
+				assert.Equal(t, tt.status, status)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(patched))
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// ProgramHandler handles the requests on training programs and enrolling in
+// them.
+type ProgramHandler struct {
+	programStore store.ProgramStore
+	logger       *log.Logger
+}
+
+// NewProgramHandler creates a new instance of ProgramHandler.
+func NewProgramHandler(programStore store.ProgramStore, logger *log.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		programStore: programStore,
+		logger:       logger,
+	}
+}
+
+type programRequest struct {
+	Title       *string             `json:"title"`
+	Description *string             `json:"description"`
+	IsPublic    *bool               `json:"is_public"`
+	Weeks       []store.ProgramWeek `json:"weeks"`
+}
+
+// apply copies the fields present in the request onto program, weeks are
+// replaced as a whole and days without a progression do not progress.
+func (req *programRequest) apply(program *store.Program) {
+	if req.Title != nil {
+		program.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Description != nil {
+		program.Description = *req.Description
+	}
+	if req.IsPublic != nil {
+		program.IsPublic = *req.IsPublic
+	}
+	if req.Weeks != nil {
+		program.Weeks = req.Weeks
+	}
+	for i := range program.Weeks {
+		for j := range program.Weeks[i].Days {
+			day := &program.Weeks[i].Days[j]
+			if day.Progression.Type == "" {
+				day.Progression.Type = store.ProgressionNone
+			}
+		}
+	}
+}
+
+// HandleListPrograms handles the GET request listing the current user's
+// programs and every public program. Like every program response it shows
+// the amounts of linear progressions in the units of the request.
+func (ph *ProgramHandler) HandleListPrograms(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	programs, err := ph.programStore.ListPrograms(middleware.GetUser(r).ID)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"programs": programsInUnits(programs, u), "units": u})
+}
+
+// HandleGetProgramByID handles the GET request to retrieve a program by its
+// ID. Private programs of other users answer 404.
+func (ph *ProgramHandler) HandleGetProgramByID(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	program, ok := ph.readVisibleProgram(w, r)
+	if !ok {
+		return
+	}
+	if writeNotModified(w, r, utils.ETagIn(program.Version, u.Weight)) {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"program": programInUnits(program, u), "units": u})
+}
+
+// HandleCreateProgram handles the POST request creating a program of the
+// current user.
+func (ph *ProgramHandler) HandleCreateProgram(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	var req programRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	programWeeksFromUnits(req.Weeks, nil, u)
+	program := &store.Program{UserID: middleware.GetUser(r).ID}
+	req.apply(program)
+
+	v := validator.New()
+	store.ValidateProgram(v, program)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = ph.programStore.CreateProgram(program)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(program.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"program": programInUnits(program, u), "units": u})
+}
+
+// HandleUpdateProgram handles the PUT request changing one of the current
+// user's programs, the weeks sent replace all of its weeks.
+func (ph *ProgramHandler) HandleUpdateProgram(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	program, ok := ph.readOwnProgram(w, r)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(program.Version, u.Weight)) {
+		return
+	}
+
+	var req programRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	programWeeksFromUnits(req.Weeks, program.Weeks, u)
+	req.apply(program)
+
+	v := validator.New()
+	store.ValidateProgram(v, program)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = ph.programStore.UpdateProgram(program)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(program.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"program": programInUnits(program, u), "units": u})
+}
+
+// HandleDeleteProgram handles the DELETE request removing one of the current
+// user's programs.
+func (ph *ProgramHandler) HandleDeleteProgram(w http.ResponseWriter, r *http.Request) {
+	program, ok := ph.readOwnProgram(w, r)
+	if !ok {
+		return
+	}
+	err := ph.programStore.DeleteProgram(int64(program.ID))
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// HandleEnroll handles the POST request enrolling the current user in a
+// program. Every session of the program is scheduled from start_date on,
+// today when it is left out, and training_maxes gives the training max per
+// exercise name for sessions with percent progression. The training maxes and
+// the sessions are in the units of the request.
+func (ph *ProgramHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	program, ok := ph.readVisibleProgram(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		StartDate     *string            `json:"start_date"`
+		TrainingMaxes map[string]float64 `json:"training_maxes"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	v := validator.New()
+	startDate := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.StartDate != nil {
+		startDate, err = time.Parse(time.DateOnly, *req.StartDate)
+		v.Check(err == nil, "start_date", "must be a YYYY-MM-DD date")
+	}
+	for name, weight := range req.TrainingMaxes {
+		v.Check(validator.NotBlank(name), "training_maxes", "must not have blank exercise names")
+		v.Check(weight > 0, "training_maxes."+name, "must be greater than zero")
+	}
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if req.TrainingMaxes == nil {
+		req.TrainingMaxes = map[string]float64{}
+	}
+	for name, weight := range req.TrainingMaxes {
+		req.TrainingMaxes[name] = u.ToKilograms(weight)
+	}
+
+	enrollment := &store.Enrollment{
+		UserID:        middleware.GetUser(r).ID,
+		ProgramID:     &program.ID,
+		StartDate:     startDate,
+		TrainingMaxes: req.TrainingMaxes,
+	}
+	err = ph.programStore.Enroll(enrollment)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"enrollment": enrollmentInUnits(enrollment, u), "units": u})
+}
+
+// readVisibleProgram loads the program named in the URL when it is public or
+// belongs to the current user, and writes the error response otherwise.
+func (ph *ProgramHandler) readVisibleProgram(w http.ResponseWriter, r *http.Request) (*store.Program, bool) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid program ID")
+		return nil, false
+	}
+	program, err := ph.programStore.GetProgramByID(programID)
+	if err != nil {
+		storeErrorResponse(ph.logger, w, r, err)
+		return nil, false
+	}
+	if !program.IsPublic && program.UserID != middleware.GetUser(r).ID {
+		storeErrorResponse(ph.logger, w, r, store.ErrNotFound)
+		return nil, false
+	}
+	return program, true
+}
+
+// readOwnProgram is readVisibleProgram for changes, which only the owner of a
+// program can make.
+func (ph *ProgramHandler) readOwnProgram(w http.ResponseWriter, r *http.Request) (*store.Program, bool) {
+	program, ok := ph.readVisibleProgram(w, r)
+	if !ok {
+		return nil, false
+	}
+	if program.UserID != middleware.GetUser(r).ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to change this program")
+		return nil, false
+	}
+	return program, true
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+)
+
+// RecordHandler handles the requests on the personal records of a user.
+type RecordHandler struct {
+	recordStore store.RecordStore
+	logger      *log.Logger
+}
+
+// NewRecordHandler creates a new instance of RecordHandler.
+func NewRecordHandler(recordStore store.RecordStore, logger *log.Logger) *RecordHandler {
+	return &RecordHandler{
+		recordStore: recordStore,
+		logger:      logger,
+	}
+}
+
+// HandleListRecords handles the GET request listing the standing records of a
+// user on every exercise. Like the history it shows weights in the units of
+// the request.
+func (rh *RecordHandler) HandleListRecords(w http.ResponseWriter, r *http.Request) {
+	userID, formula, u, ok := rh.readRecordsRequest(w, r)
+	if !ok {
+		return
+	}
+	records, err := rh.recordStore.GetCurrentRecords(userID, formula)
+	if err != nil {
+		storeErrorResponse(rh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"records": recordsInUnits(records, u), "units": u})
+}
+
+// HandleGetRecordHistory handles the GET request listing every record a user
+// set on one exercise, oldest first. The exercise is a catalog ID or the name
+// used by entries not linked to the catalog.
+func (rh *RecordHandler) HandleGetRecordHistory(w http.ResponseWriter, r *http.Request) {
+	userID, formula, u, ok := rh.readRecordsRequest(w, r)
+	if !ok {
+		return
+	}
+	exercise, err := url.PathUnescape(chi.URLParam(r, "exercise"))
+	exercise = strings.TrimSpace(exercise)
+	if err != nil || exercise == "" {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid exercise")
+		return
+	}
+	records, err := rh.recordStore.GetRecordHistory(userID, exercise, formula)
+	if err != nil {
+		storeErrorResponse(rh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"records": recordsInUnits(records, u), "units": u})
+}
+
+// readRecordsRequest reads the user ID, the one rep max formula and the units
+// of a records request, which users can only make about themselves.
+func (rh *RecordHandler) readRecordsRequest(w http.ResponseWriter, r *http.Request) (int64, string, units.Units, bool) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid user ID")
+		return 0, "", units.Units{}, false
+	}
+	if int64(middleware.GetUser(r).ID) != userID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to view these records")
+		return 0, "", units.Units{}, false
+	}
+	fieldErrors := map[string]string{}
+	formula := r.URL.Query().Get("formula")
+	if formula == "" {
+		formula = store.FormulaEpley
+	}
+	if !slices.Contains(store.OneRepMaxFormulas, formula) {
+		fieldErrors["formula"] = "must be one of " + strings.Join(store.OneRepMaxFormulas, ", ")
+	}
+	u := readUnitsQuery(r, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return 0, "", units.Units{}, false
+	}
+	return userID, formula, u, true
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// ScheduleHandler handles the requests on the planned workouts of the current
+// user.
+type ScheduleHandler struct {
+	scheduleStore store.ScheduleStore
+	workoutStore  store.WorkoutStore
+	logger        *log.Logger
+}
+
+// NewScheduleHandler creates a new instance of ScheduleHandler.
+func NewScheduleHandler(scheduleStore store.ScheduleStore, workoutStore store.WorkoutStore, logger *log.Logger) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleStore: scheduleStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+// HandleGetSchedule handles the GET request listing the planned workouts of
+// the current user. By default it lists what is due: the sessions not
+// completed yet that are scheduled up to today. Weights are shown in the units
+// of the request.
+func (sh *ScheduleHandler) HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	filter := store.ScheduleFilter{
+		UserID: middleware.GetUser(r).ID,
+		Status: r.URL.Query().Get("status"),
+	}
+	if filter.Status == "" {
+		filter.Status = "due"
+	}
+
+	fieldErrors := map[string]string{}
+	if !slices.Contains(store.ScheduleStatuses, filter.Status) {
+		fieldErrors["status"] = "must be one of " + strings.Join(store.ScheduleStatuses, ", ")
+	}
+	u := readUnitsQuery(r, fieldErrors)
+	var err error
+	filter.From, err = utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		fieldErrors["from"] = err.Error()
+	}
+	filter.To, err = utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		fieldErrors["to"] = err.Error()
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+	if filter.To == nil && filter.Status == "due" {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		filter.To = &today
+	}
+
+	schedule, err := sh.scheduleStore.ListSchedule(filter)
+	if err != nil {
+		storeErrorResponse(sh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"schedule": scheduleInUnits(schedule, u), "units": u})
+}
+
+// HandleCompletePlannedWorkout handles the POST request completing a planned
+// workout. With a workout_id the logged workout is linked to the plan and
+// compared with the prescription, without one a workout is created exactly as
+// prescribed. Weights are shown in the units of the request.
+func (sh *ScheduleHandler) HandleCompletePlannedWorkout(w http.ResponseWriter, r *http.Request) {
+	plannedID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid planned workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	planned, err := sh.scheduleStore.GetPlannedWorkout(plannedID)
+	if err != nil {
+		storeErrorResponse(sh.logger, w, r, err)
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	if planned.UserID != currentUser.ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to access this planned workout")
+		return
+	}
+
+	var req struct {
+		WorkoutID *int64 `json:"workout_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.WorkoutID == nil {
+		workout := planned.NewWorkout()
+		markEstimatedCalories(workout, nil)
+		v := validator.New()
+		store.ValidateWorkout(v, workout)
+		if !v.Valid() {
+			failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		createdWorkout, err := sh.workoutStore.CreateWorkout(workout)
+		if err != nil {
+			storeErrorResponse(sh.logger, w, r, err)
+			return
+		}
+		planned.WorkoutID = &createdWorkout.ID
+		planned.AsPrescribed = createdWorkout.AsPrescribed
+		planned.Status = store.PlannedStatusCompleted
+		w.Header().Set("ETag", utils.ETagIn(createdWorkout.Version, u.Weight))
+		utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"planned_workout": plannedInUnits(planned, u), "workout": workoutInUnits(createdWorkout, u), "units": u})
+		return
+	}
+
+	workout, err := sh.workoutStore.GetWorkoutByID(*req.WorkoutID)
+	if err != nil {
+		storeErrorResponse(sh.logger, w, r, err)
+		return
+	}
+	if workout.UserID != currentUser.ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to access this workout")
+		return
+	}
+	asPrescribed := planned.DoneAsPrescribed(workout)
+	err = sh.scheduleStore.CompletePlannedWorkout(plannedID, *req.WorkoutID, asPrescribed)
+	if err != nil {
+		storeErrorResponse(sh.logger, w, r, err)
+		return
+	}
+	planned.WorkoutID = &workout.ID
+	planned.AsPrescribed = &asPrescribed
+	planned.Status = store.PlannedStatusCompleted
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"planned_workout": plannedInUnits(planned, u), "units": u})
+}
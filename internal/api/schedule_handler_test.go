@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGetScheduleInUnits(t *testing.T) {
+	user := &store.User{ID: 1, Username: "tester", Units: units.Imperial}
+	scheduleStore := &memoryScheduleStore{schedule: []*store.PlannedWorkout{{
+		ID:      1,
+		UserID:  user.ID,
+		Title:   "Legs",
+		Entries: []store.PlannedEntry{{ExerciseName: "Squat", Weight: floatPtr(100)}},
+	}}}
+	sh := NewScheduleHandler(scheduleStore, newMemoryWorkoutStore(), log.New(io.Discard, "", 0))
+
+	getSchedule := func(query string) (*store.PlannedWorkout, units.Units) {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/me/schedule"+query, nil)
+		sh.HandleGetSchedule(rr, middleware.SetUser(r, user))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Schedule []*store.PlannedWorkout `json:"schedule"`
+			Units    units.Units             `json:"units"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.Len(t, response.Schedule, 1)
+		return response.Schedule[0], response.Units
+	}
+
+	planned, u := getSchedule("")
+	assert.Equal(t, units.Pound, u.Weight)
+	assert.Equal(t, floatPtr(220.5), planned.Entries[0].Weight)
+
+	planned, u = getSchedule("?units=metric")
+	assert.Equal(t, units.Kilogram, u.Weight)
+	assert.Equal(t, floatPtr(100), planned.Entries[0].Weight)
+	assert.Equal(t, floatPtr(100), scheduleStore.schedule[0].Entries[0].Weight)
+}
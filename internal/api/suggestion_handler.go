@@ -0,0 +1,110 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// SuggestionHandler handles the requests suggesting the load of the next
+// session of an exercise.
+type SuggestionHandler struct {
+	suggestionStore store.SuggestionStore
+	logger          *log.Logger
+}
+
+// NewSuggestionHandler creates a new instance of SuggestionHandler.
+func NewSuggestionHandler(suggestionStore store.SuggestionStore, logger *log.Logger) *SuggestionHandler {
+	return &SuggestionHandler{
+		suggestionStore: suggestionStore,
+		logger:          logger,
+	}
+}
+
+// HandleGetSuggestion handles the GET request suggesting the next session of
+// one exercise for the current user, from their recent sessions of it. The
+// exercise is a catalog ID or an exercise name, the progression model and its
+// settings come from the query string. The suggestion and the increment are
+// in the units of the request.
+func (sh *SuggestionHandler) HandleGetSuggestion(w http.ResponseWriter, r *http.Request) {
+	fieldErrors := map[string]string{}
+	exercise := strings.TrimSpace(r.URL.Query().Get("exercise"))
+	if exercise == "" {
+		fieldErrors["exercise"] = "must be provided"
+	}
+	u := readUnitsQuery(r, fieldErrors)
+	model := readProgressionModel(r, u, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+
+	var exerciseID *int
+	if id, err := strconv.Atoi(exercise); err == nil {
+		exerciseID = &id
+		exercise = ""
+	}
+	history, err := sh.suggestionStore.GetExerciseHistory(middleware.GetUser(r).ID, exerciseID, exercise)
+	if err != nil {
+		storeErrorResponse(sh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"suggestion": model.Suggest(historyInUnits(history, u)), "units": u})
+}
+
+// readProgressionModel reads the progression model and its settings from the
+// query string, recording the problems found in fieldErrors. Settings left out
+// keep the defaults of the model. The increment is in u, the model is applied
+// to a history in u.
+func readProgressionModel(r *http.Request, u units.Units, fieldErrors map[string]string) store.ProgressionModel {
+	modelType := r.URL.Query().Get("model")
+	if modelType == "" {
+		modelType = store.ModelDoubleProgression
+	}
+	model := store.DefaultProgressionModel(modelType)
+	if u.Weight == units.Pound {
+		// the smallest pair of plates, as 2.5 kg is in metric
+		model.Increment = 5
+	}
+
+	ints := map[string]*int{"reps_min": &model.RepsMin, "reps_max": &model.RepsMax, "deload_after": &model.DeloadAfter}
+	for key, field := range ints {
+		value, err := utils.ReadIntQuery(r, key)
+		if err != nil {
+			fieldErrors[key] = err.Error()
+		} else if value != nil {
+			*field = *value
+		}
+	}
+	floats := map[string]*float64{"increment": &model.Increment, "rpe": &model.TargetRPE, "deload_percent": &model.DeloadPercent}
+	for key, field := range floats {
+		value, err := utils.ReadFloatQuery(r, key)
+		if err != nil {
+			fieldErrors[key] = err.Error()
+		} else if value != nil {
+			*field = *value
+		}
+	}
+	reps, err := utils.ReadIntQuery(r, "reps")
+	if err != nil {
+		fieldErrors["reps"] = err.Error()
+	}
+	model.TargetReps = reps
+	if len(fieldErrors) > 0 {
+		return model
+	}
+
+	v := validator.New()
+	store.ValidateProgressionModel(v, &model)
+	for key, message := range v.Errors {
+		fieldErrors[key] = message
+	}
+	return model
+}
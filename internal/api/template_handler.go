@@ -0,0 +1,322 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// TemplateHandler handles the requests on workout templates and the workouts
+// started from them.
+type TemplateHandler struct {
+	templateStore   store.TemplateStore
+	workoutStore    store.WorkoutStore
+	suggestionStore store.SuggestionStore
+	logger          *log.Logger
+}
+
+// NewTemplateHandler creates a new instance of TemplateHandler.
+func NewTemplateHandler(templateStore store.TemplateStore, workoutStore store.WorkoutStore, suggestionStore store.SuggestionStore, logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore:   templateStore,
+		workoutStore:    workoutStore,
+		suggestionStore: suggestionStore,
+		logger:          logger,
+	}
+}
+
+type templateRequest struct {
+	Title           *string               `json:"title"`
+	Description     *string               `json:"description"`
+	DurationMinutes *int                  `json:"duration_minutes"`
+	Entries         []store.TemplateEntry `json:"entries"`
+}
+
+// apply copies the fields present in the request onto template, entries are
+// replaced as a whole.
+func (req *templateRequest) apply(template *store.WorkoutTemplate) {
+	if req.Title != nil {
+		template.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.DurationMinutes != nil {
+		template.DurationMinutes = *req.DurationMinutes
+	}
+	if req.Entries != nil {
+		template.Entries = req.Entries
+	}
+}
+
+// HandleListTemplates handles the GET request listing the current user's
+// templates. Like every template response it shows weights in the units of
+// the request.
+func (th *TemplateHandler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	templates, err := th.templateStore.ListTemplates(middleware.GetUser(r).ID)
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"templates": templatesInUnits(templates, u), "units": u})
+}
+
+// HandleGetTemplateByID handles the GET request to retrieve a template by its
+// ID.
+func (th *TemplateHandler) HandleGetTemplateByID(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	template, ok := th.readOwnTemplate(w, r)
+	if !ok {
+		return
+	}
+	if writeNotModified(w, r, utils.ETagIn(template.Version, u.Weight)) {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": templateInUnits(template, u), "units": u})
+}
+
+// HandleCreateTemplate handles the POST request creating a template of the
+// current user, with weights in the units of the request.
+func (th *TemplateHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	var req templateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	templateEntriesFromUnits(req.Entries, nil, u)
+	template := &store.WorkoutTemplate{UserID: middleware.GetUser(r).ID, Entries: []store.TemplateEntry{}}
+	req.apply(template)
+	th.createTemplate(w, r, template, u)
+}
+
+// HandleUpdateTemplate handles the PUT request changing a template, the
+// entries sent replace all of its entries.
+func (th *TemplateHandler) HandleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	template, ok := th.readOwnTemplate(w, r)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(template.Version, u.Weight)) {
+		return
+	}
+
+	var req templateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	templateEntriesFromUnits(req.Entries, template.Entries, u)
+	req.apply(template)
+
+	v := validator.New()
+	store.ValidateTemplate(v, template)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = th.templateStore.UpdateTemplate(template)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(template.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": templateInUnits(template, u), "units": u})
+}
+
+// HandleDeleteTemplate handles the DELETE request removing a template,
+// workouts started from it are kept.
+func (th *TemplateHandler) HandleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := th.readOwnTemplate(w, r)
+	if !ok {
+		return
+	}
+	err := th.templateStore.DeleteTemplate(int64(template.ID))
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// HandleStartTemplate handles the POST request starting a workout from a
+// template. The workout is created right away with the lower end of every
+// planned range and is then edited like any other workout. It comes with a
+// suggestion for every entry, made with the progression model of the query
+// string as with GET /me/suggestions. The workout and the suggestions are
+// shown in the units of the request.
+func (th *TemplateHandler) HandleStartTemplate(w http.ResponseWriter, r *http.Request) {
+	fieldErrors := map[string]string{}
+	u := readUnitsQuery(r, fieldErrors)
+	model := readProgressionModel(r, u, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+	template, ok := th.readOwnTemplate(w, r)
+	if !ok {
+		return
+	}
+	// suggestions go first, the new workout is not part of the history
+	suggestions, err := th.suggestEntries(r, template, model, u)
+	if err != nil {
+		serverErrorResponse(th.logger, w, r, err)
+		return
+	}
+	workout := template.NewWorkout()
+	markEstimatedCalories(workout, nil)
+
+	v := validator.New()
+	store.ValidateWorkout(v, workout)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	createdWorkout, err := th.workoutStore.CreateWorkout(workout)
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(createdWorkout.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": workoutInUnits(createdWorkout, u), "suggestions": suggestions, "units": u})
+}
+
+// suggestEntries suggests the next session of every entry of a template. The
+// rep range of an entry is the range of double progression and its lower end
+// the target reps of the other models, unless the query string sets them. The
+// suggestions are worked out in u.
+func (th *TemplateHandler) suggestEntries(r *http.Request, template *store.WorkoutTemplate, model store.ProgressionModel, u units.Units) ([]*store.Suggestion, error) {
+	qs := r.URL.Query()
+	suggestions := make([]*store.Suggestion, 0, len(template.Entries))
+	for _, entry := range template.Entries {
+		entryModel := model
+		if entry.RepsMin != nil {
+			if !qs.Has("reps_min") && !qs.Has("reps_max") {
+				entryModel.RepsMin = *entry.RepsMin
+				entryModel.RepsMax = *entry.RepsMin
+				if entry.RepsMax != nil {
+					entryModel.RepsMax = *entry.RepsMax
+				}
+			}
+			if entryModel.TargetReps == nil && (model.Type != store.ModelRPE || *entry.RepsMin <= 12) {
+				entryModel.TargetReps = entry.RepsMin
+			}
+		}
+		history, err := th.suggestionStore.GetExerciseHistory(template.UserID, entry.ExerciseID, entry.ExerciseName)
+		if err != nil {
+			return nil, err
+		}
+		suggestion := entryModel.Suggest(historyInUnits(history, u))
+		suggestion.OrderIndex = entry.OrderIndex
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// HandleSaveWorkoutAsTemplate handles the POST request capturing one of the
+// current user's workouts as a new template. The body is optional and can
+// give the template a title of its own.
+func (th *TemplateHandler) HandleSaveWorkoutAsTemplate(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	workout, err := th.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	if workout.UserID != middleware.GetUser(r).ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to access this workout")
+		return
+	}
+
+	var req struct {
+		Title *string `json:"title"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	template := store.TemplateFromWorkout(workout)
+	if req.Title != nil {
+		template.Title = strings.TrimSpace(*req.Title)
+	}
+	th.createTemplate(w, r, template, u)
+}
+
+// createTemplate validates and stores a new template and writes the response
+// in u.
+func (th *TemplateHandler) createTemplate(w http.ResponseWriter, r *http.Request, template *store.WorkoutTemplate, u units.Units) {
+	v := validator.New()
+	store.ValidateTemplate(v, template)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := th.templateStore.CreateTemplate(template)
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(template.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"template": templateInUnits(template, u), "units": u})
+}
+
+// readOwnTemplate loads the template named in the URL when it belongs to the
+// current user, and writes the error response otherwise.
+func (th *TemplateHandler) readOwnTemplate(w http.ResponseWriter, r *http.Request) (*store.WorkoutTemplate, bool) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid template ID")
+		return nil, false
+	}
+	template, err := th.templateStore.GetTemplateByID(templateID)
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return nil, false
+	}
+	if template.UserID != middleware.GetUser(r).ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to access this template")
+		return nil, false
+	}
+	return template, true
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleStartTemplateInUnits(t *testing.T) {
+	user := &store.User{ID: 1, Username: "tester", Units: units.Metric}
+	templateStore := newMemoryTemplateStore(&store.WorkoutTemplate{
+		ID:              1,
+		UserID:          user.ID,
+		Title:           "Legs",
+		DurationMinutes: 45,
+		Entries: []store.TemplateEntry{
+			{ExerciseName: "Squat", SetsMin: 3, RepsMin: intPtr(5), WeightMin: floatPtr(100), OrderIndex: 1},
+		},
+	})
+	// every set reached the top of the range at 100 kg
+	done := true
+	sets := make([]store.WorkoutSet, 3)
+	for i := range sets {
+		sets[i] = store.WorkoutSet{SetNumber: i + 1, Type: store.SetWorking, Reps: intPtr(5), Weight: floatPtr(100), Completed: &done}
+	}
+	suggestionStore := memorySuggestionStore{"Squat": {
+		ExerciseName: "Squat",
+		Sessions:     []store.TrainingSession{{WorkoutID: 1, Date: time.Now(), Sets: sets}},
+	}}
+	th := NewTemplateHandler(templateStore, newMemoryWorkoutStore(), suggestionStore, log.New(io.Discard, "", 0))
+
+	router := chi.NewRouter()
+	router.Post("/templates/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		th.HandleStartTemplate(w, middleware.SetUser(r, user))
+	})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/templates/1/start?units=imperial", nil))
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var response struct {
+		Workout     store.Workout       `json:"workout"`
+		Suggestions []*store.Suggestion `json:"suggestions"`
+		Units       units.Units         `json:"units"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, units.Pound, response.Units.Weight)
+	assert.Equal(t, floatPtr(220.5), response.Workout.Entries[0].Weight)
+	require.Len(t, response.Suggestions, 1)
+	suggestion := response.Suggestions[0]
+	assert.Equal(t, store.SuggestIncrease, suggestion.Action)
+	// the increment is the imperial one and the reason quotes lb
+	assert.Equal(t, floatPtr(225.5), suggestion.Weight)
+	assert.Equal(t, "every set reached 5 reps at 220.5, add weight and start again at 5 reps", suggestion.Reason)
+}
+
+func TestTemplateWeightsInUnits(t *testing.T) {
+	user := &store.User{ID: 1, Username: "tester", Units: units.Imperial}
+	templateStore := newMemoryTemplateStore()
+	th := NewTemplateHandler(templateStore, newMemoryWorkoutStore(), memorySuggestionStore{}, log.New(io.Discard, "", 0))
+
+	router := chi.NewRouter()
+	router.Post("/templates/", func(w http.ResponseWriter, r *http.Request) {
+		th.HandleCreateTemplate(w, middleware.SetUser(r, user))
+	})
+	router.Get("/templates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		th.HandleGetTemplateByID(w, middleware.SetUser(r, user))
+	})
+	router.Put("/templates/{id}/", func(w http.ResponseWriter, r *http.Request) {
+		th.HandleUpdateTemplate(w, middleware.SetUser(r, user))
+	})
+	send := func(method, target, etag, body string) (*httptest.ResponseRecorder, *store.WorkoutTemplate) {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if etag != "" {
+			r.Header.Set("If-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+		var response struct {
+			Template *store.WorkoutTemplate `json:"template"`
+		}
+		if rr.Code < http.StatusBadRequest {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		}
+		return rr, response.Template
+	}
+	entries := `[{"exercise_name": "Squat", "sets_min": 3, "reps_min": 5, "weight_min": 225, "weight_max": 245, "order_index": 1}]`
+
+	rr, template := send(http.MethodPost, "/templates/", "", `{"title": "Legs", "duration_minutes": 45, "entries": `+entries+`}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, utils.ETagIn(1, "lb"), rr.Header().Get("ETag"))
+	assert.Equal(t, floatPtr(225), template.Entries[0].WeightMin)
+	stored := templateStore.templates[1]
+	assert.Equal(t, floatPtr(102.06), stored.Entries[0].WeightMin)
+	assert.Equal(t, floatPtr(111.13), stored.Entries[0].WeightMax)
+
+	rr, template = send(http.MethodGet, "/templates/1?units=metric", "", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, floatPtr(102.06), template.Entries[0].WeightMin)
+
+	// the kg ETag does not match the lb representation
+	rr, _ = send(http.MethodPut, "/templates/1/", utils.ETagIn(1, "kg"), `{"title": "Lower"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	// weights sent back as shown keep their stored value instead of drifting
+	templateStore.templates[1].Entries[0].ID = 7
+	templateStore.templates[1].Entries[0].WeightMin = floatPtr(100)
+	rr, template = send(http.MethodPut, "/templates/1/", utils.ETagIn(1, "lb"),
+		`{"title": "Lower", "entries": [{"id": 7, "exercise_name": "Squat", "sets_min": 3, "reps_min": 5, "weight_min": 220.5, "order_index": 1}]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Lower", template.Title)
+	assert.Equal(t, floatPtr(220.5), template.Entries[0].WeightMin)
+	assert.Equal(t, floatPtr(100), templateStore.templates[1].Entries[0].WeightMin)
+}
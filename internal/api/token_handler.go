@@ -1,11 +1,13 @@
 package api
 
 import (
+	"github.com/makhammatovb/femProject/internal/middleware"
 	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/tokens"
 	"github.com/makhammatovb/femProject/internal/utils"
 	"log"
 	"net/http"
-	"time"
+	"errors"
 	"encoding/json"
 )
 
@@ -20,6 +22,10 @@ type createTokenRequest struct {
 	Password  string `json:"password"`
 }
 
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokenHandler {
 	return &TokenHandler{
 		tokenStore: tokenStore,
@@ -34,34 +40,103 @@ func (th *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		th.logger.Println("error while decoding token:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request payload"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
 	user, err := th.userStore.GetUserByUsername(req.Username)
-	if err != nil || user == nil {
-		th.logger.Println("error while getting user:", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid username or password"})
+	if errors.Is(err, store.ErrNotFound) {
+		errorResponse(w, r, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
 		return
 	}
 
 	passwordDoMatch, err := user.PasswordHash.Matches(req.Password)
 	if err != nil {
 		th.logger.Println("error while comparing passwords:", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid username or password"})
+		errorResponse(w, r, http.StatusUnauthorized, "Invalid username or password")
 		return
 	}
 	if !passwordDoMatch {
 		th.logger.Println("error while comparing passwords:", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid username or password"})
+		errorResponse(w, r, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if !user.Activated {
+		errorResponse(w, r, http.StatusForbidden, "Your account must be activated before you can log in")
+		return
+	}
+
+	token, refreshToken, err := th.tokenStore.CreateSession(int64(user.ID), r.UserAgent())
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token, "refresh_token": refreshToken})
+}
+
+// HandleRefreshToken exchanges a refresh token for a new token pair.
+func (th *TokenHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.RefreshToken == "" {
+		th.logger.Println("error while decoding refresh token:", err)
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	token, err := th.tokenStore.CreateNewToken(int64(user.ID), 24*time.Hour, "authentication")
+	token, refreshToken, err := th.tokenStore.RotateRefreshToken(req.RefreshToken, r.UserAgent())
 	if err != nil {
-		th.logger.Println("error while creating token:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		switch {
+		case errors.Is(err, store.ErrTokenReused):
+			th.logger.Println("refresh token reused, token family revoked")
+			errorResponse(w, r, http.StatusUnauthorized, "Refresh token has already been used, please log in again")
+		case errors.Is(err, store.ErrInvalidToken):
+			errorResponse(w, r, http.StatusUnauthorized, "Invalid or expired refresh token")
+		default:
+			storeErrorResponse(th.logger, w, r, err)
+		}
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token})
-}
\ No newline at end of file
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token, "refresh_token": refreshToken})
+}
+
+// HandleListSessions lists the active sessions of the current user.
+func (th *TokenHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	sessions, err := th.tokenStore.GetSessionsForUser(int64(user.ID), middleware.GetToken(r))
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"sessions": sessions})
+}
+
+// HandleRevokeCurrentToken logs out the session the request was made with.
+func (th *TokenHandler) HandleRevokeCurrentToken(w http.ResponseWriter, r *http.Request) {
+	err := th.tokenStore.DeleteToken(middleware.GetToken(r))
+	if err != nil {
+		storeErrorResponse(th.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// HandleRevokeAllTokens logs out every session of the current user.
+func (th *TokenHandler) HandleRevokeAllTokens(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	for _, scope := range []string{tokens.ScopeAuthentication, tokens.ScopeRefresh} {
+		err := th.tokenStore.DeleteAllTokenForUser(int64(user.ID), scope)
+		if err != nil {
+			storeErrorResponse(th.logger, w, r, err)
+			return
+		}
+	}
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
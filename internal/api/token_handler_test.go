@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func refreshToken(t *testing.T, th *TokenHandler, plainText string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	t.Helper()
+	body := `{"refresh_token": "` + plainText + `"}`
+	rr := httptest.NewRecorder()
+	th.HandleRefreshToken(rr, httptest.NewRequest(http.MethodPost, "/tokens/refresh", strings.NewReader(body)))
+
+	var response map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	return rr, response
+}
+
+func TestHandleRefreshToken(t *testing.T) {
+	tokenStore := newMemoryTokenStore()
+	th := NewTokenHandler(tokenStore, nil, log.New(io.Discard, "", 0))
+
+	access, refresh, err := tokenStore.CreateSession(1, "test")
+	require.NoError(t, err)
+
+	rr, response := refreshToken(t, th, refresh.PlainText)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var rotated struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(response["refresh_token"], &rotated))
+	assert.NotEqual(t, refresh.PlainText, rotated.Token)
+	assert.Nil(t, tokenStore.find(tokens.ScopeAuthentication, access.PlainText), "the old access token is superseded")
+
+	// presenting the used token again is rejected and revokes the family
+	rr, response = refreshToken(t, th, refresh.PlainText)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `"Refresh token has already been used, please log in again"`, string(response["detail"]))
+	assert.Empty(t, tokenStore.tokens)
+
+	rr, response = refreshToken(t, th, rotated.Token)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `"Invalid or expired refresh token"`, string(response["detail"]))
+
+	rr, _ = refreshToken(t, th, "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+}
+
+func TestHandleRefreshTokenKeepsOtherSessions(t *testing.T) {
+	tokenStore := newMemoryTokenStore()
+	th := NewTokenHandler(tokenStore, nil, log.New(io.Discard, "", 0))
+
+	_, stolen, err := tokenStore.CreateSession(1, "test")
+	require.NoError(t, err)
+	other, otherRefresh, err := tokenStore.CreateSession(1, "other")
+	require.NoError(t, err)
+
+	rr, _ := refreshToken(t, th, stolen.PlainText)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr, _ = refreshToken(t, th, stolen.PlainText)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	assert.NotNil(t, tokenStore.find(tokens.ScopeAuthentication, other.PlainText))
+	rr, _ = refreshToken(t, th, otherRefresh.PlainText)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,421 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/makhammatovb/femProject/internal/utils"
+)
+
+// readUnits returns the units weights are given in for a request: those of
+// the units query parameter, else those the current user prefers. It answers
+// 400 for an unknown unit system.
+func readUnits(w http.ResponseWriter, r *http.Request) (units.Units, bool) {
+	fieldErrors := map[string]string{}
+	u := readUnitsQuery(r, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return u, false
+	}
+	return u, true
+}
+
+// readUnitsQuery is readUnits for handlers reading more query parameters,
+// recording an unknown unit system in fieldErrors.
+func readUnitsQuery(r *http.Request, fieldErrors map[string]string) units.Units {
+	system := r.URL.Query().Get("units")
+	if system == "" {
+		return units.Of(middleware.GetUser(r).Units)
+	}
+	if !units.Valid(system) {
+		fieldErrors["units"] = "must be one of " + strings.Join(units.Systems, ", ")
+	}
+	return units.Of(system)
+}
+
+// workoutInUnits returns a copy of workout with its weights converted from kg
+// to u, leaving workout as it is.
+func workoutInUnits(workout *store.Workout, u units.Units) *store.Workout {
+	if u.IsCanonical() {
+		return workout
+	}
+	converted := *workout
+	converted.Entries = slices.Clone(workout.Entries)
+	for i := range converted.Entries {
+		converted.Entries[i] = entryInUnits(converted.Entries[i], u)
+	}
+	converted.NewRecords = slices.Clone(workout.NewRecords)
+	for i := range converted.NewRecords {
+		converted.NewRecords[i] = recordInUnits(converted.NewRecords[i], u)
+	}
+	return &converted
+}
+
+// workoutsInUnits applies workoutInUnits to every workout.
+func workoutsInUnits(workouts []*store.Workout, u units.Units) []*store.Workout {
+	if u.IsCanonical() {
+		return workouts
+	}
+	converted := make([]*store.Workout, len(workouts))
+	for i, workout := range workouts {
+		converted[i] = workoutInUnits(workout, u)
+	}
+	return converted
+}
+
+// entryInUnits is workoutInUnits for a single entry.
+func entryInUnits(entry store.WorkoutEntry, u units.Units) store.WorkoutEntry {
+	if u.IsCanonical() {
+		return entry
+	}
+	entry.Weight = convertWeight(entry.Weight, u.FromKilograms)
+	entry.SetList = slices.Clone(entry.SetList)
+	for i := range entry.SetList {
+		entry.SetList[i].Weight = convertWeight(entry.SetList[i].Weight, u.FromKilograms)
+	}
+	return entry
+}
+
+// recordInUnits is workoutInUnits for a personal record. The value is a
+// weight for weight records only, strength scores have no unit.
+func recordInUnits(record store.PersonalRecord, u units.Units) store.PersonalRecord {
+	if u.IsCanonical() {
+		return record
+	}
+	record.Weight = convertWeight(record.Weight, u.FromKilograms)
+	record.BodyWeight = convertWeight(record.BodyWeight, u.FromKilograms)
+	if record.Type == store.RecordHeaviestWeight || record.Type == store.RecordEstimated1RM {
+		record.Value = u.FromKilograms(record.Value)
+	}
+	return record
+}
+
+// recordsInUnits applies recordInUnits to every record.
+func recordsInUnits(records []*store.PersonalRecord, u units.Units) []*store.PersonalRecord {
+	if u.IsCanonical() {
+		return records
+	}
+	converted := make([]*store.PersonalRecord, len(records))
+	for i, record := range records {
+		convertedRecord := recordInUnits(*record, u)
+		converted[i] = &convertedRecord
+	}
+	return converted
+}
+
+// plannedInUnits is workoutInUnits for a planned workout.
+func plannedInUnits(planned *store.PlannedWorkout, u units.Units) *store.PlannedWorkout {
+	if u.IsCanonical() {
+		return planned
+	}
+	converted := *planned
+	converted.Entries = slices.Clone(planned.Entries)
+	for i := range converted.Entries {
+		converted.Entries[i].Weight = convertWeight(converted.Entries[i].Weight, u.FromKilograms)
+	}
+	return &converted
+}
+
+// scheduleInUnits applies plannedInUnits to every planned workout.
+func scheduleInUnits(schedule []*store.PlannedWorkout, u units.Units) []*store.PlannedWorkout {
+	if u.IsCanonical() {
+		return schedule
+	}
+	converted := make([]*store.PlannedWorkout, len(schedule))
+	for i, planned := range schedule {
+		converted[i] = plannedInUnits(planned, u)
+	}
+	return converted
+}
+
+// programInUnits returns a copy of program with the amounts of its linear
+// progressions converted from kg to u, percent progressions have no unit.
+func programInUnits(program *store.Program, u units.Units) *store.Program {
+	if u.IsCanonical() {
+		return program
+	}
+	converted := *program
+	converted.Weeks = slices.Clone(program.Weeks)
+	for i := range converted.Weeks {
+		week := &converted.Weeks[i]
+		week.Days = slices.Clone(week.Days)
+		for j := range week.Days {
+			progression := &week.Days[j].Progression
+			if progression.Type == store.ProgressionLinear {
+				progression.Amount = u.FromKilograms(progression.Amount)
+			}
+		}
+	}
+	return &converted
+}
+
+// programsInUnits applies programInUnits to every program.
+func programsInUnits(programs []*store.Program, u units.Units) []*store.Program {
+	if u.IsCanonical() {
+		return programs
+	}
+	converted := make([]*store.Program, len(programs))
+	for i, program := range programs {
+		converted[i] = programInUnits(program, u)
+	}
+	return converted
+}
+
+// enrollmentInUnits returns a copy of enrollment with its training maxes and
+// the weights of its sessions converted from kg to u.
+func enrollmentInUnits(enrollment *store.Enrollment, u units.Units) *store.Enrollment {
+	if u.IsCanonical() {
+		return enrollment
+	}
+	converted := *enrollment
+	converted.TrainingMaxes = make(map[string]float64, len(enrollment.TrainingMaxes))
+	for name, weight := range enrollment.TrainingMaxes {
+		converted.TrainingMaxes[name] = u.FromKilograms(weight)
+	}
+	converted.Sessions = scheduleInUnits(enrollment.Sessions, u)
+	return &converted
+}
+
+// templateInUnits returns a copy of template with the weight ranges of its
+// entries converted from kg to u.
+func templateInUnits(template *store.WorkoutTemplate, u units.Units) *store.WorkoutTemplate {
+	if u.IsCanonical() {
+		return template
+	}
+	converted := *template
+	converted.Entries = slices.Clone(template.Entries)
+	for i := range converted.Entries {
+		entry := &converted.Entries[i]
+		entry.WeightMin = convertWeight(entry.WeightMin, u.FromKilograms)
+		entry.WeightMax = convertWeight(entry.WeightMax, u.FromKilograms)
+	}
+	return &converted
+}
+
+// templatesInUnits applies templateInUnits to every template.
+func templatesInUnits(templates []*store.WorkoutTemplate, u units.Units) []*store.WorkoutTemplate {
+	if u.IsCanonical() {
+		return templates
+	}
+	converted := make([]*store.WorkoutTemplate, len(templates))
+	for i, template := range templates {
+		converted[i] = templateInUnits(template, u)
+	}
+	return converted
+}
+
+// volumeInUnits returns a copy of a volume series with its tonnages converted
+// from kg to u, the percentages have no unit.
+func volumeInUnits(series []*store.VolumeSeries, u units.Units) []*store.VolumeSeries {
+	if u.IsCanonical() {
+		return series
+	}
+	converted := make([]*store.VolumeSeries, len(series))
+	for i, group := range series {
+		points := slices.Clone(group.Points)
+		for j := range points {
+			point := &points[j]
+			point.Tonnage = u.FromKilograms(point.Tonnage)
+			point.Change = convertWeight(point.Change, u.FromKilograms)
+			point.MovingAverage = u.FromKilograms(point.MovingAverage)
+		}
+		converted[i] = &store.VolumeSeries{Key: group.Key, Points: points}
+	}
+	return converted
+}
+
+// historyInUnits returns a copy of history with the weights of its sets
+// converted from kg to u, so a suggestion worked out from it comes in u down
+// to the weights quoted in its reason.
+func historyInUnits(history *store.ExerciseHistory, u units.Units) *store.ExerciseHistory {
+	if u.IsCanonical() {
+		return history
+	}
+	converted := *history
+	converted.Sessions = slices.Clone(history.Sessions)
+	for i := range converted.Sessions {
+		session := &converted.Sessions[i]
+		session.Sets = slices.Clone(session.Sets)
+		for j := range session.Sets {
+			session.Sets[j].Weight = convertWeight(session.Sets[j].Weight, u.FromKilograms)
+		}
+	}
+	return &converted
+}
+
+// userInUnits returns a copy of user with the body weight converted from kg
+// to u.
+func userInUnits(user *store.User, u units.Units) *store.User {
+	if u.IsCanonical() {
+		return user
+	}
+	converted := *user
+	converted.BodyWeight = convertWeight(user.BodyWeight, u.FromKilograms)
+	return &converted
+}
+
+// measurementInUnits returns a copy of measurement with the body weight and
+// its trend converted from kg to u, the other metrics have no unit system.
+func measurementInUnits(measurement *store.BodyMeasurement, u units.Units) *store.BodyMeasurement {
+	if u.IsCanonical() {
+		return measurement
+	}
+	converted := *measurement
+	converted.BodyWeight = convertWeight(measurement.BodyWeight, u.FromKilograms)
+	if trend, ok := measurement.Trend[store.MetricBodyWeight]; ok {
+		converted.Trend = maps.Clone(measurement.Trend)
+		converted.Trend[store.MetricBodyWeight] = u.FromKilograms(trend)
+	}
+	return &converted
+}
+
+// measurementsInUnits applies measurementInUnits to every measurement.
+func measurementsInUnits(measurements []*store.BodyMeasurement, u units.Units) []*store.BodyMeasurement {
+	if u.IsCanonical() {
+		return measurements
+	}
+	converted := make([]*store.BodyMeasurement, len(measurements))
+	for i, measurement := range measurements {
+		converted[i] = measurementInUnits(measurement, u)
+	}
+	return converted
+}
+
+// goalInUnits returns a copy of a body weight goal with its values converted
+// from kg to u, goals on other metrics are returned as they are.
+func goalInUnits(goal *store.BodyGoal, u units.Units) *store.BodyGoal {
+	if u.IsCanonical() || goal.Metric != store.MetricBodyWeight {
+		return goal
+	}
+	converted := *goal
+	converted.Target = u.FromKilograms(goal.Target)
+	converted.StartValue = convertWeight(goal.StartValue, u.FromKilograms)
+	converted.Current = convertWeight(goal.Current, u.FromKilograms)
+	converted.Remaining = convertWeight(goal.Remaining, u.FromKilograms)
+	return &converted
+}
+
+// goalsInUnits applies goalInUnits to every goal.
+func goalsInUnits(goals []*store.BodyGoal, u units.Units) []*store.BodyGoal {
+	if u.IsCanonical() {
+		return goals
+	}
+	converted := make([]*store.BodyGoal, len(goals))
+	for i, goal := range goals {
+		converted[i] = goalInUnits(goal, u)
+	}
+	return converted
+}
+
+// entriesFromUnits converts the weights of entries sent in u to kg in place.
+// existing holds the entries as stored, a weight sent back as the client was
+// shown it keeps its stored value instead of drifting by the rounding of the
+// conversion.
+func entriesFromUnits(entries, existing []store.WorkoutEntry, u units.Units) {
+	byID := make(map[int]*store.WorkoutEntry, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+	}
+	for i := range entries {
+		var old *store.WorkoutEntry
+		if entries[i].ID != 0 {
+			old = byID[entries[i].ID]
+		}
+		entryFromUnits(&entries[i], old, u)
+	}
+}
+
+// entryFromUnits is entriesFromUnits for a single entry, old is nil for a new
+// entry.
+func entryFromUnits(entry, old *store.WorkoutEntry, u units.Units) {
+	if u.IsCanonical() {
+		return
+	}
+	var stored *float64
+	if old != nil {
+		stored = old.Weight
+	}
+	entry.Weight = weightFromUnits(entry.Weight, stored, u)
+	for i := range entry.SetList {
+		stored = nil
+		if old != nil && i < len(old.SetList) {
+			stored = old.SetList[i].Weight
+		}
+		entry.SetList[i].Weight = weightFromUnits(entry.SetList[i].Weight, stored, u)
+	}
+}
+
+// templateEntriesFromUnits is entriesFromUnits for the entries of a template.
+func templateEntriesFromUnits(entries, existing []store.TemplateEntry, u units.Units) {
+	if u.IsCanonical() {
+		return
+	}
+	byID := make(map[int]*store.TemplateEntry, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+	}
+	for i := range entries {
+		entry := &entries[i]
+		var storedMin, storedMax *float64
+		if old := byID[entry.ID]; entry.ID != 0 && old != nil {
+			storedMin, storedMax = old.WeightMin, old.WeightMax
+		}
+		entry.WeightMin = weightFromUnits(entry.WeightMin, storedMin, u)
+		entry.WeightMax = weightFromUnits(entry.WeightMax, storedMax, u)
+	}
+}
+
+// programWeeksFromUnits converts the amounts of the linear progressions of
+// weeks sent in u to kg in place. existing holds the weeks as stored, an
+// amount sent back as shown on the same day keeps its stored value.
+func programWeeksFromUnits(weeks, existing []store.ProgramWeek, u units.Units) {
+	if u.IsCanonical() {
+		return
+	}
+	stored := map[int]float64{}
+	for _, week := range existing {
+		for _, day := range week.Days {
+			if day.Progression.Type == store.ProgressionLinear {
+				stored[day.ID] = day.Progression.Amount
+			}
+		}
+	}
+	for i := range weeks {
+		for j := range weeks[i].Days {
+			day := &weeks[i].Days[j]
+			if day.Progression.Type != store.ProgressionLinear {
+				continue
+			}
+			var old *float64
+			if amount, ok := stored[day.ID]; ok && day.ID != 0 {
+				old = &amount
+			}
+			day.Progression.Amount = *weightFromUnits(&day.Progression.Amount, old, u)
+		}
+	}
+}
+
+// weightFromUnits converts a weight sent in u to kg, keeping the stored weight
+// when the client sent it back unchanged.
+func weightFromUnits(weight, stored *float64, u units.Units) *float64 {
+	if weight != nil && stored != nil && *weight == u.FromKilograms(*stored) {
+		kg := *stored
+		return &kg
+	}
+	return convertWeight(weight, u.ToKilograms)
+}
+
+// convertWeight converts an optional weight into a new pointer, so weights
+// shared between an entry and its sets are not converted twice.
+func convertWeight(weight *float64, convert func(float64) float64) *float64 {
+	if weight == nil {
+		return nil
+	}
+	converted := convert(*weight)
+	return &converted
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/units"
+	"github.com/stretchr/testify/assert"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestWorkoutInUnits(t *testing.T) {
+	// the summary and the set share a pointer, as prepared entries can
+	weight := floatPtr(100)
+	workout := &store.Workout{
+		Entries: []store.WorkoutEntry{
+			{ID: 1, ExerciseName: "Squat", Weight: weight, SetList: []store.WorkoutSet{{SetNumber: 1, Weight: weight}}},
+			{ID: 2, ExerciseName: "Plank"},
+		},
+		NewRecords: []store.PersonalRecord{
+			{Type: store.RecordHeaviestWeight, Value: 100, Weight: weight},
+			{Type: store.RecordMostReps, Value: 5, Weight: weight},
+		},
+	}
+
+	converted := workoutInUnits(workout, units.Of(units.Imperial))
+	assert.Equal(t, floatPtr(220.5), converted.Entries[0].Weight)
+	assert.Equal(t, floatPtr(220.5), converted.Entries[0].SetList[0].Weight)
+	assert.Nil(t, converted.Entries[1].Weight)
+	assert.Equal(t, 220.5, converted.NewRecords[0].Value)
+	assert.Equal(t, 5.0, converted.NewRecords[1].Value)
+	assert.Equal(t, floatPtr(220.5), converted.NewRecords[1].Weight)
+
+	// the stored workout is left in kg
+	assert.Equal(t, floatPtr(100), workout.Entries[0].Weight)
+	assert.Equal(t, floatPtr(100), workout.Entries[0].SetList[0].Weight)
+	assert.Equal(t, 100.0, workout.NewRecords[0].Value)
+
+	assert.Same(t, workout, workoutInUnits(workout, units.Of(units.Metric)))
+}
+
+func TestPlannedInUnits(t *testing.T) {
+	planned := &store.PlannedWorkout{Entries: []store.PlannedEntry{
+		{ExerciseName: "Squat", Weight: floatPtr(100)},
+		{ExerciseName: "Plank"},
+	}}
+
+	converted := plannedInUnits(planned, units.Of(units.Imperial))
+	assert.Equal(t, floatPtr(220.5), converted.Entries[0].Weight)
+	assert.Nil(t, converted.Entries[1].Weight)
+	assert.Equal(t, floatPtr(100), planned.Entries[0].Weight)
+
+	assert.Same(t, planned, plannedInUnits(planned, units.Of(units.Metric)))
+}
+
+func TestRecordsInUnits(t *testing.T) {
+	records := []*store.PersonalRecord{
+		{Type: store.RecordEstimated1RM, Value: 116.67, Weight: floatPtr(100), Reps: intPtr(5), BodyWeight: floatPtr(80), Wilks: floatPtr(80.5)},
+		{Type: store.RecordMostReps, Value: 12, Weight: floatPtr(60), Reps: intPtr(12)},
+	}
+
+	converted := recordsInUnits(records, units.Of(units.Imperial))
+	assert.Equal(t, 257.2, converted[0].Value)
+	assert.Equal(t, floatPtr(220.5), converted[0].Weight)
+	assert.Equal(t, floatPtr(176.4), converted[0].BodyWeight)
+	assert.Equal(t, floatPtr(80.5), converted[0].Wilks)
+	// reps stay reps
+	assert.Equal(t, 12.0, converted[1].Value)
+	assert.Equal(t, floatPtr(132.3), converted[1].Weight)
+	assert.Equal(t, 116.67, records[0].Value)
+
+	assert.Equal(t, records, recordsInUnits(records, units.Of(units.Metric)))
+}
+
+func TestVolumeInUnits(t *testing.T) {
+	series := []*store.VolumeSeries{{Key: "total", Points: []store.VolumePoint{
+		{Tonnage: 1000, Sets: 10, MovingAverage: 1000},
+		{Tonnage: 1500, Sets: 15, Change: floatPtr(500), ChangePercent: floatPtr(50), MovingAverage: 1250},
+	}}}
+
+	converted := volumeInUnits(series, units.Of(units.Imperial))
+	assert.Equal(t, "total", converted[0].Key)
+	assert.Equal(t, 2204.6, converted[0].Points[0].Tonnage)
+	assert.Nil(t, converted[0].Points[0].Change)
+	assert.Equal(t, floatPtr(1102.3), converted[0].Points[1].Change)
+	assert.Equal(t, floatPtr(50), converted[0].Points[1].ChangePercent)
+	assert.Equal(t, 2755.8, converted[0].Points[1].MovingAverage)
+	assert.Equal(t, 15, converted[0].Points[1].Sets)
+	assert.Equal(t, 1000.0, series[0].Points[0].Tonnage)
+
+	assert.Equal(t, series, volumeInUnits(series, units.Of(units.Metric)))
+}
+
+func TestProgramInUnits(t *testing.T) {
+	imperial := units.Of(units.Imperial)
+	program := &store.Program{Weeks: []store.ProgramWeek{{WeekNumber: 1, Days: []store.ProgramDay{
+		{ID: 1, DayNumber: 1, Progression: store.Progression{Type: store.ProgressionLinear, Amount: 2.5}},
+		{ID: 2, DayNumber: 3, Progression: store.Progression{Type: store.ProgressionPercent, Amount: 75}},
+	}}}}
+
+	converted := programInUnits(program, imperial)
+	assert.Equal(t, 5.5, converted.Weeks[0].Days[0].Progression.Amount)
+	assert.Equal(t, 75.0, converted.Weeks[0].Days[1].Progression.Amount)
+	assert.Equal(t, 2.5, program.Weeks[0].Days[0].Progression.Amount)
+	assert.Same(t, program, programInUnits(program, units.Of(units.Metric)))
+
+	// an amount sent back as shown keeps its stored value, new ones convert
+	weeks := []store.ProgramWeek{{WeekNumber: 1, Days: []store.ProgramDay{
+		{ID: 1, DayNumber: 1, Progression: store.Progression{Type: store.ProgressionLinear, Amount: 5.5}},
+		{DayNumber: 2, Progression: store.Progression{Type: store.ProgressionLinear, Amount: 5}},
+		{ID: 2, DayNumber: 3, Progression: store.Progression{Type: store.ProgressionPercent, Amount: 75}},
+	}}}
+	programWeeksFromUnits(weeks, program.Weeks, imperial)
+	assert.Equal(t, 2.5, weeks[0].Days[0].Progression.Amount)
+	assert.Equal(t, 2.27, weeks[0].Days[1].Progression.Amount)
+	assert.Equal(t, 75.0, weeks[0].Days[2].Progression.Amount)
+}
+
+func TestEnrollmentInUnits(t *testing.T) {
+	enrollment := &store.Enrollment{
+		TrainingMaxes: map[string]float64{"Squat": 140},
+		Sessions: []*store.PlannedWorkout{
+			{Entries: []store.PlannedEntry{{ExerciseName: "Squat", Weight: floatPtr(100)}}},
+		},
+	}
+
+	converted := enrollmentInUnits(enrollment, units.Of(units.Imperial))
+	assert.Equal(t, map[string]float64{"Squat": 308.6}, converted.TrainingMaxes)
+	assert.Equal(t, floatPtr(220.5), converted.Sessions[0].Entries[0].Weight)
+	assert.Equal(t, 140.0, enrollment.TrainingMaxes["Squat"])
+	assert.Equal(t, floatPtr(100), enrollment.Sessions[0].Entries[0].Weight)
+
+	assert.Same(t, enrollment, enrollmentInUnits(enrollment, units.Of(units.Metric)))
+}
+
+func TestBodyInUnits(t *testing.T) {
+	imperial := units.Of(units.Imperial)
+	measurement := &store.BodyMeasurement{
+		BodyWeight: floatPtr(100),
+		Waist:      floatPtr(90),
+		Trend:      map[string]float64{store.MetricBodyWeight: 100, store.MetricWaist: 90},
+	}
+	converted := measurementInUnits(measurement, imperial)
+	assert.Equal(t, floatPtr(220.5), converted.BodyWeight)
+	assert.Equal(t, floatPtr(90), converted.Waist)
+	assert.Equal(t, map[string]float64{store.MetricBodyWeight: 220.5, store.MetricWaist: 90}, converted.Trend)
+	assert.Equal(t, 100.0, measurement.Trend[store.MetricBodyWeight])
+
+	goal := &store.BodyGoal{Metric: store.MetricBodyWeight, Target: 90, StartValue: floatPtr(100), Current: floatPtr(95), Remaining: floatPtr(-5)}
+	convertedGoal := goalInUnits(goal, imperial)
+	assert.Equal(t, 198.4, convertedGoal.Target)
+	assert.Equal(t, floatPtr(220.5), convertedGoal.StartValue)
+	assert.Equal(t, floatPtr(-11), convertedGoal.Remaining)
+	assert.Equal(t, 90.0, goal.Target)
+
+	waistGoal := &store.BodyGoal{Metric: store.MetricWaist, Target: 80}
+	assert.Same(t, waistGoal, goalInUnits(waistGoal, imperial))
+}
+
+func TestEntriesFromUnits(t *testing.T) {
+	existing := []store.WorkoutEntry{
+		{ID: 1, ExerciseName: "Squat", Weight: floatPtr(102.5), SetList: []store.WorkoutSet{{SetNumber: 1, Weight: floatPtr(102.5)}}},
+	}
+	entries := []store.WorkoutEntry{
+		// sent back as shown, 226 lb would be stored as 102.51 kg
+		{ID: 1, ExerciseName: "Squat", Weight: floatPtr(226), SetList: []store.WorkoutSet{{SetNumber: 1, Weight: floatPtr(226)}, {SetNumber: 2, Weight: floatPtr(225)}}},
+		{ExerciseName: "Bench Press", Weight: floatPtr(135)},
+	}
+
+	entriesFromUnits(entries, existing, units.Of(units.Imperial))
+	assert.Equal(t, floatPtr(102.5), entries[0].Weight)
+	assert.Equal(t, floatPtr(102.5), entries[0].SetList[0].Weight)
+	assert.Equal(t, floatPtr(102.06), entries[0].SetList[1].Weight)
+	assert.Equal(t, floatPtr(61.23), entries[1].Weight)
+
+	metric := []store.WorkoutEntry{{ExerciseName: "Squat", Weight: floatPtr(100)}}
+	entriesFromUnits(metric, nil, units.Of(units.Metric))
+	assert.Equal(t, floatPtr(100), metric[0].Weight)
+}
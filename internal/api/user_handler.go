@@ -7,11 +7,19 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"strings"
+	"time"
 
+	"github.com/makhammatovb/femProject/internal/mailer"
+	"github.com/makhammatovb/femProject/internal/middleware"
 	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/tokens"
+	"github.com/makhammatovb/femProject/internal/units"
 	"github.com/makhammatovb/femProject/internal/utils"
 )
 
+var emailRX = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
+
 type registerUserRequest struct {
 	Username string `json:"username"`
 	Email     string `json:"email"`
@@ -19,39 +27,83 @@ type registerUserRequest struct {
 	BIO       string `json:"bio"`
 }
 
+type tokenRequest struct {
+	Token string `json:"token"`
+}
+
+// publicUser is the part of a user shown to everyone but the user themselves.
+type publicUser struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	BIO       string    `json:"bio"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newPublicUser(user *store.User) *publicUser {
+	return &publicUser{
+		ID:        user.ID,
+		Username:  user.Username,
+		BIO:       user.BIO,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
 // UserHandler struct to handle User-related requests for future use
 type UserHandler struct {
-	userStore store.UserStore
-	logger    *log.Logger
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	logger     *log.Logger
 }
 
 // NewUserHandler creates a new instance of UserHandler.
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, tokenStore store.TokenStore, mailer mailer.Mailer, logger *log.Logger) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
-		logger:    logger,
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     mailer,
+		logger:     logger,
 	}
 }
 
-func (uh *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
-	if req.Username == "" || req.Email == "" {
-		return errors.New("missing required fields")
-	}
+// validateRegisterRequest returns what is wrong with each invalid field,
+// keyed by its JSON name.
+func (uh *UserHandler) validateRegisterRequest(req *registerUserRequest) map[string]string {
+	fieldErrors := map[string]string{}
 
-	if len(req.Email) > 255 {
-		return errors.New("email is too long")
+	if req.Username == "" {
+		fieldErrors["username"] = "must be provided"
+	} else if len(req.Username) > 255 {
+		fieldErrors["username"] = "must not be more than 255 characters long"
 	}
 
-	emailRegex := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
-	if !emailRegex.MatchString(req.Email) {
-		return errors.New("invalid email format")
+	if msg := validateEmail(req.Email); msg != "" {
+		fieldErrors["email"] = msg
 	}
 
 	if req.Password == "" {
-		return errors.New("missing required fields")
+		fieldErrors["password"] = "must be provided"
 	}
 
-	return nil
+	return fieldErrors
+}
+
+// validateEmail returns what is wrong with an email address, or an empty
+// string when it is valid.
+func validateEmail(email string) string {
+	switch {
+	case email == "":
+		return "must be provided"
+	case len(email) > 255:
+		return "must not be more than 255 characters long"
+	case !emailRX.MatchString(email):
+		return "must be a valid email address"
+	}
+	return ""
 }
 
 func (uh *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request) {
@@ -60,19 +112,19 @@ func (uh *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		uh.logger.Println("error while decoding user:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request payload"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	err = uh.validateRegisterRequest(&req)
-	if err != nil {
-		uh.logger.Println("error while validating user:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+	fieldErrors := uh.validateRegisterRequest(&req)
+	if len(fieldErrors) > 0 {
+		failedValidationResponse(w, r, fieldErrors)
 		return
 	}
 
 	user := &store.User{
-		Email: req.Email,
+		Username: req.Username,
+		Email:    req.Email,
 	}
 
 	if req.BIO != "" {
@@ -81,21 +133,74 @@ func (uh *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request
 
 	err = user.PasswordHash.Set(req.Password)
 	if err != nil {
-		uh.logger.Println("error while hashing password:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		serverErrorResponse(uh.logger, w, r, err)
 		return
 	}
 
 	err = uh.userStore.CreateUser(user)
 	if err != nil {
-		uh.logger.Println("error while creating user:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(uh.logger, w, r, err)
 		return
 	}
+
+	// the account exists at this point, a failed email is logged rather than failing the registration
+	err = uh.sendVerificationEmail(user, user.Email, tokens.ScopeActivation, tokens.ActivationTTL)
+	if err != nil {
+		uh.logger.Println("error while sending activation email:", err)
+	}
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"user": user})
 
 }
 
+// HandleActivateUser consumes an activation token and activates the account
+// it was issued for.
+func (uh *UserHandler) HandleActivateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := uh.readUserFromToken(w, r, tokens.ScopeActivation)
+	if !ok {
+		return
+	}
+
+	user.Activated = true
+	err := uh.userStore.UpdateUser(user)
+	if err != nil {
+		storeErrorResponse(uh.logger, w, r, err)
+		return
+	}
+	err = uh.tokenStore.DeleteAllTokenForUser(int64(user.ID), tokens.ScopeActivation)
+	if err != nil {
+		storeErrorResponse(uh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+}
+
+// HandleConfirmEmail consumes an email change token and replaces the user's
+// email with the pending address the token was sent to.
+func (uh *UserHandler) HandleConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := uh.readUserFromToken(w, r, tokens.ScopeEmailChange)
+	if !ok {
+		return
+	}
+	if user.PendingEmail == "" {
+		errorResponse(w, r, http.StatusUnprocessableEntity, "There is no pending email change")
+		return
+	}
+
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	err := uh.userStore.UpdateUser(user)
+	if err != nil {
+		storeErrorResponse(uh.logger, w, r, err)
+		return
+	}
+	err = uh.tokenStore.DeleteAllTokenForUser(int64(user.ID), tokens.ScopeEmailChange)
+	if err != nil {
+		storeErrorResponse(uh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": userInUnits(user, units.Of(user.Units))})
+}
+
 func (uh *UserHandler) HandleGetUserByID(w http.ResponseWriter, r *http.Request) {
 
 	// retrieves the user ID from the URL parameters
@@ -103,45 +208,74 @@ func (uh *UserHandler) HandleGetUserByID(w http.ResponseWriter, r *http.Request)
 	fmt.Println("USER ID:", userID)
 	if err != nil {
 		uh.logger.Println("Error reading user ID:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid user ID"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
 		return
 	}
 	user, err := uh.userStore.GetUserByID(userID)
 	if err != nil {
-		uh.logger.Println("Error getting user by ID:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(uh.logger, w, r, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+	// contact details, settings and body metrics are only shown to the user
+	// themselves
+	if int64(middleware.GetUser(r).ID) != userID {
+		if writeNotModified(w, r, utils.ETag(user.Version)) {
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": newPublicUser(user)})
+		return
+	}
+	if writeNotModified(w, r, utils.ETagIn(user.Version, u.Weight)) {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": userInUnits(user, u), "units": u})
 }
 
 func (uh *UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := utils.ReadIDParam(r)
 	if err != nil {
 		uh.logger.Println("Error reading user ID:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid user ID"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !uh.authorizeSelf(w, r, userID) {
 		return
 	}
 	existingUser, err := uh.userStore.GetUserByID(userID)
 	if err != nil {
-		uh.logger.Println("Error getting user by ID:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(uh.logger, w, r, err)
 		return
 	}
-	if existingUser == nil {
-		http.NotFound(w, r)
+	if !checkIfMatch(w, r, utils.ETagIn(existingUser.Version, u.Weight)) {
 		return
 	}
 	var updatedUserRequest struct {
 		Username     *string `json:"username"`
 		Email        *string `json:"email"`
-		PasswordHash *string `json:"password"`
+		Password     *string `json:"password"`
 		BIO          *string `json:"bio"`
+		BodyWeight   *float64 `json:"body_weight"`
+		Sex          *string  `json:"sex"`
+		Units        *string  `json:"units"`
 	}
 	err = json.NewDecoder(r.Body).Decode(&updatedUserRequest)
 	if err != nil {
 		uh.logger.Println("error while decoding user:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request payload"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	// passwords are only changed through the reset flow, which proves access to
+	// the email and logs out every session
+	if updatedUserRequest.Password != nil {
+		failedValidationResponse(w, r, map[string]string{"password": "cannot be changed here, use POST /password-reset"})
 		return
 	}
 	if updatedUserRequest.Username != nil {
@@ -150,31 +284,145 @@ func (uh *UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request)
 	if updatedUserRequest.BIO != nil {
 		existingUser.BIO = *updatedUserRequest.BIO
 	}
-	if updatedUserRequest.Email != nil {
-		existingUser.Email = *updatedUserRequest.Email
+	// the body weight is given in the units set by the same request, if any
+	if updatedUserRequest.Units != nil {
+		if !units.Valid(*updatedUserRequest.Units) {
+			failedValidationResponse(w, r, map[string]string{"units": "must be one of " + strings.Join(units.Systems, ", ")})
+			return
+		}
+		existingUser.Units = *updatedUserRequest.Units
+		u = units.Of(existingUser.Units)
+	}
+	if updatedUserRequest.BodyWeight != nil {
+		bodyWeight := weightFromUnits(updatedUserRequest.BodyWeight, existingUser.BodyWeight, u)
+		if *bodyWeight <= 0 || *bodyWeight >= 1000 {
+			failedValidationResponse(w, r, map[string]string{"body_weight": fmt.Sprintf("must be greater than 0 and less than %g %s", u.FromKilograms(1000), u.Weight)})
+			return
+		}
+		existingUser.BodyWeight = bodyWeight
+	}
+	// the sex picks the coefficients of the Wilks and DOTS scores of records
+	if updatedUserRequest.Sex != nil {
+		if *updatedUserRequest.Sex != "" && *updatedUserRequest.Sex != store.SexMale && *updatedUserRequest.Sex != store.SexFemale {
+			failedValidationResponse(w, r, map[string]string{"sex": "must be male, female or empty"})
+			return
+		}
+		existingUser.Sex = *updatedUserRequest.Sex
+	}
+	// a new email only replaces the current one after it has been verified
+	emailChanged := updatedUserRequest.Email != nil && *updatedUserRequest.Email != existingUser.Email
+	if emailChanged {
+		newEmail := *updatedUserRequest.Email
+		if msg := validateEmail(newEmail); msg != "" {
+			failedValidationResponse(w, r, map[string]string{"email": msg})
+			return
+		}
+		_, err := uh.userStore.GetUserByEmail(newEmail)
+		if err == nil {
+			storeErrorResponse(uh.logger, w, r, &store.ConflictError{Field: "email"})
+			return
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			storeErrorResponse(uh.logger, w, r, err)
+			return
+		}
+		existingUser.PendingEmail = newEmail
 	}
 	err = uh.userStore.UpdateUser(existingUser)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
 	if err != nil {
-		uh.logger.Println("Error updating user:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(uh.logger, w, r, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": existingUser})
+	if emailChanged {
+		// only the latest requested address can be confirmed
+		err = uh.tokenStore.DeleteAllTokenForUser(int64(existingUser.ID), tokens.ScopeEmailChange)
+		if err == nil {
+			err = uh.sendVerificationEmail(existingUser, existingUser.PendingEmail, tokens.ScopeEmailChange, tokens.EmailChangeTTL)
+		}
+		if err != nil {
+			serverErrorResponse(uh.logger, w, r, err)
+			return
+		}
+	}
+	w.Header().Set("ETag", utils.ETagIn(existingUser.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": userInUnits(existingUser, u), "units": u})
 }
 
 func (uh *UserHandler) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := utils.ReadIDParam(r)
 	if err != nil {
 		uh.logger.Println("Error reading user ID:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid user ID"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if !uh.authorizeSelf(w, r, userID) {
 		return
 	}
 
 	err = uh.userStore.DeleteUser(userID)
 	if err != nil {
-		uh.logger.Println("Error deleting user:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(uh.logger, w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusNoContent, nil)
 }
+
+// authorizeSelf checks that users only modify their own account and writes
+// the error response when they do not.
+func (uh *UserHandler) authorizeSelf(w http.ResponseWriter, r *http.Request, userID int64) bool {
+	currentUser := middleware.GetUser(r)
+	if int64(currentUser.ID) != userID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to modify this user")
+		return false
+	}
+	return true
+}
+
+// readUserFromToken decodes a {"token": ...} body and returns the user the
+// token was issued for, writing the error response when there is none.
+func (uh *UserHandler) readUserFromToken(w http.ResponseWriter, r *http.Request, scope string) (*store.User, bool) {
+	var req tokenRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" {
+		uh.logger.Println("error while decoding token:", err)
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+
+	user, err := uh.userStore.GetUserToken(scope, req.Token)
+	if errors.Is(err, store.ErrNotFound) {
+		errorResponse(w, r, http.StatusUnprocessableEntity, "Invalid or expired token")
+		return nil, false
+	}
+	if err != nil {
+		storeErrorResponse(uh.logger, w, r, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// sendVerificationEmail issues a token of the given scope and mails it to
+// address, which is the pending address for email changes.
+func (uh *UserHandler) sendVerificationEmail(user *store.User, address, scope string, ttl time.Duration) error {
+	token, err := uh.tokenStore.CreateNewToken(int64(user.ID), ttl, scope)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{To: address}
+	switch scope {
+	case tokens.ScopeActivation:
+		msg.Subject = "Activate your account"
+		msg.Body = fmt.Sprintf("Hi %s,\n\nThanks for signing up. Send a PUT request to /users/activated with the token below to activate your account:\n\n%s\n\nThe token expires in %s.\n",
+			user.Username, token.PlainText, ttl)
+	case tokens.ScopeEmailChange:
+		msg.Subject = "Confirm your new email address"
+		msg.Body = fmt.Sprintf("Hi %s,\n\nSend a PUT request to /users/email with the token below to start using this address:\n\n%s\n\nThe token expires in %s. If you did not ask for this change you can ignore this email.\n",
+			user.Username, token.PlainText, ttl)
+	}
+	return uh.mailer.Send(msg)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/makhammatovb/femProject/internal/mailer"
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGetUserByID(t *testing.T) {
+	tokenStore := newMemoryTokenStore()
+	userStore := newMemoryUserStore(tokenStore)
+	uh := NewUserHandler(userStore, tokenStore, mailer.NewMemoryMailer(), log.New(io.Discard, "", 0))
+
+	user := &store.User{
+		Username:     "tester",
+		Email:        "tester@example.com",
+		PendingEmail: "new@example.com",
+		BIO:          "lifts things",
+		Activated:    true,
+		BodyWeight:   floatPtr(80),
+		Sex:          "female",
+		Units:        "imperial",
+	}
+	require.NoError(t, userStore.CreateUser(user))
+	other := &store.User{Username: "other", Email: "other@example.com"}
+	require.NoError(t, userStore.CreateUser(other))
+
+	getUser := func(caller *store.User) map[string]any {
+		router := chi.NewRouter()
+		router.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			uh.HandleGetUserByID(w, middleware.SetUser(r, caller))
+		})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			User map[string]any `json:"user"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.User
+	}
+
+	self := getUser(user)
+	for _, field := range []string{"email", "pending_email", "activated", "body_weight", "sex", "units"} {
+		assert.Contains(t, self, field)
+	}
+
+	for _, caller := range []*store.User{other, store.AnonymousUser} {
+		public := getUser(caller)
+		assert.Equal(t, "tester", public["username"])
+		assert.Equal(t, "lifts things", public["bio"])
+		for _, field := range []string{"email", "pending_email", "activated", "body_weight", "sex", "units"} {
+			assert.NotContains(t, public, field)
+		}
+	}
+}
+
+func TestHandleUpdateUserBodyWeightInUnits(t *testing.T) {
+	tokenStore := newMemoryTokenStore()
+	userStore := newMemoryUserStore(tokenStore)
+	uh := NewUserHandler(userStore, tokenStore, mailer.NewMemoryMailer(), log.New(io.Discard, "", 0))
+	require.NoError(t, userStore.CreateUser(&store.User{Username: "tester", Email: "tester@example.com", Units: "metric"}))
+
+	updateUser := func(query, etag, body string) (*httptest.ResponseRecorder, *store.User) {
+		caller, err := userStore.GetUserByID(1)
+		require.NoError(t, err)
+		router := chi.NewRouter()
+		router.Put("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			uh.HandleUpdateUser(w, middleware.SetUser(r, caller))
+		})
+		r := httptest.NewRequest(http.MethodPut, "/users/1"+query, strings.NewReader(body))
+		r.Header.Set("If-Match", etag)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+
+		stored, err := userStore.GetUserByID(1)
+		require.NoError(t, err)
+		return rr, stored
+	}
+
+	// the units sent in the same request apply to the body weight
+	rr, stored := updateUser("", utils.ETagIn(1, "kg"), `{"units": "imperial", "body_weight": 176.4}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, floatPtr(80.01), stored.BodyWeight)
+	assert.Equal(t, utils.ETagIn(2, "lb"), rr.Header().Get("ETag"))
+	var response struct {
+		User store.User `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, floatPtr(176.4), response.User.BodyWeight)
+
+	// sent back as shown it keeps its stored value
+	rr, stored = updateUser("", utils.ETagIn(2, "lb"), `{"body_weight": 176.4, "bio": "lifts"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, floatPtr(80.01), stored.BodyWeight)
+
+	// the units query parameter overrides the preference
+	rr, stored = updateUser("?units=metric", utils.ETagIn(3, "kg"), `{"body_weight": 82.5}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, floatPtr(82.5), stored.BodyWeight)
+
+	rr, _ = updateUser("", utils.ETagIn(4, "lb"), `{"body_weight": 2300}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "less than 2204.6 lb")
+}
+
+func TestHandleUpdateUserRejectsPassword(t *testing.T) {
+	tokenStore := newMemoryTokenStore()
+	userStore := newMemoryUserStore(tokenStore)
+	uh := NewUserHandler(userStore, tokenStore, mailer.NewMemoryMailer(), log.New(io.Discard, "", 0))
+	user := &store.User{Username: "tester", Email: "tester@example.com"}
+	require.NoError(t, user.PasswordHash.Set("old-password"))
+	require.NoError(t, userStore.CreateUser(user))
+
+	router := chi.NewRouter()
+	router.Put("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		uh.HandleUpdateUser(w, middleware.SetUser(r, user))
+	})
+	r := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"bio": "lifts", "password": "new-password"}`))
+	r.Header.Set("If-Match", utils.ETagIn(1, "kg"))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, r)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "/password-reset")
+	stored, err := userStore.GetUserByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Version)
+	matches, err := stored.PasswordHash.Matches("old-password")
+	require.NoError(t, err)
+	assert.True(t, matches)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// HandleCreateWorkoutEntry handles the POST request adding a single entry to a
+// workout. Without an order_index the entry is appended. Like the other entry
+// changes it requires If-Match with the ETag of the workout and answers with
+// the new one.
+func (wh *WorkoutHandler) HandleCreateWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(workout.Version, u.Weight)) {
+		return
+	}
+
+	var entry store.WorkoutEntry
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		wh.logger.Println("error while decoding workout entry:", err)
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	entry.ID = 0
+	entryFromUnits(&entry, nil, u)
+
+	v := validator.New()
+	store.ValidateWorkoutEntry(v, "", &entry)
+	v.Check(entry.OrderIndex >= 0, "order_index", "must not be negative")
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version, err := wh.workoutStore.AddWorkoutEntry(workoutID, workout.Version, &entry)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(version, u.Weight))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"entry": entryInUnits(entry, u), "units": u})
+}
+
+// HandleUpdateWorkoutEntry handles the PATCH request changing a single entry.
+// The body is a JSON merge patch or a JSON patch, so reps can be cleared by
+// sending null or removing it. The patch applies to the entry as shown in the
+// units of the request.
+func (wh *WorkoutHandler) HandleUpdateWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workoutID, entryID, ok := readEntryParams(w, r)
+	if !ok {
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(workout.Version, u.Weight)) {
+		return
+	}
+	var existing *store.WorkoutEntry
+	for i := range workout.Entries {
+		if int64(workout.Entries[i].ID) == entryID {
+			existing = &workout.Entries[i]
+		}
+	}
+	if existing == nil {
+		storeErrorResponse(wh.logger, w, r, store.ErrNotFound)
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	original, err := json.Marshal(entryInUnits(*existing, u))
+	if err != nil {
+		serverErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	patched, status, err := applyPatch(r.Header.Get("Content-Type"), original, patch)
+	if err != nil {
+		if status == http.StatusUnsupportedMediaType {
+			w.Header().Set("Accept-Patch", mergePatchMediaType+", "+jsonPatchMediaType)
+		}
+		errorResponse(w, r, status, err.Error())
+		return
+	}
+	var entry store.WorkoutEntry
+	err = json.Unmarshal(patched, &entry)
+	if err != nil {
+		errorResponse(w, r, http.StatusUnprocessableEntity, "The patch does not produce a valid entry")
+		return
+	}
+	// the position changes through the order endpoint only
+	entry.ID = existing.ID
+	entry.OrderIndex = existing.OrderIndex
+	entry.CreatedAt = existing.CreatedAt
+	entryFromUnits(&entry, existing, u)
+	store.ReconcileSetList(existing, &entry)
+
+	v := validator.New()
+	store.ValidateWorkoutEntry(v, "", &entry)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	relinkRenamedEntry(existing, &entry)
+
+	version, err := wh.workoutStore.UpdateWorkoutEntry(workoutID, workout.Version, &entry)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"entry": entryInUnits(entry, u), "units": u})
+}
+
+// HandleDeleteWorkoutEntry handles the DELETE request removing a single entry,
+// the entries after it move up by one.
+func (wh *WorkoutHandler) HandleDeleteWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workoutID, entryID, ok := readEntryParams(w, r)
+	if !ok {
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(workout.Version, u.Weight)) {
+		return
+	}
+
+	version, err := wh.workoutStore.DeleteWorkoutEntry(workoutID, workout.Version, entryID)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(version, u.Weight))
+	utils.WriteJSON(w, http.StatusNoContent, nil)
+}
+
+// HandleReorderWorkoutEntries handles the PUT request setting the order of all
+// entries of a workout at once.
+func (wh *WorkoutHandler) HandleReorderWorkoutEntries(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(workout.Version, u.Weight)) {
+		return
+	}
+
+	var input struct {
+		EntryIDs []int64 `json:"entry_ids"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if input.EntryIDs == nil {
+		failedValidationResponse(w, r, map[string]string{"entry_ids": "must be provided"})
+		return
+	}
+
+	_, err = wh.workoutStore.ReorderWorkoutEntries(workoutID, workout.Version, input.EntryIDs)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if errors.Is(err, store.ErrInvalidEntryOrder) {
+		failedValidationResponse(w, r, map[string]string{"entry_ids": "must list every entry of the workout exactly once"})
+		return
+	}
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+
+	workout, err = wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(workout.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workoutInUnits(workout, u), "units": u})
+}
+
+// readEntryParams reads the workout and entry IDs from the URL, answering 400
+// when either is malformed.
+func readEntryParams(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return 0, 0, false
+	}
+	entryID, err := utils.ReadInt64Param(r, "entryID")
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid entry ID")
+		return 0, 0, false
+	}
+	return workoutID, entryID, true
+}
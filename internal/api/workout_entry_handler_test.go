@@ -0,0 +1,68 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/makhammatovb/femProject/internal/middleware"
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutEntryChangesRequireIfMatch(t *testing.T) {
+	user := &store.User{ID: 1, Username: "tester", Units: "metric"}
+	workoutStore := newMemoryWorkoutStore(&store.Workout{
+		ID:      1,
+		UserID:  user.ID,
+		Title:   "Legs",
+		Entries: []store.WorkoutEntry{{ID: 1, ExerciseName: "Squat", Sets: 5, OrderIndex: 1}},
+	})
+	wh := NewWorkoutHandler(workoutStore, log.New(io.Discard, "", 0))
+
+	router := chi.NewRouter()
+	router.Post("/workouts/{id}/entries", func(w http.ResponseWriter, r *http.Request) {
+		wh.HandleCreateWorkoutEntry(w, middleware.SetUser(r, user))
+	})
+	router.Delete("/workouts/{id}/entries/{entryID}", func(w http.ResponseWriter, r *http.Request) {
+		wh.HandleDeleteWorkoutEntry(w, middleware.SetUser(r, user))
+	})
+	send := func(method, target, etag, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if etag != "" {
+			r.Header.Set("If-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+		return rr
+	}
+	entry := `{"exercise_name": "Lunge", "reps": 10, "sets": 3}`
+
+	rr := send(http.MethodPost, "/workouts/1/entries", "", entry)
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+
+	rr = send(http.MethodPost, "/workouts/1/entries", utils.ETagIn(1, "kg"), entry)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, utils.ETagIn(2, "kg"), rr.Header().Get("ETag"))
+
+	// the ETag the entry was added against is stale now
+	rr = send(http.MethodDelete, "/workouts/1/entries/1", utils.ETagIn(1, "kg"), "")
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	rr = send(http.MethodDelete, "/workouts/1/entries/1?units=imperial", utils.ETagIn(2, "kg"), "")
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	rr = send(http.MethodDelete, "/workouts/1/entries/1?units=imperial", utils.ETagIn(2, "lb"), "")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, utils.ETagIn(3, "lb"), rr.Header().Get("ETag"))
+
+	workout, err := workoutStore.GetWorkoutByID(1)
+	require.NoError(t, err)
+	require.Len(t, workout.Entries, 1)
+	assert.Equal(t, "Lunge", workout.Entries[0].ExerciseName)
+}
@@ -2,11 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/makhammatovb/femProject/internal/middleware"
 	"github.com/makhammatovb/femProject/internal/store"
 	"github.com/makhammatovb/femProject/internal/utils"
+	"github.com/makhammatovb/femProject/internal/validator"
 )
 
 // WorkoutHandler struct to handle workout-related requests for future use
@@ -30,67 +38,181 @@ func (wh *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Re
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
 		wh.logger.Println("Error reading workout ID:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid workout ID"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
 		return
 	}
 	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
 	if err != nil {
-		wh.logger.Println("Error workout by ID:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	if writeNotModified(w, r, utils.ETagIn(workout.Version, u.Weight)) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workoutInUnits(workout, u), "units": u})
+}
+
+// HandleListWorkouts handles the GET request to list the current user's
+// workouts one page at a time.
+func (wh *WorkoutHandler) HandleListWorkouts(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	qs := r.URL.Query()
+
+	filter := store.WorkoutFilter{
+		UserID:   currentUser.ID,
+		Title:    qs.Get("title"),
+		Exercise: qs.Get("exercise"),
+		Sort:     qs.Get("sort"),
+		Cursor:   qs.Get("cursor"),
+		Limit:    20,
+	}
+
+	fieldErrors := map[string]string{}
+	readInt := func(key string) *int {
+		value, err := utils.ReadIntQuery(r, key)
+		if err != nil {
+			fieldErrors[key] = err.Error()
+		}
+		return value
+	}
+	readTime := func(key string) *time.Time {
+		value, err := utils.ReadTimeQuery(r, key)
+		if err != nil {
+			fieldErrors[key] = err.Error()
+		}
+		return value
+	}
+
+	owner := readInt("owner")
+	limit := readInt("limit")
+	filter.MinDuration = readInt("min_duration")
+	filter.MaxDuration = readInt("max_duration")
+	filter.CreatedFrom = readTime("created_from")
+	filter.CreatedTo = readTime("created_to")
+	u := readUnitsQuery(r, fieldErrors)
+
+	if limit != nil {
+		if *limit < 1 || *limit > 100 {
+			fieldErrors["limit"] = "must be between 1 and 100"
+		}
+		filter.Limit = *limit
+	}
+	if filter.Sort != "" && !slices.Contains(store.WorkoutSortSafelist, filter.Sort) {
+		fieldErrors["sort"] = "must be one of " + strings.Join(store.WorkoutSortSafelist, ", ")
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "One or more query parameters are invalid", fieldErrors)
+		return
+	}
+
+	if owner != nil && *owner != currentUser.ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to list these workouts")
+		return
+	}
+
+	workouts, nextCursor, err := wh.workoutStore.ListWorkouts(filter)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			utils.WriteProblem(w, r, http.StatusBadRequest, "The cursor is invalid", map[string]string{"cursor": "must be a next_cursor value returned with the same sort"})
+			return
+		}
+		storeErrorResponse(wh.logger, w, r, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workouts": workoutsInUnits(workouts, u), "next_cursor": nextCursor, "units": u})
 }
 
 // HandleCreateWorkout handles the POST request to create a new workout.
 func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Request) {
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
 	var workout store.Workout
 	err := json.NewDecoder(r.Body).Decode(&workout)
 	if err != nil {
 		wh.logger.Println("Decoding error:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request payload"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if currentUser == nil || currentUser.IsAnonymous() {
+		errorResponse(w, r, http.StatusUnauthorized, "You must be logged in")
+		return
+	}
+	workout.UserID = currentUser.ID
+	// workouts are tied to a template by starting them from it and to a plan
+	// by completing a planned workout with them
+	workout.TemplateID = nil
+	workout.PlannedWorkoutID = nil
+	workout.AsPrescribed = nil
+	entriesFromUnits(workout.Entries, nil, u)
+	markEstimatedCalories(&workout, nil)
+
+	v := validator.New()
+	store.ValidateWorkout(v, &workout)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
 	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
 	if err != nil {
-		wh.logger.Println("Error creating workout:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(wh.logger, w, r, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+	w.Header().Set("ETag", utils.ETagIn(createdWorkout.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": workoutInUnits(createdWorkout, u), "units": u})
 }
 
 func (wh *WorkoutHandler) HandleUpdateWorkout(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
 		wh.logger.Println("Error reading workout ID:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid workout ID"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
 		return
 	}
 	existingWorkout, err := wh.workoutStore.GetWorkoutByID(workoutID)
 	if err != nil {
-		wh.logger.Println("Error getting workout by ID:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(wh.logger, w, r, err)
 		return
 	}
-	if existingWorkout == nil {
-		http.NotFound(w, r)
+	if !checkIfMatch(w, r, utils.ETagIn(existingWorkout.Version, u.Weight)) {
 		return
 	}
+	existingEntries := existingWorkout.Entries
+	previous := *existingWorkout
 	var updatedWorkoutRequest struct {
 		Title           *string              `json:"title"`
 		Description     *string              `json:"description"`
 		DurationMinutes *int                 `json:"duration_minutes"`
 		CaloriesBurned  *int                 `json:"calories_burned"`
+		SessionRPE      *float64             `json:"session_rpe"`
 		Entries         []store.WorkoutEntry `json:"entries"`
+		Blocks          []store.WorkoutBlock `json:"blocks"`
 	}
 	err = json.NewDecoder(r.Body).Decode(&updatedWorkoutRequest)
 	if err != nil {
 		wh.logger.Println("error while decoding workout:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request payload"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 	if updatedWorkoutRequest.Title != nil {
@@ -105,31 +227,278 @@ func (wh *WorkoutHandler) HandleUpdateWorkout(w http.ResponseWriter, r *http.Req
 	if updatedWorkoutRequest.CaloriesBurned != nil {
 		existingWorkout.CaloriesBurned = *updatedWorkoutRequest.CaloriesBurned
 	}
+	if updatedWorkoutRequest.SessionRPE != nil {
+		existingWorkout.SessionRPE = updatedWorkoutRequest.SessionRPE
+	}
 	if updatedWorkoutRequest.Entries != nil {
+		entriesFromUnits(updatedWorkoutRequest.Entries, existingEntries, u)
 		existingWorkout.Entries = updatedWorkoutRequest.Entries
 	}
+	if updatedWorkoutRequest.Blocks != nil {
+		existingWorkout.Blocks = updatedWorkoutRequest.Blocks
+	}
+
+	reconcileSetLists(existingEntries, existingWorkout.Entries)
+	markEstimatedCalories(existingWorkout, &previous)
+
+	v := validator.New()
+	store.ValidateWorkout(v, existingWorkout)
+	checkEntryIDs(v, existingEntries, existingWorkout.Entries)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	relinkRenamedEntries(existingEntries, existingWorkout.Entries)
 	err = wh.workoutStore.UpdateWorkout(existingWorkout)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
 	if err != nil {
-		wh.logger.Println("Error updating workout:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(wh.logger, w, r, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": existingWorkout})
+	w.Header().Set("ETag", utils.ETagIn(existingWorkout.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workoutInUnits(existingWorkout, u), "units": u})
+}
+
+// HandlePatchWorkout handles the PATCH request applying a JSON merge patch
+// (RFC 7396) or a JSON patch (RFC 6902) to a workout, picked by the
+// Content-Type of the request. The position of an entry in the patched
+// entries array becomes its order_index. The patch applies to the workout as
+// shown in the units of the request.
+func (wh *WorkoutHandler) HandlePatchWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Println("Error reading workout ID:", err)
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
+		return
+	}
+	existingWorkout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, utils.ETagIn(existingWorkout.Version, u.Weight)) {
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	original, err := json.Marshal(workoutInUnits(existingWorkout, u))
+	if err != nil {
+		serverErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	patched, status, err := applyPatch(r.Header.Get("Content-Type"), original, patch)
+	if err != nil {
+		if status == http.StatusUnsupportedMediaType {
+			w.Header().Set("Accept-Patch", mergePatchMediaType+", "+jsonPatchMediaType)
+		}
+		errorResponse(w, r, status, err.Error())
+		return
+	}
+
+	var workout store.Workout
+	err = json.Unmarshal(patched, &workout)
+	if err != nil {
+		errorResponse(w, r, http.StatusUnprocessableEntity, "The patch does not produce a valid workout")
+		return
+	}
+	// fields owned by the server cannot be patched
+	workout.ID = existingWorkout.ID
+	workout.UserID = existingWorkout.UserID
+	workout.TemplateID = existingWorkout.TemplateID
+	workout.PlannedWorkoutID = existingWorkout.PlannedWorkoutID
+	workout.AsPrescribed = existingWorkout.AsPrescribed
+	workout.Version = existingWorkout.Version
+	workout.CreatedAt = existingWorkout.CreatedAt
+	for i := range workout.Entries {
+		workout.Entries[i].OrderIndex = i + 1
+	}
+	entriesFromUnits(workout.Entries, existingWorkout.Entries, u)
+
+	reconcileSetLists(existingWorkout.Entries, workout.Entries)
+	markEstimatedCalories(&workout, existingWorkout)
+
+	v := validator.New()
+	store.ValidateWorkout(v, &workout)
+	checkEntryIDs(v, existingWorkout.Entries, workout.Entries)
+	if !v.Valid() {
+		failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	relinkRenamedEntries(existingWorkout.Entries, workout.Entries)
+	err = wh.workoutStore.UpdateWorkout(&workout)
+	if errors.Is(err, store.ErrEditConflict) {
+		preconditionFailedResponse(w, r)
+		return
+	}
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(workout.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workoutInUnits(&workout, u), "units": u})
 }
 
 func (wh *WorkoutHandler) HandleDeleteWorkout(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
 		wh.logger.Println("Error reading workout ID:", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid workout ID"})
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
 		return
 	}
 
 	err = wh.workoutStore.DeleteWorkout(workoutID)
 	if err != nil {
-		wh.logger.Println("Error deleting workout:", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		storeErrorResponse(wh.logger, w, r, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusNoContent, nil)
 }
+
+// markEstimatedCalories has the store estimate the calories of a workout when
+// the client left them out or sent zero, or kept an estimate as it was.
+// previous is the workout before the change, nil for a new workout.
+func markEstimatedCalories(workout, previous *store.Workout) {
+	workout.CaloriesEstimated = workout.CaloriesBurned == 0 ||
+		(previous != nil && previous.CaloriesEstimated && workout.CaloriesBurned == previous.CaloriesBurned)
+}
+
+// HandleEstimateCalories handles the POST request replacing the calories of a
+// workout with an estimate, also when they were measured.
+func (wh *WorkoutHandler) HandleEstimateCalories(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		errorResponse(w, r, http.StatusBadRequest, "Invalid workout ID")
+		return
+	}
+	u, ok := readUnits(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeOwner(w, r, workoutID) {
+		return
+	}
+
+	err = wh.workoutStore.EstimateCalories(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	w.Header().Set("ETag", utils.ETagIn(workout.Version, u.Weight))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workoutInUnits(workout, u), "units": u})
+}
+
+// HandleReestimateCalories handles the POST request estimating again the
+// calories of every workout of the current user that were estimated or left
+// out, as after a change of body weight. Measured calories are kept.
+func (wh *WorkoutHandler) HandleReestimateCalories(w http.ResponseWriter, r *http.Request) {
+	updated, err := wh.workoutStore.ReestimateCalories(middleware.GetUser(r).ID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"updated": updated})
+}
+
+// checkEntryIDs requires every updated entry that carries an ID to be one of
+// the existing entries and to be sent only once, new entries are sent without
+// an ID.
+func checkEntryIDs(v *validator.Validator, existing, updated []store.WorkoutEntry) {
+	known := make(map[int]bool, len(existing))
+	for _, entry := range existing {
+		known[entry.ID] = true
+	}
+	seen := make(map[int]bool, len(updated))
+	for i, entry := range updated {
+		key := fmt.Sprintf("entries[%d].id", i)
+		if entry.ID == 0 {
+			continue
+		}
+		v.Check(known[entry.ID], key, "must be the ID of an entry of this workout")
+		v.Check(!seen[entry.ID], key, "duplicate")
+		seen[entry.ID] = true
+	}
+}
+
+// relinkRenamedEntries applies relinkRenamedEntry to every updated entry that
+// already existed.
+func relinkRenamedEntries(existing, updated []store.WorkoutEntry) {
+	byID := make(map[int]*store.WorkoutEntry, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+	}
+	for i := range updated {
+		if old, ok := byID[updated[i].ID]; ok {
+			relinkRenamedEntry(old, &updated[i])
+		}
+	}
+}
+
+// reconcileSetLists applies store.ReconcileSetList to every updated entry that
+// already existed.
+func reconcileSetLists(existing, updated []store.WorkoutEntry) {
+	byID := make(map[int]*store.WorkoutEntry, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+	}
+	for i := range updated {
+		if old, ok := byID[updated[i].ID]; ok {
+			store.ReconcileSetList(old, &updated[i])
+		}
+	}
+}
+
+// relinkRenamedEntry drops the exercise link of an entry whose name changed
+// while it kept the old exercise_id, so the store links it to the exercise
+// matching the new name.
+func relinkRenamedEntry(old, entry *store.WorkoutEntry) {
+	if old.ExerciseName == entry.ExerciseName || old.ExerciseID == nil || entry.ExerciseID == nil {
+		return
+	}
+	if *old.ExerciseID == *entry.ExerciseID {
+		entry.ExerciseID = nil
+	}
+}
+
+// authorizeOwner checks that the user making the request owns the workout and
+// writes the error response when they do not.
+func (wh *WorkoutHandler) authorizeOwner(w http.ResponseWriter, r *http.Request, workoutID int64) bool {
+	currentUser := middleware.GetUser(r)
+	if currentUser == nil || currentUser.IsAnonymous() {
+		errorResponse(w, r, http.StatusUnauthorized, "You must be logged in")
+		return false
+	}
+
+	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutID)
+	if err != nil {
+		storeErrorResponse(wh.logger, w, r, err)
+		return false
+	}
+
+	if workoutOwner != currentUser.ID {
+		errorResponse(w, r, http.StatusForbidden, "You are not authorized to access this workout")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/store"
+	"github.com/makhammatovb/femProject/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEntryIDs(t *testing.T) {
+	existing := []store.WorkoutEntry{{ID: 1}, {ID: 2}}
+	tests := []struct {
+		name    string
+		updated []store.WorkoutEntry
+		want    map[string]string
+	}{
+		{"existing and new", []store.WorkoutEntry{{ID: 2}, {}, {ID: 1}, {}}, map[string]string{}},
+		{"unknown", []store.WorkoutEntry{{ID: 1}, {ID: 3}}, map[string]string{"entries[1].id": "must be the ID of an entry of this workout"}},
+		{"duplicate", []store.WorkoutEntry{{ID: 1}, {ID: 2}, {ID: 1}}, map[string]string{"entries[2].id": "duplicate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			checkEntryIDs(v, existing, tt.updated)
+			assert.Equal(t, tt.want, v.Errors)
+		})
+	}
+}
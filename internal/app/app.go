@@ -6,19 +6,32 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/makhammatovb/femProject/internal/api"
+	"github.com/makhammatovb/femProject/internal/mailer"
+	"github.com/makhammatovb/femProject/internal/middleware"
 	"github.com/makhammatovb/femProject/internal/store"
 	"github.com/makhammatovb/femProject/migrations"
 )
 
 // Application struct includes logger and handler from api package
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	DB             *sql.DB
+	Logger               *log.Logger
+	WorkoutHandler       *api.WorkoutHandler
+	TemplateHandler      *api.TemplateHandler
+	ProgramHandler       *api.ProgramHandler
+	ScheduleHandler      *api.ScheduleHandler
+	ExerciseHandler      *api.ExerciseHandler
+	RecordHandler        *api.RecordHandler
+	AnalyticsHandler     *api.AnalyticsHandler
+	SuggestionHandler    *api.SuggestionHandler
+	BodyHandler          *api.BodyHandler
+	UserHandler          *api.UserHandler
+	TokenHandler         *api.TokenHandler
+	PasswordResetHandler *api.PasswordResetHandler
+	Middleware           middleware.UserMiddleware
+	DB                   *sql.DB
 }
 
 // NewApplication creates a new instance of Application
@@ -34,23 +47,70 @@ func NewApplication() (*Application, error) {
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
 	workoutStore := store.NewPostgresWorkoutStore(pgDB)
+	templateStore := store.NewPostgresTemplateStore(pgDB)
+	programStore := store.NewPostgresProgramStore(pgDB)
+	scheduleStore := store.NewPostgresScheduleStore(pgDB)
+	exerciseStore := store.NewPostgresExerciseStore(pgDB)
+	recordStore := store.NewPostgresRecordStore(pgDB)
+	analyticsStore := store.NewPostgresAnalyticsStore(pgDB)
+	suggestionStore := store.NewPostgresSuggestionStore(pgDB)
+	bodyStore := store.NewPostgresBodyMeasurementStore(pgDB)
 	userStore := store.NewPostgresUserStore(pgDB)
 	tokenStore := store.NewPostgresTokenStore(pgDB)
+	emailSender := newMailer()
 
 	// Initialize handlers from api package, creates a new instance of WorkoutHandler and returns pointer to it
 	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
-	userHandler := api.NewUserHandler(userStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, suggestionStore, logger)
+	programHandler := api.NewProgramHandler(programStore, logger)
+	scheduleHandler := api.NewScheduleHandler(scheduleStore, workoutStore, logger)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, logger)
+	recordHandler := api.NewRecordHandler(recordStore, logger)
+	analyticsHandler := api.NewAnalyticsHandler(analyticsStore, logger)
+	suggestionHandler := api.NewSuggestionHandler(suggestionStore, logger)
+	bodyHandler := api.NewBodyHandler(bodyStore, logger)
+	userHandler := api.NewUserHandler(userStore, tokenStore, emailSender, logger)
 	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
+	passwordResetHandler := api.NewPasswordResetHandler(userStore, tokenStore, emailSender, logger)
+	middlewareHandler := middleware.UserMiddleware{UserStore: userStore}
 	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		DB:             pgDB,
+		Logger:               logger,
+		WorkoutHandler:       workoutHandler,
+		TemplateHandler:      templateHandler,
+		ProgramHandler:       programHandler,
+		ScheduleHandler:      scheduleHandler,
+		ExerciseHandler:      exerciseHandler,
+		RecordHandler:        recordHandler,
+		AnalyticsHandler:     analyticsHandler,
+		SuggestionHandler:    suggestionHandler,
+		BodyHandler:          bodyHandler,
+		UserHandler:          userHandler,
+		TokenHandler:         tokenHandler,
+		PasswordResetHandler: passwordResetHandler,
+		Middleware:           middlewareHandler,
+		DB:                   pgDB,
 	}
 	return app, nil
 }
 
+// newMailer sends emails over SMTP when SMTP_HOST is set and otherwise writes
+// them to MAIL_DIR (./tmp/mail by default) for local development
+func newMailer() mailer.Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./tmp/mail"
+		}
+		return mailer.NewFileMailer(dir)
+	}
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		port = 587
+	}
+	return mailer.NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_SENDER"))
+}
+
 // HealthCheck is a simple handler to check the health of the application
 func (a *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "Status is available")
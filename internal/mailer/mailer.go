@@ -0,0 +1,13 @@
+package mailer
+
+// Message is a plain text email sent to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails to users, the implementation is picked at startup.
+type Mailer interface {
+	Send(msg Message) error
+}
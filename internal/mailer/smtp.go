@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer delivers messages through an SMTP server.
+type SMTPMailer struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+}
+
+// NewSMTPMailer creates a new instance of SMTPMailer, authentication is
+// skipped when username is empty.
+func NewSMTPMailer(host string, port int, username, password, sender string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr:   fmt.Sprintf("%s:%d", host, port),
+		auth:   auth,
+		sender: sender,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.sender)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	err := smtp.SendMail(m.addr, m.auth, m.sender, []string{msg.To}, []byte(b.String()))
+	if err != nil {
+		return fmt.Errorf("mailer: send to %s: %w", msg.To, err)
+	}
+	return nil
+}
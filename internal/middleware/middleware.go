@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -17,6 +18,7 @@ type UserMiddleware struct {
 type contextKey string
 
 const UserContextKey = contextKey("user")
+const TokenContextKey = contextKey("token")
 
 func SetUser(r *http.Request, user *store.User) *http.Request {
 	ctx := context.WithValue(r.Context(), UserContextKey, user)
@@ -31,6 +33,13 @@ func GetUser(r *http.Request) *store.User {
 	return user
 }
 
+// GetToken returns the bearer token the request was authenticated with, or an
+// empty string for anonymous requests.
+func GetToken(r *http.Request) string {
+	token, _ := r.Context().Value(TokenContextKey).(string)
+	return token
+}
+
 func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -43,22 +52,36 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid auth header format"})
+			utils.WriteProblem(w, r, http.StatusUnauthorized, "invalid auth header format", nil)
 			return
 		}
 
 		token := headerParts[1]
 		user, err := um.UserStore.GetUserToken(tokens.ScopeAuthentication, token)
-		if err != nil {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
-			return 
+		if errors.Is(err, store.ErrNotFound) {
+			utils.WriteProblem(w, r, http.StatusUnauthorized, "invalid token", nil)
+			return
 		}
-		if user == nil {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
+		if err != nil {
+			utils.WriteProblem(w, r, http.StatusInternalServerError, "Internal server error", nil)
 			return
 		}
 
 		r = SetUser(r, user)
+		r = r.WithContext(context.WithValue(r.Context(), TokenContextKey, token))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireUser rejects requests made without a valid authentication token.
+func (um *UserMiddleware) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUser(r)
+		if user.IsAnonymous() {
+			utils.WriteProblem(w, r, http.StatusUnauthorized, "you must be logged in to access this route", nil)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -8,19 +8,86 @@ import (
 // SetupRoutes sets up the routes for the application using chi router
 func SetupRoutes(app *app.Application) *chi.Mux {
 	r := chi.NewRouter()
+	r.Use(app.Middleware.Authenticate)
 
-	r.Get("/health", app.HealthCheck)
-	r.Get("/workouts/{id}", app.WorkoutHandler.HandleGetWorkoutByID)
-	r.Post("/workouts/", app.WorkoutHandler.HandleCreateWorkout)
-	r.Put("/workouts/{id}/", app.WorkoutHandler.HandleUpdateWorkout)
-	r.Delete("/workouts/{id}/", app.WorkoutHandler.HandleDeleteWorkout)
+	// public routes
+	r.Group(func(r chi.Router) {
+		r.Get("/health", app.HealthCheck)
 
-	r.Get("/users/{id}", app.UserHandler.HandleGetUserByID) // checked
-	r.Post("/users/", app.UserHandler.HandleRegisterUser) // checked
-	r.Put("/users/{id}/", app.UserHandler.HandleUpdateUser) // checked
-	r.Delete("/users/{id}/", app.UserHandler.HandleDeleteUser) // checked
+		r.Get("/users/{id}", app.UserHandler.HandleGetUserByID)
+		r.Post("/users/", app.UserHandler.HandleRegisterUser)
+		r.Put("/users/activated", app.UserHandler.HandleActivateUser)
+		r.Put("/users/email", app.UserHandler.HandleConfirmEmail)
 
-	// tokens
-	r.Post("/tokens/", app.TokenHandler.HandleCreateToken)
+		// tokens
+		r.Post("/tokens/", app.TokenHandler.HandleCreateToken)
+		r.Post("/tokens/refresh", app.TokenHandler.HandleRefreshToken)
+
+		r.Post("/password-reset", app.PasswordResetHandler.HandleRequestPasswordReset)
+		r.Put("/password-reset", app.PasswordResetHandler.HandleResetPassword)
+	})
+
+	// routes that require an authenticated user
+	r.Group(func(r chi.Router) {
+		r.Use(app.Middleware.RequireUser)
+
+		r.Get("/workouts/", app.WorkoutHandler.HandleListWorkouts)
+		r.Get("/workouts/{id}", app.WorkoutHandler.HandleGetWorkoutByID)
+		r.Post("/workouts/", app.WorkoutHandler.HandleCreateWorkout)
+		r.Put("/workouts/{id}/", app.WorkoutHandler.HandleUpdateWorkout)
+		r.Patch("/workouts/{id}", app.WorkoutHandler.HandlePatchWorkout)
+		r.Delete("/workouts/{id}/", app.WorkoutHandler.HandleDeleteWorkout)
+		r.Post("/workouts/{id}/entries", app.WorkoutHandler.HandleCreateWorkoutEntry)
+		r.Put("/workouts/{id}/entries/order", app.WorkoutHandler.HandleReorderWorkoutEntries)
+		r.Patch("/workouts/{id}/entries/{entryID}", app.WorkoutHandler.HandleUpdateWorkoutEntry)
+		r.Delete("/workouts/{id}/entries/{entryID}", app.WorkoutHandler.HandleDeleteWorkoutEntry)
+		r.Post("/workouts/{id}/save-as-template", app.TemplateHandler.HandleSaveWorkoutAsTemplate)
+		r.Post("/workouts/{id}/estimate-calories", app.WorkoutHandler.HandleEstimateCalories)
+		r.Post("/workouts/estimate-calories", app.WorkoutHandler.HandleReestimateCalories)
+
+		r.Get("/templates/", app.TemplateHandler.HandleListTemplates)
+		r.Get("/templates/{id}", app.TemplateHandler.HandleGetTemplateByID)
+		r.Post("/templates/", app.TemplateHandler.HandleCreateTemplate)
+		r.Put("/templates/{id}/", app.TemplateHandler.HandleUpdateTemplate)
+		r.Delete("/templates/{id}/", app.TemplateHandler.HandleDeleteTemplate)
+		r.Post("/templates/{id}/start", app.TemplateHandler.HandleStartTemplate)
+
+		r.Get("/programs/", app.ProgramHandler.HandleListPrograms)
+		r.Get("/programs/{id}", app.ProgramHandler.HandleGetProgramByID)
+		r.Post("/programs/", app.ProgramHandler.HandleCreateProgram)
+		r.Put("/programs/{id}/", app.ProgramHandler.HandleUpdateProgram)
+		r.Delete("/programs/{id}/", app.ProgramHandler.HandleDeleteProgram)
+		r.Post("/programs/{id}/enroll", app.ProgramHandler.HandleEnroll)
+
+		r.Get("/me/schedule", app.ScheduleHandler.HandleGetSchedule)
+		r.Post("/me/schedule/{id}/complete", app.ScheduleHandler.HandleCompletePlannedWorkout)
+		r.Get("/me/suggestions", app.SuggestionHandler.HandleGetSuggestion)
+		r.Get("/me/load", app.AnalyticsHandler.HandleGetLoad)
+		r.Get("/me/body-measurements/", app.BodyHandler.HandleListMeasurements)
+		r.Get("/me/body-measurements/{id}", app.BodyHandler.HandleGetMeasurementByID)
+		r.Post("/me/body-measurements/", app.BodyHandler.HandleCreateMeasurement)
+		r.Put("/me/body-measurements/{id}/", app.BodyHandler.HandleUpdateMeasurement)
+		r.Delete("/me/body-measurements/{id}/", app.BodyHandler.HandleDeleteMeasurement)
+		r.Get("/me/body-goals/", app.BodyHandler.HandleListGoals)
+		r.Put("/me/body-goals/{metric}/", app.BodyHandler.HandleSetGoal)
+		r.Delete("/me/body-goals/{metric}/", app.BodyHandler.HandleDeleteGoal)
+
+		r.Get("/exercises/", app.ExerciseHandler.HandleSearchExercises)
+		r.Get("/exercises/{id}", app.ExerciseHandler.HandleGetExerciseByID)
+		r.Post("/exercises/", app.ExerciseHandler.HandleCreateExercise)
+		r.Put("/exercises/{id}/", app.ExerciseHandler.HandleUpdateExercise)
+		r.Delete("/exercises/{id}/", app.ExerciseHandler.HandleDeleteExercise)
+
+		r.Get("/analytics/volume", app.AnalyticsHandler.HandleGetVolume)
+
+		r.Get("/users/{id}/records", app.RecordHandler.HandleListRecords)
+		r.Get("/users/{id}/records/{exercise}", app.RecordHandler.HandleGetRecordHistory)
+		r.Put("/users/{id}/", app.UserHandler.HandleUpdateUser)
+		r.Delete("/users/{id}/", app.UserHandler.HandleDeleteUser)
+
+		r.Get("/tokens/", app.TokenHandler.HandleListSessions)
+		r.Delete("/tokens/", app.TokenHandler.HandleRevokeAllTokens)
+		r.Delete("/tokens/current", app.TokenHandler.HandleRevokeCurrentToken)
+	})
 	return r
 }
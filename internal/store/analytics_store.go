@@ -0,0 +1,152 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// VolumePeriods and VolumeGroupings hold the values accepted for
+// VolumeFilter.Period and VolumeFilter.GroupBy.
+var (
+	VolumePeriods   = []string{"day", "week", "month"}
+	VolumeGroupings = []string{"total", "exercise", "muscle"}
+)
+
+// VolumeFilter selects the workouts of a user summed up by GetVolume. From is
+// inclusive and To is exclusive, Window is the number of periods the moving
+// average spans.
+type VolumeFilter struct {
+	UserID  int
+	From    time.Time
+	To      time.Time
+	Period  string
+	GroupBy string
+	Window  int
+}
+
+// VolumePoint sums up the training of one period. Change compares the
+// tonnage with the period before, it is nil for the first period and
+// ChangePercent is nil when the period before had no tonnage.
+type VolumePoint struct {
+	PeriodStart   time.Time `json:"period_start"`
+	Tonnage       float64   `json:"tonnage"`
+	Sets          int       `json:"sets"`
+	Sessions      int       `json:"sessions"`
+	Change        *float64  `json:"tonnage_change"`
+	ChangePercent *float64  `json:"tonnage_change_percent"`
+	MovingAverage float64   `json:"moving_average"`
+}
+
+// VolumeSeries holds the points of one group, an exercise, a muscle group or
+// the total.
+type VolumeSeries struct {
+	Key    string        `json:"key"`
+	Points []VolumePoint `json:"points"`
+}
+
+type PostgresAnalyticsStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAnalyticsStore(db *sql.DB) *PostgresAnalyticsStore {
+	return &PostgresAnalyticsStore{db: db}
+}
+
+type AnalyticsStore interface {
+	GetVolume(filter VolumeFilter) ([]*VolumeSeries, error)
+	GetSessionLoads(userID int, from, to time.Time) ([]*SessionLoad, error)
+}
+
+// volumeKeys maps VolumeFilter.GroupBy to the expression naming the group of
+// an entry. Muscle groups come from the primary muscles of the linked
+// exercise, so an entry counts fully towards each of them.
+var volumeKeys = map[string]string{
+	"total":    `'total'`,
+	"exercise": `COALESCE(x.name, LOWER(TRIM(e.exercise_name)))`,
+	"muscle":   `COALESCE(m.muscle, 'unassigned')`,
+}
+
+// GetVolume sums up tonnage (sets x reps x weight), sets and sessions per
+// period and group, the sets of an entry in a block counting once per round.
+// Every period of the range is returned, with zeros when nothing was logged,
+// so changes and moving averages compare neighbouring periods.
+func (pg *PostgresAnalyticsStore) GetVolume(filter VolumeFilter) ([]*VolumeSeries, error) {
+	key, ok := volumeKeys[filter.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown volume grouping %q", filter.GroupBy)
+	}
+	join := ""
+	if filter.GroupBy == "muscle" {
+		join = "LEFT JOIN LATERAL unnest(x.primary_muscles) AS m(muscle) ON TRUE"
+	}
+	// period and window are checked by the handler, Postgres cannot take
+	// either as a placeholder in these positions
+	query := fmt.Sprintf(`
+	WITH periods AS (
+		SELECT generate_series(date_trunc('%[1]s', $2::timestamptz), $3::timestamptz - interval '1 microsecond', interval '1 %[1]s') AS period_start
+	),
+	entries AS (
+		SELECT date_trunc('%[1]s', w.created_at) AS period_start, %[2]s AS key, w.id AS workout_id,
+			s.sets * COALESCE(b.rounds, 1) AS sets,
+			s.tonnage * COALESCE(b.rounds, 1) AS tonnage
+		FROM workout_entries e
+		INNER JOIN workouts w ON w.id = e.workout_id
+		-- warm-ups and sets that were not completed add no volume
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS sets, COALESCE(SUM(COALESCE(ws.reps, 0) * COALESCE(ws.weight, 0)), 0) AS tonnage
+			FROM workout_sets ws
+			WHERE ws.entry_id = e.id AND ws.completed AND ws.set_type <> 'warmup'
+		) s
+		LEFT JOIN workout_blocks b ON b.id = e.block_id
+		LEFT JOIN exercises x ON x.id = e.exercise_id
+		%[4]s
+		WHERE w.user_id = $1 AND w.created_at >= $2 AND w.created_at < $3
+	),
+	totals AS (
+		SELECT period_start, key, SUM(tonnage) AS tonnage, SUM(sets) AS sets, COUNT(DISTINCT workout_id) AS sessions
+		FROM entries
+		GROUP BY period_start, key
+	),
+	series AS (
+		SELECT k.key, p.period_start, COALESCE(t.tonnage, 0) AS tonnage, COALESCE(t.sets, 0) AS sets, COALESCE(t.sessions, 0) AS sessions
+		FROM (SELECT DISTINCT key FROM totals) k
+		CROSS JOIN periods p
+		LEFT JOIN totals t ON t.key = k.key AND t.period_start = p.period_start
+	)
+	SELECT key, period_start, tonnage, sets, sessions,
+		tonnage - LAG(tonnage) OVER ordered,
+		(tonnage - LAG(tonnage) OVER ordered) * 100 / NULLIF(LAG(tonnage) OVER ordered, 0),
+		AVG(tonnage) OVER (ordered ROWS BETWEEN %[3]d PRECEDING AND CURRENT ROW)
+	FROM series
+	WINDOW ordered AS (PARTITION BY key ORDER BY period_start)
+	ORDER BY key, period_start;
+	`, filter.Period, key, filter.Window-1, join)
+
+	rows, err := pg.db.Query(query, filter.UserID, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []*VolumeSeries{}
+	var current *VolumeSeries
+	for rows.Next() {
+		var key string
+		var point VolumePoint
+		err := rows.Scan(&key, &point.PeriodStart, &point.Tonnage, &point.Sets, &point.Sessions, &point.Change, &point.ChangePercent, &point.MovingAverage)
+		if err != nil {
+			return nil, err
+		}
+		if point.ChangePercent != nil {
+			*point.ChangePercent = round2(*point.ChangePercent)
+		}
+		point.MovingAverage = round2(point.MovingAverage)
+		if current == nil || current.Key != key {
+			current = &VolumeSeries{Key: key}
+			series = append(series, current)
+		}
+		current.Points = append(current.Points, point)
+	}
+	return series, rows.Err()
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVolume(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workouts := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
+
+	// Mondays, so every workout opens a week of its own
+	weeks := []time.Time{
+		time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 24, 10, 0, 0, 0, time.UTC),
+	}
+	for i, day := range weeks {
+		workout, err := workouts.CreateWorkout(&Workout{
+			UserID:          user.ID,
+			Title:           "Legs",
+			DurationMinutes: 60,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Back Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(float64(100 + i*10)), OrderIndex: 1},
+				{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 2},
+			},
+		})
+		require.NoError(t, err)
+		_, err = db.Exec(`UPDATE workouts SET created_at = $1 WHERE id = $2`, day, workout.ID)
+		require.NoError(t, err)
+	}
+
+	store := NewPostgresAnalyticsStore(db)
+	series, err := store.GetVolume(VolumeFilter{
+		UserID:  user.ID,
+		From:    weeks[0],
+		To:      weeks[2].AddDate(0, 0, 7),
+		Period:  "week",
+		GroupBy: "total",
+		Window:  2,
+	})
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+
+	points := series[0].Points
+	require.Len(t, points, 4)
+	assert.Equal(t, 2500.0, points[0].Tonnage)
+	assert.Equal(t, 8, points[0].Sets)
+	assert.Equal(t, 1, points[0].Sessions)
+	assert.Nil(t, points[0].Change)
+	assert.Equal(t, 250.0, *points[1].Change)
+	assert.Equal(t, 10.0, *points[1].ChangePercent)
+	assert.Equal(t, 2625.0, points[1].MovingAverage)
+	assert.Equal(t, 0.0, points[2].Tonnage)
+	assert.Equal(t, 3000.0, points[3].Tonnage)
+	assert.Nil(t, points[3].ChangePercent)
+
+	series, err = store.GetVolume(VolumeFilter{
+		UserID:  user.ID,
+		From:    weeks[0],
+		To:      weeks[2].AddDate(0, 0, 7),
+		Period:  "month",
+		GroupBy: "muscle",
+		Window:  1,
+	})
+	require.NoError(t, err)
+	var keys []string
+	for _, s := range series {
+		keys = append(keys, s.Key)
+	}
+	assert.Equal(t, []string{"core", "glutes", "quadriceps"}, keys)
+}
@@ -0,0 +1,344 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Metrics of a body measurement, named after their columns. Body weight is in
+// kg, body fat in percent and circumferences in cm.
+const (
+	MetricBodyWeight     = "body_weight"
+	MetricBodyFatPercent = "body_fat_percent"
+	MetricWaist          = "waist"
+	MetricHips           = "hips"
+	MetricChest          = "chest"
+	MetricNeck           = "neck"
+	MetricArm            = "arm"
+	MetricThigh          = "thigh"
+)
+
+// BodyMetrics holds the metrics a body measurement can record.
+var BodyMetrics = []string{
+	MetricBodyWeight, MetricBodyFatPercent, MetricWaist, MetricHips,
+	MetricChest, MetricNeck, MetricArm, MetricThigh,
+}
+
+// DefaultTrendAlpha is the smoothing factor of the measurement trends, each
+// measurement moves the trend a tenth of the way towards it.
+const DefaultTrendAlpha = 0.1
+
+// BodyMeasurement holds the metrics a user measured at one time, any of which
+// can be missing. Trend is only filled in by SmoothTrends.
+type BodyMeasurement struct {
+	ID             int                `json:"id"`
+	UserID         int                `json:"user_id"`
+	MeasuredAt     time.Time          `json:"measured_at"`
+	BodyWeight     *float64           `json:"body_weight"`
+	BodyFatPercent *float64           `json:"body_fat_percent"`
+	Waist          *float64           `json:"waist"`
+	Hips           *float64           `json:"hips"`
+	Chest          *float64           `json:"chest"`
+	Neck           *float64           `json:"neck"`
+	Arm            *float64           `json:"arm"`
+	Thigh          *float64           `json:"thigh"`
+	Notes          string             `json:"notes"`
+	Trend          map[string]float64 `json:"trend,omitempty"`
+	Version        int                `json:"version"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// Value returns the value of a metric, nil when it was not measured.
+func (m *BodyMeasurement) Value(metric string) *float64 {
+	switch metric {
+	case MetricBodyWeight:
+		return m.BodyWeight
+	case MetricBodyFatPercent:
+		return m.BodyFatPercent
+	case MetricWaist:
+		return m.Waist
+	case MetricHips:
+		return m.Hips
+	case MetricChest:
+		return m.Chest
+	case MetricNeck:
+		return m.Neck
+	case MetricArm:
+		return m.Arm
+	case MetricThigh:
+		return m.Thigh
+	}
+	return nil
+}
+
+// BodyGoal is the value a user wants to bring one metric to. StartValue is
+// the latest value of the metric when the goal was set, the progress fields
+// are only filled in by Track.
+type BodyGoal struct {
+	Metric          string     `json:"metric"`
+	Target          float64    `json:"target"`
+	TargetDate      *time.Time `json:"target_date"`
+	StartValue      *float64   `json:"start_value"`
+	Current         *float64   `json:"current"`
+	Remaining       *float64   `json:"remaining"`
+	ProgressPercent *float64   `json:"progress_percent"`
+	Achieved        bool       `json:"achieved"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// SmoothTrends fills in the trend of every metric on measurements, which must
+// be sorted oldest first, as an exponentially weighted moving average: the
+// trend starts at the first value of a metric and each later value moves it
+// alpha of the way towards it. Measurements carry the trend of every metric
+// measured so far, whether they measured it or not.
+func SmoothTrends(measurements []*BodyMeasurement, alpha float64) {
+	trend := map[string]float64{}
+	for _, m := range measurements {
+		for _, metric := range BodyMetrics {
+			value := m.Value(metric)
+			if value == nil {
+				continue
+			}
+			if current, ok := trend[metric]; ok {
+				trend[metric] = current + alpha*(*value-current)
+			} else {
+				trend[metric] = *value
+			}
+		}
+		m.Trend = make(map[string]float64, len(trend))
+		for metric, value := range trend {
+			m.Trend[metric] = round2(value)
+		}
+	}
+}
+
+// Track fills in the progress of the goal from measurements, sorted oldest
+// first with their trends smoothed. Current is the latest trend of the
+// metric, a goal set before the metric was ever measured starts from the
+// first value measured after it was set. The goal is achieved once the trend
+// reaches the target from the side it started on.
+func (g *BodyGoal) Track(measurements []*BodyMeasurement) {
+	g.Current, g.Remaining, g.ProgressPercent, g.Achieved = nil, nil, nil, false
+	start := g.StartValue
+	for _, m := range measurements {
+		if start == nil && !m.MeasuredAt.Before(g.CreatedAt) {
+			start = m.Value(g.Metric)
+		}
+		if value, ok := m.Trend[g.Metric]; ok {
+			g.Current = &value
+		}
+	}
+	if g.Current == nil {
+		return
+	}
+	remaining := round2(g.Target - *g.Current)
+	g.Remaining = &remaining
+	if start == nil {
+		return
+	}
+	if g.Target < *start {
+		g.Achieved = *g.Current <= g.Target
+	} else {
+		g.Achieved = *g.Current >= g.Target
+	}
+	if *start != g.Target {
+		progress := math.Round((*g.Current-*start)/(g.Target-*start)*1000) / 10
+		g.ProgressPercent = &progress
+	}
+}
+
+// bodyWeightAt returns the SQL expression of the body weight of a user at a
+// point in time: the last weigh-in up to it, else the first one after it. The
+// body weight set on the user counts as a weigh-in of when it was last
+// changed.
+func bodyWeightAt(user, at string) string {
+	return fmt.Sprintf(`(SELECT w.body_weight FROM (
+			SELECT bm.body_weight, bm.measured_at, bm.id FROM body_measurements bm
+			WHERE bm.user_id = %[1]s AND bm.body_weight IS NOT NULL
+			UNION ALL
+			SELECT p.body_weight, COALESCE(p.body_weight_updated_at, p.updated_at), 0 FROM users p
+			WHERE p.id = %[1]s AND p.body_weight IS NOT NULL
+		) w
+		ORDER BY w.measured_at > %[2]s, ABS(EXTRACT(EPOCH FROM w.measured_at - %[2]s)), w.id DESC
+		LIMIT 1)`, user, at)
+}
+
+type PostgresBodyMeasurementStore struct {
+	db *sql.DB
+}
+
+func NewPostgresBodyMeasurementStore(db *sql.DB) *PostgresBodyMeasurementStore {
+	return &PostgresBodyMeasurementStore{db: db}
+}
+
+type BodyMeasurementStore interface {
+	CreateMeasurement(measurement *BodyMeasurement) error
+	GetMeasurementByID(id int64) (*BodyMeasurement, error)
+	ListMeasurements(userID int, to *time.Time) ([]*BodyMeasurement, error)
+	UpdateMeasurement(measurement *BodyMeasurement) error
+	DeleteMeasurement(id int64) error
+	ListGoals(userID int) ([]*BodyGoal, error)
+	SetGoal(userID int, goal *BodyGoal) error
+	DeleteGoal(userID int, metric string) error
+}
+
+const measurementColumns = `id, user_id, measured_at, body_weight, body_fat_percent, waist, hips, chest, neck, arm, thigh, notes, version, created_at, updated_at`
+
+func scanMeasurement(row scanner) (*BodyMeasurement, error) {
+	m := &BodyMeasurement{}
+	err := row.Scan(&m.ID, &m.UserID, &m.MeasuredAt, &m.BodyWeight, &m.BodyFatPercent, &m.Waist, &m.Hips, &m.Chest, &m.Neck, &m.Arm, &m.Thigh, &m.Notes, &m.Version, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (pg *PostgresBodyMeasurementStore) CreateMeasurement(m *BodyMeasurement) error {
+	query := `
+	INSERT INTO body_measurements (user_id, measured_at, body_weight, body_fat_percent, waist, hips, chest, neck, arm, thigh, notes)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING id, version, created_at, updated_at;
+	`
+	err := pg.db.QueryRow(query, m.UserID, m.MeasuredAt, m.BodyWeight, m.BodyFatPercent, m.Waist, m.Hips, m.Chest, m.Neck, m.Arm, m.Thigh, m.Notes).Scan(&m.ID, &m.Version, &m.CreatedAt, &m.UpdatedAt)
+	return mapError(err)
+}
+
+func (pg *PostgresBodyMeasurementStore) GetMeasurementByID(id int64) (*BodyMeasurement, error) {
+	query := `SELECT ` + measurementColumns + ` FROM body_measurements WHERE id = $1;`
+	m, err := scanMeasurement(pg.db.QueryRow(query, id))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return m, nil
+}
+
+// ListMeasurements returns the measurements of a user up to, but not
+// including, to, oldest first. A nil to lists them all.
+func (pg *PostgresBodyMeasurementStore) ListMeasurements(userID int, to *time.Time) ([]*BodyMeasurement, error) {
+	query := `
+	SELECT ` + measurementColumns + `
+	FROM body_measurements
+	WHERE user_id = $1 AND ($2::timestamptz IS NULL OR measured_at < $2)
+	ORDER BY measured_at, id;
+	`
+	rows, err := pg.db.Query(query, userID, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	measurements := []*BodyMeasurement{}
+	for rows.Next() {
+		m, err := scanMeasurement(rows)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, m)
+	}
+	return measurements, rows.Err()
+}
+
+func (pg *PostgresBodyMeasurementStore) UpdateMeasurement(m *BodyMeasurement) error {
+	query := `
+	UPDATE body_measurements
+	SET measured_at = $1, body_weight = $2, body_fat_percent = $3, waist = $4, hips = $5, chest = $6, neck = $7, arm = $8, thigh = $9, notes = $10, version = version + 1, updated_at = NOW()
+	WHERE id = $11 AND version = $12
+	RETURNING version, updated_at;
+	`
+	err := pg.db.QueryRow(query, m.MeasuredAt, m.BodyWeight, m.BodyFatPercent, m.Waist, m.Hips, m.Chest, m.Neck, m.Arm, m.Thigh, m.Notes, m.ID, m.Version).Scan(&m.Version, &m.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return mapError(err)
+	}
+	return nil
+}
+
+func (pg *PostgresBodyMeasurementStore) DeleteMeasurement(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM body_measurements WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListGoals returns the goals of a user in the order of BodyMetrics.
+func (pg *PostgresBodyMeasurementStore) ListGoals(userID int) ([]*BodyGoal, error) {
+	query := `
+	SELECT metric, target, target_date, start_value, created_at, updated_at
+	FROM body_goals
+	WHERE user_id = $1;
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	goals := []*BodyGoal{}
+	for rows.Next() {
+		goal := &BodyGoal{}
+		err := rows.Scan(&goal.Metric, &goal.Target, &goal.TargetDate, &goal.StartValue, &goal.CreatedAt, &goal.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	slices.SortFunc(goals, func(a, b *BodyGoal) int {
+		return slices.Index(BodyMetrics, a.Metric) - slices.Index(BodyMetrics, b.Metric)
+	})
+	return goals, rows.Err()
+}
+
+// SetGoal creates or replaces the goal of a user on goal.Metric, which must be
+// one of BodyMetrics. A new goal starts from the latest value of the metric,
+// a replaced one keeps the value it started from.
+func (pg *PostgresBodyMeasurementStore) SetGoal(userID int, goal *BodyGoal) error {
+	// the metric names the column the start value is read from, so only the
+	// known ones may reach the query
+	if !slices.Contains(BodyMetrics, goal.Metric) {
+		return ErrInvalidData
+	}
+	query := fmt.Sprintf(`
+	INSERT INTO body_goals (user_id, metric, target, target_date, start_value)
+	VALUES ($1, $2, $3, $4, (
+		SELECT %[1]s FROM body_measurements
+		WHERE user_id = $1 AND %[1]s IS NOT NULL
+		ORDER BY measured_at DESC, id DESC
+		LIMIT 1
+	))
+	ON CONFLICT (user_id, metric) DO UPDATE
+	SET target = EXCLUDED.target, target_date = EXCLUDED.target_date,
+		start_value = COALESCE(body_goals.start_value, EXCLUDED.start_value), updated_at = NOW()
+	RETURNING start_value, created_at, updated_at;
+	`, goal.Metric)
+	err := pg.db.QueryRow(query, userID, goal.Metric, goal.Target, goal.TargetDate).Scan(&goal.StartValue, &goal.CreatedAt, &goal.UpdatedAt)
+	return mapError(err)
+}
+
+func (pg *PostgresBodyMeasurementStore) DeleteGoal(userID int, metric string) error {
+	result, err := pg.db.Exec(`DELETE FROM body_goals WHERE user_id = $1 AND metric = $2;`, userID, metric)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
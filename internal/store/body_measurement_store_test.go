@@ -0,0 +1,139 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmoothTrends(t *testing.T) {
+	day := time.Date(2025, 1, 1, 7, 0, 0, 0, time.UTC)
+	measurements := []*BodyMeasurement{
+		{MeasuredAt: day, BodyWeight: FloatPtr(80)},
+		{MeasuredAt: day.AddDate(0, 0, 1), BodyWeight: FloatPtr(82)},
+		{MeasuredAt: day.AddDate(0, 0, 2), Waist: FloatPtr(90)},
+		{MeasuredAt: day.AddDate(0, 0, 3), BodyWeight: FloatPtr(78)},
+	}
+	SmoothTrends(measurements, 0.5)
+
+	assert.Equal(t, map[string]float64{MetricBodyWeight: 80}, measurements[0].Trend)
+	assert.Equal(t, map[string]float64{MetricBodyWeight: 81}, measurements[1].Trend)
+	// the trend of a metric carries over measurements without it
+	assert.Equal(t, map[string]float64{MetricBodyWeight: 81, MetricWaist: 90}, measurements[2].Trend)
+	assert.Equal(t, map[string]float64{MetricBodyWeight: 79.5, MetricWaist: 90}, measurements[3].Trend)
+}
+
+func TestTrackGoal(t *testing.T) {
+	day := time.Date(2025, 1, 1, 7, 0, 0, 0, time.UTC)
+	measurements := []*BodyMeasurement{
+		{MeasuredAt: day, BodyWeight: FloatPtr(80)},
+		{MeasuredAt: day.AddDate(0, 0, 1), BodyWeight: FloatPtr(82)},
+		{MeasuredAt: day.AddDate(0, 0, 2), Waist: FloatPtr(90)},
+		{MeasuredAt: day.AddDate(0, 0, 3), BodyWeight: FloatPtr(78)},
+	}
+	SmoothTrends(measurements, 0.5)
+
+	// set before the first weigh-in, which it starts from
+	goal := &BodyGoal{Metric: MetricBodyWeight, Target: 75, CreatedAt: day.Add(-time.Hour)}
+	goal.Track(measurements)
+	assert.Equal(t, FloatPtr(79.5), goal.Current)
+	assert.Equal(t, FloatPtr(-4.5), goal.Remaining)
+	assert.Equal(t, FloatPtr(10), goal.ProgressPercent)
+	assert.False(t, goal.Achieved)
+
+	goal = &BodyGoal{Metric: MetricBodyWeight, Target: 80, StartValue: FloatPtr(85), CreatedAt: day}
+	goal.Track(measurements)
+	assert.Equal(t, FloatPtr(110), goal.ProgressPercent)
+	assert.True(t, goal.Achieved)
+
+	// gaining towards the target
+	goal = &BodyGoal{Metric: MetricBodyWeight, Target: 85, StartValue: FloatPtr(78), CreatedAt: day}
+	goal.Track(measurements)
+	assert.Equal(t, FloatPtr(21.4), goal.ProgressPercent)
+	assert.False(t, goal.Achieved)
+
+	// not measured since it was set, so there is no progress to tell
+	goal = &BodyGoal{Metric: MetricWaist, Target: 85, CreatedAt: day.AddDate(0, 0, 3)}
+	goal.Track(measurements)
+	assert.Equal(t, FloatPtr(90), goal.Current)
+	assert.Equal(t, FloatPtr(-5), goal.Remaining)
+	assert.Nil(t, goal.ProgressPercent)
+	assert.False(t, goal.Achieved)
+
+	goal = &BodyGoal{Metric: MetricNeck, Target: 40}
+	goal.Track(measurements)
+	assert.Nil(t, goal.Current)
+	assert.Nil(t, goal.Remaining)
+}
+
+func TestValidateBodyMeasurement(t *testing.T) {
+	v := validator.New()
+	ValidateBodyMeasurement(v, &BodyMeasurement{MeasuredAt: time.Now(), BodyWeight: FloatPtr(80), BodyFatPercent: FloatPtr(18)})
+	assert.Empty(t, v.Errors)
+
+	v = validator.New()
+	ValidateBodyMeasurement(v, &BodyMeasurement{})
+	assert.Equal(t, map[string]string{
+		"measured_at": "must be provided",
+		"measurement": "must hold at least one of body_weight, body_fat_percent, waist, hips, chest, neck, arm, thigh",
+	}, v.Errors)
+
+	v = validator.New()
+	ValidateBodyMeasurement(v, &BodyMeasurement{MeasuredAt: time.Now(), BodyFatPercent: FloatPtr(120), Waist: FloatPtr(-1)})
+	assert.Equal(t, map[string]string{
+		"body_fat_percent": "must be greater than 0 and less than 100",
+		"waist":            "must be greater than 0 and less than 1000",
+	}, v.Errors)
+
+	v = validator.New()
+	ValidateBodyGoal(v, &BodyGoal{Metric: "height", Target: 180})
+	assert.Equal(t, map[string]string{"metric": "must be one of body_weight, body_fat_percent, waist, hips, chest, neck, arm, thigh"}, v.Errors)
+}
+
+func TestBodyWeightAt(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestUser(t, db)
+	measurementStore := NewPostgresBodyMeasurementStore(db)
+	userStore := NewPostgresUserStore(db)
+
+	weighIn := time.Now().AddDate(0, 0, -10)
+	require.NoError(t, measurementStore.CreateMeasurement(&BodyMeasurement{UserID: user.ID, MeasuredAt: weighIn, BodyWeight: FloatPtr(90)}))
+
+	bodyWeight := func(at time.Time) *float64 {
+		var weight *float64
+		err := db.QueryRow(`SELECT `+bodyWeightAt("$1::bigint", "$2::timestamptz"), user.ID, at).Scan(&weight)
+		require.NoError(t, err)
+		return weight
+	}
+	assert.Equal(t, FloatPtr(90), bodyWeight(time.Now()))
+
+	// the body weight set on the profile later on wins from then
+	profile, err := userStore.GetUserByID(int64(user.ID))
+	require.NoError(t, err)
+	profile.BodyWeight = FloatPtr(80)
+	require.NoError(t, userStore.UpdateUser(profile))
+
+	assert.Equal(t, FloatPtr(80), bodyWeight(time.Now()))
+	assert.Equal(t, FloatPtr(90), bodyWeight(weighIn.AddDate(0, 0, 1)))
+	assert.Equal(t, FloatPtr(90), bodyWeight(weighIn.AddDate(0, 0, -1)))
+
+	// saving the profile without changing the weight does not make it newer
+	require.NoError(t, measurementStore.CreateMeasurement(&BodyMeasurement{UserID: user.ID, MeasuredAt: time.Now(), BodyWeight: FloatPtr(85)}))
+	profile.BIO = "cutting"
+	require.NoError(t, userStore.UpdateUser(profile))
+	assert.Equal(t, FloatPtr(85), bodyWeight(time.Now().Add(time.Minute)))
+}
+
+func TestSetGoalRejectsUnknownMetric(t *testing.T) {
+	// the metric is checked before the database is reached
+	measurementStore := NewPostgresBodyMeasurementStore(nil)
+	for _, metric := range []string{"", "weight", "waist FROM body_measurements; DROP TABLE body_goals; --"} {
+		err := measurementStore.SetGoal(1, &BodyGoal{Metric: metric, Target: 80})
+		assert.ErrorIs(t, err, ErrInvalidData, metric)
+	}
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// metricLimit is the exclusive upper bound of the values of a metric.
+func metricLimit(metric string) (float64, string) {
+	if metric == MetricBodyFatPercent {
+		return 100, "must be greater than 0 and less than 100"
+	}
+	return 1000, "must be greater than 0 and less than 1000"
+}
+
+// ValidateBodyMeasurement checks a body measurement before it reaches the
+// database, recording every violation in v.
+func ValidateBodyMeasurement(v *validator.Validator, m *BodyMeasurement) {
+	v.Check(!m.MeasuredAt.IsZero(), "measured_at", "must be provided")
+	measured := false
+	for _, metric := range BodyMetrics {
+		value := m.Value(metric)
+		if value == nil {
+			continue
+		}
+		measured = true
+		limit, message := metricLimit(metric)
+		v.Check(*value > 0 && *value < limit, metric, message)
+	}
+	v.Check(measured, "measurement", "must hold at least one of "+strings.Join(BodyMetrics, ", "))
+}
+
+// ValidateBodyGoal checks a body goal before it reaches the database,
+// recording every violation in v.
+func ValidateBodyGoal(v *validator.Validator, goal *BodyGoal) {
+	if !slices.Contains(BodyMetrics, goal.Metric) {
+		v.AddError("metric", "must be one of "+strings.Join(BodyMetrics, ", "))
+		return
+	}
+	limit, message := metricLimit(goal.Metric)
+	v.Check(goal.Target > 0 && goal.Target < limit, "target", message)
+}
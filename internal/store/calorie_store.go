@@ -0,0 +1,228 @@
+package store
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//go:embed met_values.csv
+var metValuesCSV string
+
+// metValues maps lower case exercise names, and movement patterns prefixed
+// with "pattern:", to the MET of the activity.
+var metValues = loadMETValues(metValuesCSV)
+
+// defaultMET is the MET of general resistance training, used for exercises
+// the table does not know and for workouts without sets.
+const defaultMET = 3.5
+
+// referenceBodyWeight stands in for the body weight of users who neither
+// weighed in nor set theirs.
+const referenceBodyWeight = 70.0
+
+// secondsPerRep estimates the time a set counted in reps takes.
+const secondsPerRep = 4
+
+func loadMETValues(data string) map[string]float64 {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("invalid MET table: %v", err))
+	}
+	values := make(map[string]float64, len(records))
+	for _, record := range records[1:] {
+		met, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			panic(fmt.Sprintf("invalid MET value %q for %s", record[1], record[0]))
+		}
+		values[record[0]] = met
+	}
+	return values
+}
+
+// exerciseMET looks the MET of an exercise up by its name, then by the
+// movement pattern of its catalog exercise.
+func exerciseMET(name, pattern string) float64 {
+	if met, ok := metValues[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return met
+	}
+	if met, ok := metValues["pattern:"+pattern]; ok {
+		return met
+	}
+	return defaultMET
+}
+
+// activity is the time spent on the sets of one exercise at its MET.
+type activity struct {
+	MET     float64
+	Seconds float64
+}
+
+// estimateCalories estimates the kcal burned in a workout as MET x body weight
+// in kg x hours. The duration of the workout, rest included, is shared out
+// among the activities by their active time, so a workout burns at the
+// average MET of its sets. Logged sets taking longer than the duration count
+// in full.
+func estimateCalories(bodyWeight float64, durationMinutes int, activities []activity) int {
+	active, weighted := 0.0, 0.0
+	for _, a := range activities {
+		active += a.Seconds
+		weighted += a.MET * a.Seconds
+	}
+	met := defaultMET
+	if active > 0 {
+		met = weighted / active
+	}
+	hours := max(float64(durationMinutes)*60, active) / 3600
+	return int(math.Round(met * bodyWeight * hours))
+}
+
+// estimateWorkoutCalories estimates the calories of a workout from its
+// completed sets and the body weight of its owner at the time, and stores
+// them as estimated.
+func estimateWorkoutCalories(tx *sql.Tx, workoutID int64) (int, error) {
+	var bodyWeight *float64
+	var durationMinutes int
+	query := `
+	SELECT ` + bodyWeightAt("w.user_id", "w.created_at") + `, w.duration_minutes
+	FROM workouts w
+	WHERE w.id = $1;
+	`
+	err := tx.QueryRow(query, workoutID).Scan(&bodyWeight, &durationMinutes)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	query = `
+	SELECT COALESCE(x.name, e.exercise_name), COALESCE(x.movement_pattern, ''), s.reps, s.duration_seconds, COALESCE(b.rounds, 1)
+	FROM workout_sets s
+	INNER JOIN workout_entries e ON e.id = s.entry_id
+	LEFT JOIN exercises x ON x.id = e.exercise_id
+	LEFT JOIN workout_blocks b ON b.id = e.block_id
+	WHERE e.workout_id = $1 AND s.completed;
+	`
+	rows, err := tx.Query(query, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	var activities []activity
+	for rows.Next() {
+		var name, pattern string
+		var reps, durationSeconds *int
+		var rounds int
+		err := rows.Scan(&name, &pattern, &reps, &durationSeconds, &rounds)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		seconds := 0
+		if durationSeconds != nil {
+			seconds = *durationSeconds
+		} else if reps != nil {
+			seconds = *reps * secondsPerRep
+		}
+		activities = append(activities, activity{MET: exerciseMET(name, pattern), Seconds: float64(seconds * rounds)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	weight := referenceBodyWeight
+	if bodyWeight != nil {
+		weight = *bodyWeight
+	}
+	calories := estimateCalories(weight, durationMinutes, activities)
+	_, err = tx.Exec(`UPDATE workouts SET calories_burned = $1, calories_estimated = TRUE WHERE id = $2;`, calories, workoutID)
+	return calories, err
+}
+
+// refreshEstimatedCalories re-estimates the calories of a workout whose
+// entries changed, unless they were measured.
+func refreshEstimatedCalories(tx *sql.Tx, workoutID int64) error {
+	var estimated bool
+	err := tx.QueryRow(`SELECT calories_estimated FROM workouts WHERE id = $1;`, workoutID).Scan(&estimated)
+	if err != nil || !estimated {
+		return mapError(err)
+	}
+	_, err = estimateWorkoutCalories(tx, workoutID)
+	return err
+}
+
+// EstimateCalories replaces the calories of a workout with an estimate, also
+// when they were measured.
+func (pg *PostgresWorkoutStore) EstimateCalories(workoutID int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = lockWorkout(tx, workoutID)
+	if err != nil {
+		return err
+	}
+	_, err = estimateWorkoutCalories(tx, workoutID)
+	if err != nil {
+		return err
+	}
+	_, err = touchWorkout(tx, workoutID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReestimateCalories estimates the calories of every workout of a user that
+// has estimated calories or none at all, as after a new weigh-in,
+// and returns the number of workouts updated. Measured calories are kept.
+func (pg *PostgresWorkoutStore) ReestimateCalories(userID int) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT id FROM workouts
+	WHERE user_id = $1 AND (calories_estimated OR calories_burned = 0)
+	ORDER BY id
+	FOR UPDATE;
+	`
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		_, err = estimateWorkoutCalories(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		_, err = touchWorkout(tx, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), tx.Commit()
+}
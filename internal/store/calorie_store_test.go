@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExerciseMET(t *testing.T) {
+	assert.Equal(t, 9.8, exerciseMET(" Running ", "cardio"))
+	// catalog exercises not listed by name fall back to their movement pattern
+	assert.Equal(t, 6.0, exerciseMET("Back Squat", "squat"))
+	assert.Equal(t, defaultMET, exerciseMET("Zercher Carry Hold", ""))
+	for key, met := range metValues {
+		assert.Greater(t, met, 1.0, key)
+	}
+}
+
+func TestEstimateCalories(t *testing.T) {
+	// no sets: the whole hour at the MET of resistance training
+	assert.Equal(t, 280, estimateCalories(80, 60, nil))
+
+	// 20 minutes of running and 10 of planks share out a 45 minute session
+	activities := []activity{
+		{MET: 9.8, Seconds: 1200},
+		{MET: 3.8, Seconds: 300},
+		{MET: 3.8, Seconds: 300},
+	}
+	assert.Equal(t, 439, estimateCalories(75, 45, activities))
+
+	// sets logged beyond the duration of the workout still count
+	assert.Equal(t, 686, estimateCalories(70, 30, []activity{{MET: 9.8, Seconds: 3600}}))
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a record clashes with an existing one on a
+	// unique column such as a username or an email.
+	ErrConflict = errors.New("record already exists")
+	// ErrEditConflict is returned when a record changed between being read and
+	// being written back.
+	ErrEditConflict = errors.New("edit conflict")
+	// ErrInvalidData is returned when the database rejects a record because it
+	// breaks a constraint.
+	ErrInvalidData = errors.New("record violates a constraint")
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgNotNullViolation    = "23502"
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+)
+
+// ConflictError reports which field of a record clashed with an existing one,
+// or with Reason set, why the record clashes with the records referencing it.
+type ConflictError struct {
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("this %s %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("a record with this %s already exists", e.Field)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ConstraintError reports the constraint a record broke.
+type ConstraintError struct {
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("record violates constraint %q", e.Constraint)
+}
+
+func (e *ConstraintError) Is(target error) bool {
+	return target == ErrInvalidData
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// mapError translates errors coming from database/sql and pgx into the errors
+// of this package, anything it does not recognize is returned unchanged.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return &ConflictError{Field: conflictField(pgErr), Err: err}
+	case pgCheckViolation, pgNotNullViolation, pgForeignKeyViolation:
+		constraint := pgErr.ConstraintName
+		if constraint == "" {
+			constraint = pgErr.ColumnName
+		}
+		return &ConstraintError{Constraint: constraint, Err: err}
+	}
+	return err
+}
+
+// isForeignKeyViolation reports whether err is Postgres refusing to remove a
+// row that is still referenced.
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation
+}
+
+// conflictField guesses the column behind a unique violation from the
+// constraint name Postgres generates, e.g. users_email_key becomes email.
+func conflictField(pgErr *pgconn.PgError) string {
+	name := strings.TrimSuffix(pgErr.ConstraintName, "_key")
+	if pgErr.TableName != "" {
+		name = strings.TrimPrefix(name, pgErr.TableName+"_")
+	}
+	if name == "" {
+		return "value"
+	}
+	return name
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapError(t *testing.T) {
+	uniqueErr := &pgconn.PgError{Code: pgUniqueViolation, TableName: "users", ConstraintName: "users_email_key"}
+	checkErr := &pgconn.PgError{Code: pgCheckViolation, TableName: "workout_entries", ConstraintName: "valid_workout_entry"}
+	foreignKeyErr := &pgconn.PgError{Code: pgForeignKeyViolation, TableName: "program_days", ConstraintName: "program_days_template_id_fkey"}
+	otherErr := errors.New("connection reset")
+
+	assert.Nil(t, mapError(nil))
+	assert.ErrorIs(t, mapError(sql.ErrNoRows), ErrNotFound)
+	assert.Equal(t, otherErr, mapError(otherErr))
+
+	err := mapError(fmt.Errorf("insert: %w", uniqueErr))
+	assert.ErrorIs(t, err, ErrConflict)
+	var conflictErr *ConflictError
+	if assert.ErrorAs(t, err, &conflictErr) {
+		assert.Equal(t, "email", conflictErr.Field)
+	}
+
+	err = mapError(checkErr)
+	assert.ErrorIs(t, err, ErrInvalidData)
+	var constraintErr *ConstraintError
+	if assert.ErrorAs(t, err, &constraintErr) {
+		assert.Equal(t, "valid_workout_entry", constraintErr.Constraint)
+	}
+
+	assert.True(t, isForeignKeyViolation(fmt.Errorf("delete: %w", foreignKeyErr)))
+	assert.False(t, isForeignKeyViolation(checkErr))
+	assert.False(t, isForeignKeyViolation(nil))
+	assert.Equal(t, "this template is scheduled by a program", (&ConflictError{Field: "template", Reason: "is scheduled by a program"}).Error())
+}
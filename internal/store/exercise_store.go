@@ -0,0 +1,237 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ExerciseTypeReps  = "reps"
+	ExerciseTypeTimed = "timed"
+)
+
+var (
+	// MuscleGroups holds the values accepted for the muscles of an exercise.
+	MuscleGroups = []string{
+		"chest", "back", "lats", "traps", "shoulders", "biceps", "triceps", "forearms",
+		"core", "quadriceps", "hamstrings", "glutes", "calves", "adductors", "full_body", "cardio",
+	}
+	// Equipment holds the values accepted for the equipment of an exercise.
+	Equipment = []string{"barbell", "dumbbell", "kettlebell", "machine", "cable", "band", "bodyweight", "none", "other"}
+	// MovementPatterns holds the values accepted for the movement pattern of an
+	// exercise.
+	MovementPatterns = []string{
+		"squat", "hinge", "lunge", "horizontal_push", "vertical_push", "horizontal_pull",
+		"vertical_pull", "carry", "core", "isolation", "cardio",
+	}
+)
+
+// Exercise is an entry of the exercise catalog. Built-in exercises have no
+// UserID and can be used by everyone, the others belong to a single user.
+type Exercise struct {
+	ID               int       `json:"id"`
+	UserID           *int      `json:"user_id"`
+	Name             string    `json:"name"`
+	Aliases          []string  `json:"aliases"`
+	PrimaryMuscles   []string  `json:"primary_muscles"`
+	SecondaryMuscles []string  `json:"secondary_muscles"`
+	Equipment        string    `json:"equipment"`
+	MovementPattern  string    `json:"movement_pattern"`
+	Type             string    `json:"type"`
+	Version          int       `json:"version"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// IsBuiltIn reports whether the exercise is part of the seeded catalog.
+func (e *Exercise) IsBuiltIn() bool {
+	return e.UserID == nil
+}
+
+// ExerciseFilter narrows down the exercises returned by SearchExercises. Only
+// the built-in exercises and those of UserID are searched.
+type ExerciseFilter struct {
+	UserID          int
+	Query           string
+	Muscle          string
+	Equipment       string
+	MovementPattern string
+	Type            string
+	Limit           int
+}
+
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db}
+}
+
+type ExerciseStore interface {
+	CreateExercise(exercise *Exercise) error
+	GetExerciseByID(id int64) (*Exercise, error)
+	UpdateExercise(exercise *Exercise) error
+	DeleteExercise(id int64) error
+	SearchExercises(filter ExerciseFilter) ([]*Exercise, error)
+}
+
+// textArray scans a text[] column selected through to_json, which works the
+// same whichever pgx driver is registered.
+type textArray []string
+
+func (a *textArray) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, a)
+	case string:
+		return json.Unmarshal([]byte(src), a)
+	case nil:
+		*a = []string{}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into textArray", src)
+}
+
+const exerciseColumns = `id, user_id, name, to_json(aliases), to_json(primary_muscles), to_json(secondary_muscles), equipment, movement_pattern, type, version, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExercise(row scanner) (*Exercise, error) {
+	exercise := &Exercise{}
+	var aliases, primaryMuscles, secondaryMuscles textArray
+	err := row.Scan(&exercise.ID, &exercise.UserID, &exercise.Name, &aliases, &primaryMuscles, &secondaryMuscles, &exercise.Equipment, &exercise.MovementPattern, &exercise.Type, &exercise.Version, &exercise.CreatedAt, &exercise.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	exercise.Aliases = aliases
+	exercise.PrimaryMuscles = primaryMuscles
+	exercise.SecondaryMuscles = secondaryMuscles
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) CreateExercise(exercise *Exercise) error {
+	query := `
+	INSERT INTO exercises (user_id, name, aliases, primary_muscles, secondary_muscles, equipment, movement_pattern, type)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, version, created_at, updated_at;
+	`
+	err := pg.db.QueryRow(query, exercise.UserID, exercise.Name, nonNil(exercise.Aliases), nonNil(exercise.PrimaryMuscles), nonNil(exercise.SecondaryMuscles), exercise.Equipment, exercise.MovementPattern, exercise.Type).Scan(&exercise.ID, &exercise.Version, &exercise.CreatedAt, &exercise.UpdatedAt)
+	return mapError(err)
+}
+
+func (pg *PostgresExerciseStore) GetExerciseByID(id int64) (*Exercise, error) {
+	query := `SELECT ` + exerciseColumns + ` FROM exercises WHERE id = $1;`
+	exercise, err := scanExercise(pg.db.QueryRow(query, id))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) UpdateExercise(exercise *Exercise) error {
+	query := `
+	UPDATE exercises SET name = $1, aliases = $2, primary_muscles = $3, secondary_muscles = $4, equipment = $5, movement_pattern = $6, type = $7, version = version + 1, updated_at = NOW()
+	WHERE id = $8 AND version = $9
+	RETURNING version, updated_at;
+	`
+	err := pg.db.QueryRow(query, exercise.Name, nonNil(exercise.Aliases), nonNil(exercise.PrimaryMuscles), nonNil(exercise.SecondaryMuscles), exercise.Equipment, exercise.MovementPattern, exercise.Type, exercise.ID, exercise.Version).Scan(&exercise.Version, &exercise.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return mapError(err)
+	}
+	return nil
+}
+
+// DeleteExercise removes an exercise, entries linked to it keep their
+// exercise_name and lose the link.
+func (pg *PostgresExerciseStore) DeleteExercise(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM exercises WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SearchExercises returns the exercises matching the filter. Query matches
+// names and aliases, exact matches come first, then prefix matches.
+func (pg *PostgresExerciseStore) SearchExercises(filter ExerciseFilter) ([]*Exercise, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	addCondition("(user_id IS NULL OR user_id = $%d)", filter.UserID)
+	if filter.Muscle != "" {
+		addCondition("($%[1]d = ANY(primary_muscles) OR $%[1]d = ANY(secondary_muscles))", filter.Muscle)
+	}
+	if filter.Equipment != "" {
+		addCondition("equipment = $%d", filter.Equipment)
+	}
+	if filter.MovementPattern != "" {
+		addCondition("movement_pattern = $%d", filter.MovementPattern)
+	}
+	if filter.Type != "" {
+		addCondition("type = $%d", filter.Type)
+	}
+	order := "name"
+	if filter.Query != "" {
+		addCondition(`(name ILIKE '%%' || $%[1]d || '%%' ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM unnest(aliases) AS alias WHERE alias ILIKE '%%' || $%[1]d || '%%' ESCAPE '\'))`, likeEscaper.Replace(filter.Query))
+		pattern := len(args)
+		args = append(args, filter.Query)
+		order = fmt.Sprintf(`LOWER(name) = LOWER($%[1]d) DESC,
+			EXISTS (SELECT 1 FROM unnest(aliases) AS alias WHERE LOWER(alias) = LOWER($%[1]d)) DESC,
+			name ILIKE $%[2]d || '%%' ESCAPE '\' DESC, name`, len(args), pattern)
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM exercises
+	WHERE %s
+	ORDER BY %s, id
+	LIMIT $%d;
+	`, exerciseColumns, strings.Join(conditions, " AND "), order, len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+	return exercises, rows.Err()
+}
+
+// nonNil keeps NOT NULL array columns from receiving NULL for a missing list.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
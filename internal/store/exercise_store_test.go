@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchExercises(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresExerciseStore(db)
+	user := createTestUser(t, db)
+
+	custom := &Exercise{
+		UserID:          &user.ID,
+		Name:            "Zercher Squat",
+		PrimaryMuscles:  []string{"quadriceps"},
+		Equipment:       "barbell",
+		MovementPattern: "squat",
+		Type:            ExerciseTypeReps,
+	}
+	require.NoError(t, store.CreateExercise(custom))
+	assert.Equal(t, []string{}, custom.Aliases)
+
+	duplicate := *custom
+	assert.ErrorIs(t, store.CreateExercise(&duplicate), ErrConflict)
+
+	exercises, err := store.SearchExercises(ExerciseFilter{UserID: user.ID, Query: "bp", Limit: 10})
+	require.NoError(t, err)
+	require.NotEmpty(t, exercises)
+	assert.Equal(t, "Bench Press", exercises[0].Name)
+
+	exercises, err = store.SearchExercises(ExerciseFilter{UserID: user.ID, Query: "squat", MovementPattern: "squat", Limit: 50})
+	require.NoError(t, err)
+	var names []string
+	for _, exercise := range exercises {
+		names = append(names, exercise.Name)
+	}
+	assert.Contains(t, names, "Zercher Squat")
+	assert.Contains(t, names, "Back Squat")
+
+	exercises, err = store.SearchExercises(ExerciseFilter{UserID: user.ID + 1, Query: "zercher", Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, exercises)
+}
+
+func TestEntriesLinkToExercises(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          user.ID,
+		Title:           "Push",
+		DurationMinutes: 40,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", Reps: IntPtr(5), Sets: 5, OrderIndex: 1},
+			{ExerciseName: "OHP", Reps: IntPtr(8), Sets: 3, OrderIndex: 2},
+			{ExerciseName: "Something New", Reps: IntPtr(10), Sets: 3, OrderIndex: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	exercises := NewPostgresExerciseStore(db)
+	for i, want := range []string{"Bench Press", "Overhead Press"} {
+		require.NotNil(t, workout.Entries[i].ExerciseID)
+		exercise, err := exercises.GetExerciseByID(int64(*workout.Entries[i].ExerciseID))
+		require.NoError(t, err)
+		assert.Equal(t, want, exercise.Name)
+	}
+	assert.Nil(t, workout.Entries[2].ExerciseID)
+
+	missing := 0
+	_, err = store.AddWorkoutEntry(int64(workout.ID), workout.Version, &WorkoutEntry{ExerciseID: &missing, ExerciseName: "Dips", Reps: IntPtr(10), Sets: 3})
+	assert.ErrorIs(t, err, ErrInvalidData)
+}
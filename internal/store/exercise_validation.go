@@ -0,0 +1,29 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// ValidateExercise checks an exercise before it reaches the database,
+// recording every violation in v.
+func ValidateExercise(v *validator.Validator, exercise *Exercise) {
+	v.Check(validator.NotBlank(exercise.Name), "name", "must be provided")
+	v.Check(validator.MaxChars(exercise.Name, 255), "name", "must not be more than 255 characters long")
+	for i, alias := range exercise.Aliases {
+		v.Check(validator.NotBlank(alias), fmt.Sprintf("aliases[%d]", i), "must not be blank")
+	}
+	v.Check(len(exercise.PrimaryMuscles) > 0, "primary_muscles", "must contain at least one muscle group")
+	for i, muscle := range exercise.PrimaryMuscles {
+		v.Check(slices.Contains(MuscleGroups, muscle), fmt.Sprintf("primary_muscles[%d]", i), "must be one of "+strings.Join(MuscleGroups, ", "))
+	}
+	for i, muscle := range exercise.SecondaryMuscles {
+		v.Check(slices.Contains(MuscleGroups, muscle), fmt.Sprintf("secondary_muscles[%d]", i), "must be one of "+strings.Join(MuscleGroups, ", "))
+	}
+	v.Check(slices.Contains(Equipment, exercise.Equipment), "equipment", "must be one of "+strings.Join(Equipment, ", "))
+	v.Check(slices.Contains(MovementPatterns, exercise.MovementPattern), "movement_pattern", "must be one of "+strings.Join(MovementPatterns, ", "))
+	v.Check(exercise.Type == ExerciseTypeReps || exercise.Type == ExerciseTypeTimed, "type", "must be reps or timed")
+}
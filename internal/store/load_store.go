@@ -0,0 +1,188 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// Sources of the load of a session.
+const (
+	LoadFromSessionRPE = "session_rpe"
+	LoadFromSetRPE     = "set_rpe"
+	LoadFromTonnage    = "tonnage"
+	LoadFromNothing    = "none"
+)
+
+// Zones of the acute:chronic workload ratio. Ratios above 1.3 are the ones
+// associated with a rising injury risk, above 1.5 with a high one.
+const (
+	ZoneUnknown  = "unknown"
+	ZoneLow      = "low"
+	ZoneOptimal  = "optimal"
+	ZoneCaution  = "caution"
+	ZoneHighRisk = "high_risk"
+)
+
+// tonnageLoadDivisor turns the tonnage of a strength session without effort
+// ratings into load units, 20 kg lifted count as much as a minute at RPE 1 so
+// a typical strength session scores about what its session RPE would.
+const tonnageLoadDivisor = 20
+
+// highMonotony is the monotony above which training is considered too
+// uniform to recover from.
+const highMonotony = 2
+
+// SessionLoad is the load score of one workout in arbitrary units: the
+// duration in minutes times the session RPE, or the average RPE of its sets
+// when no session RPE was logged, or else its tonnage over
+// tonnageLoadDivisor.
+type SessionLoad struct {
+	WorkoutID int       `json:"workout_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Load      float64   `json:"load"`
+	Source    string    `json:"source"`
+}
+
+// LoadPoint holds the load metrics of one day. Acute is the load of the seven
+// days up to the day and Chronic the weekly average of the last 28 days, ACWR
+// is their ratio. Monotony is the mean daily load of the last seven days over
+// its standard deviation and Strain the acute load times monotony. Ratios are
+// nil when they are undefined.
+type LoadPoint struct {
+	Date         time.Time `json:"date"`
+	Load         float64   `json:"load"`
+	Acute        float64   `json:"acute"`
+	Chronic      float64   `json:"chronic"`
+	ACWR         *float64  `json:"acwr"`
+	Monotony     *float64  `json:"monotony"`
+	Strain       *float64  `json:"strain"`
+	Zone         string    `json:"zone"`
+	HighMonotony bool      `json:"high_monotony"`
+}
+
+// sessionLoad scores a session from what was logged about it.
+func sessionLoad(durationMinutes int, sessionRPE, setRPE *float64, tonnage float64) (float64, string) {
+	switch {
+	case sessionRPE != nil:
+		return round2(float64(durationMinutes) * *sessionRPE), LoadFromSessionRPE
+	case setRPE != nil:
+		return round2(float64(durationMinutes) * *setRPE), LoadFromSetRPE
+	case tonnage > 0:
+		return round2(tonnage / tonnageLoadDivisor), LoadFromTonnage
+	}
+	return 0, LoadFromNothing
+}
+
+// BuildLoadSeries returns the load metrics of every day from from to to, both
+// UTC days. sessions must reach 27 days before from for the chronic load of
+// the first days to be complete.
+func BuildLoadSeries(sessions []*SessionLoad, from, to time.Time) []LoadPoint {
+	from, to = utcDay(from), utcDay(to)
+	first := from.AddDate(0, 0, -27)
+	days := int(to.Sub(first).Hours()/24) + 1
+	if days < 28 {
+		return []LoadPoint{}
+	}
+	daily := make([]float64, days)
+	for _, session := range sessions {
+		day := int(utcDay(session.CreatedAt).Sub(first).Hours() / 24)
+		if day >= 0 && day < days {
+			daily[day] += session.Load
+		}
+	}
+
+	points := make([]LoadPoint, 0, days-27)
+	for day := 27; day < days; day++ {
+		week := daily[day-6 : day+1]
+		point := LoadPoint{
+			Date:    first.AddDate(0, 0, day),
+			Load:    round2(daily[day]),
+			Acute:   round2(sum(week)),
+			Chronic: round2(sum(daily[day-27:day+1]) / 4),
+			Zone:    ZoneUnknown,
+		}
+		if point.Chronic > 0 {
+			acwr := round2(point.Acute / point.Chronic)
+			point.ACWR = &acwr
+			point.Zone = acwrZone(acwr)
+		}
+		mean := sum(week) / 7
+		variance := 0.0
+		for _, load := range week {
+			variance += (load - mean) * (load - mean)
+		}
+		if deviation := math.Sqrt(variance / 7); deviation > 0 {
+			monotony := round2(mean / deviation)
+			strain := round2(sum(week) * mean / deviation)
+			point.Monotony = &monotony
+			point.Strain = &strain
+			point.HighMonotony = monotony > highMonotony
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func acwrZone(acwr float64) string {
+	switch {
+	case acwr < 0.8:
+		return ZoneLow
+	case acwr <= 1.3:
+		return ZoneOptimal
+	case acwr <= 1.5:
+		return ZoneCaution
+	}
+	return ZoneHighRisk
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+// GetSessionLoads scores every workout a user logged from from up to, but not
+// including, to, oldest first. Warm-ups and sets that were not completed add
+// neither effort nor tonnage.
+func (pg *PostgresAnalyticsStore) GetSessionLoads(userID int, from, to time.Time) ([]*SessionLoad, error) {
+	query := `
+	SELECT w.id, w.created_at, w.duration_minutes, w.session_rpe,
+		(SELECT AVG(COALESCE(s.rpe, GREATEST(10 - s.rir, 1)))
+			FROM workout_sets s
+			INNER JOIN workout_entries e ON e.id = s.entry_id
+			WHERE e.workout_id = w.id AND s.completed AND s.set_type <> 'warmup' AND (s.rpe IS NOT NULL OR s.rir IS NOT NULL)),
+		(SELECT COALESCE(SUM(COALESCE(s.reps, 0) * COALESCE(s.weight, 0) * COALESCE(b.rounds, 1)), 0)
+			FROM workout_sets s
+			INNER JOIN workout_entries e ON e.id = s.entry_id
+			LEFT JOIN workout_blocks b ON b.id = e.block_id
+			WHERE e.workout_id = w.id AND s.completed AND s.set_type <> 'warmup')
+	FROM workouts w
+	WHERE w.user_id = $1 AND w.created_at >= $2 AND w.created_at < $3
+	ORDER BY w.created_at, w.id;
+	`
+	rows, err := pg.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []*SessionLoad{}
+	for rows.Next() {
+		session := &SessionLoad{}
+		var durationMinutes int
+		var sessionRPE, setRPE *float64
+		var tonnage float64
+		err := rows.Scan(&session.WorkoutID, &session.CreatedAt, &durationMinutes, &sessionRPE, &setRPE, &tonnage)
+		if err != nil {
+			return nil, err
+		}
+		session.Load, session.Source = sessionLoad(durationMinutes, sessionRPE, setRPE, tonnage)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
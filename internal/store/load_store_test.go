@@ -0,0 +1,63 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionLoad(t *testing.T) {
+	load, source := sessionLoad(60, FloatPtr(7), FloatPtr(9), 5000)
+	assert.Equal(t, 420.0, load)
+	assert.Equal(t, LoadFromSessionRPE, source)
+
+	load, source = sessionLoad(45, nil, FloatPtr(8.5), 5000)
+	assert.Equal(t, 382.5, load)
+	assert.Equal(t, LoadFromSetRPE, source)
+
+	load, source = sessionLoad(45, nil, nil, 6000)
+	assert.Equal(t, 300.0, load)
+	assert.Equal(t, LoadFromTonnage, source)
+
+	load, source = sessionLoad(30, nil, nil, 0)
+	assert.Equal(t, 0.0, load)
+	assert.Equal(t, LoadFromNothing, source)
+}
+
+func TestBuildLoadSeries(t *testing.T) {
+	from := time.Date(2026, 3, 29, 0, 0, 0, 0, time.UTC)
+	var sessions []*SessionLoad
+	// 300 every other day for four weeks, then 600 every day of the last week
+	for day := -26; day <= 0; day += 2 {
+		sessions = append(sessions, &SessionLoad{CreatedAt: from.AddDate(0, 0, day).Add(18 * time.Hour), Load: 300})
+	}
+	for day := 1; day <= 7; day++ {
+		sessions = append(sessions, &SessionLoad{CreatedAt: from.AddDate(0, 0, day).Add(7 * time.Hour), Load: 600})
+	}
+
+	points := BuildLoadSeries(sessions, from, from.AddDate(0, 0, 7))
+	require.Len(t, points, 8)
+
+	first := points[0]
+	assert.Equal(t, from, first.Date)
+	assert.Equal(t, 300.0, first.Load)
+	assert.Equal(t, 1200.0, first.Acute)
+	assert.Equal(t, 1050.0, first.Chronic)
+	assert.Equal(t, FloatPtr(1.14), first.ACWR)
+	assert.Equal(t, ZoneOptimal, first.Zone)
+	assert.Equal(t, FloatPtr(1.15), first.Monotony)
+	assert.False(t, first.HighMonotony)
+
+	last := points[7]
+	assert.Equal(t, 4200.0, last.Acute)
+	assert.Equal(t, 1875.0, last.Chronic)
+	assert.Equal(t, FloatPtr(2.24), last.ACWR)
+	assert.Equal(t, ZoneHighRisk, last.Zone)
+	// the same load every day has no variation to divide by
+	assert.Nil(t, last.Monotony)
+	assert.Nil(t, last.Strain)
+
+	assert.Equal(t, ZoneUnknown, BuildLoadSeries(nil, from, from)[0].Zone)
+}
@@ -0,0 +1,450 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Progression types of a program day.
+const (
+	ProgressionNone    = "none"
+	ProgressionLinear  = "linear"
+	ProgressionPercent = "percent"
+)
+
+// ProgressionTypes holds the values accepted for Progression.Type.
+var ProgressionTypes = []string{ProgressionNone, ProgressionLinear, ProgressionPercent}
+
+// Program is a multi-week plan of sessions, each taken from a template of the
+// program's owner. Public programs can be enrolled in by every user.
+type Program struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"user_id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	IsPublic    bool          `json:"is_public"`
+	Weeks       []ProgramWeek `json:"weeks"`
+	Version     int           `json:"version"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+type ProgramWeek struct {
+	ID         int          `json:"id"`
+	WeekNumber int          `json:"week_number"`
+	Notes      string       `json:"notes"`
+	Days       []ProgramDay `json:"days"`
+}
+
+// ProgramDay schedules a template on one day of a week, DayNumber runs from 1
+// for the first day of the week to 7.
+type ProgramDay struct {
+	ID          int         `json:"id"`
+	DayNumber   int         `json:"day_number"`
+	TemplateID  int         `json:"template_id"`
+	Progression Progression `json:"progression"`
+}
+
+// Progression adjusts the weights of a template from week to week. Linear
+// progression adds Amount to the weights for every week after the first,
+// percent progression sets the weights to Amount percent of the training max
+// of each exercise.
+type Progression struct {
+	Type   string  `json:"type"`
+	Amount float64 `json:"amount"`
+}
+
+// Enrollment puts a user on a program from StartDate on. TrainingMaxes holds
+// the training max per exercise name used by percent progression.
+type Enrollment struct {
+	ID            int                `json:"id"`
+	UserID        int                `json:"user_id"`
+	ProgramID     *int               `json:"program_id"`
+	StartDate     time.Time          `json:"start_date"`
+	TrainingMaxes map[string]float64 `json:"training_maxes"`
+	Sessions      []*PlannedWorkout  `json:"sessions"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+type PostgresProgramStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProgramStore(db *sql.DB) *PostgresProgramStore {
+	return &PostgresProgramStore{db: db}
+}
+
+type ProgramStore interface {
+	CreateProgram(program *Program) error
+	GetProgramByID(id int64) (*Program, error)
+	ListPrograms(userID int) ([]*Program, error)
+	UpdateProgram(program *Program) error
+	DeleteProgram(id int64) error
+	Enroll(enrollment *Enrollment) error
+}
+
+func (pg *PostgresProgramStore) CreateProgram(program *Program) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO programs (user_id, title, description, is_public)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, version, created_at, updated_at;
+	`
+	err = tx.QueryRow(query, program.UserID, program.Title, program.Description, program.IsPublic).Scan(&program.ID, &program.Version, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+	err = insertProgramWeeks(tx, program)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) GetProgramByID(id int64) (*Program, error) {
+	program := &Program{}
+	query := `
+	SELECT id, user_id, title, description, is_public, version, created_at, updated_at
+	FROM programs WHERE id = $1;
+	`
+	err := pg.db.QueryRow(query, id).Scan(&program.ID, &program.UserID, &program.Title, &program.Description, &program.IsPublic, &program.Version, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	err = loadProgramWeeks(pg.db, []*Program{program})
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// ListPrograms returns the programs of a user and the public programs of
+// everyone else, ordered by title.
+func (pg *PostgresProgramStore) ListPrograms(userID int) ([]*Program, error) {
+	query := `
+	SELECT id, user_id, title, description, is_public, version, created_at, updated_at
+	FROM programs WHERE user_id = $1 OR is_public
+	ORDER BY title, id;
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []*Program{}
+	for rows.Next() {
+		program := &Program{}
+		err := rows.Scan(&program.ID, &program.UserID, &program.Title, &program.Description, &program.IsPublic, &program.Version, &program.CreatedAt, &program.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = loadProgramWeeks(pg.db, programs)
+	if err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+// UpdateProgram saves a program and replaces its weeks and days. Users who
+// already enrolled keep the sessions they were given.
+func (pg *PostgresProgramStore) UpdateProgram(program *Program) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE programs SET title = $1, description = $2, is_public = $3, version = version + 1, updated_at = NOW()
+	WHERE id = $4 AND version = $5
+	RETURNING version, updated_at;
+	`
+	err = tx.QueryRow(query, program.Title, program.Description, program.IsPublic, program.ID, program.Version).Scan(&program.Version, &program.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return mapError(err)
+	}
+	_, err = tx.Exec(`DELETE FROM program_weeks WHERE program_id = $1;`, program.ID)
+	if err != nil {
+		return err
+	}
+	err = insertProgramWeeks(tx, program)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteProgram removes a program, enrollments and their sessions are kept.
+func (pg *PostgresProgramStore) DeleteProgram(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM programs WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// programSession is one day of a program together with the template it
+// schedules.
+type programSession struct {
+	week     int
+	day      ProgramDay
+	template *WorkoutTemplate
+}
+
+// Enroll schedules every day of the program on a calendar date counted from
+// the start date, prescribing the entries of each session from its template
+// and progression.
+func (pg *PostgresProgramStore) Enroll(enrollment *Enrollment) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	trainingMaxes, err := json.Marshal(enrollment.TrainingMaxes)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO program_enrollments (user_id, program_id, start_date, training_maxes)
+	VALUES ($1, $2, $3, $4::jsonb)
+	RETURNING id, created_at;
+	`
+	err = tx.QueryRow(query, enrollment.UserID, enrollment.ProgramID, enrollment.StartDate, string(trainingMaxes)).Scan(&enrollment.ID, &enrollment.CreatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+
+	sessions, err := programSessions(tx, *enrollment.ProgramID)
+	if err != nil {
+		return err
+	}
+	lookup := make(map[string]float64, len(enrollment.TrainingMaxes))
+	for name, weight := range enrollment.TrainingMaxes {
+		lookup[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	now := time.Now()
+	enrollment.Sessions = make([]*PlannedWorkout, 0, len(sessions))
+	for _, session := range sessions {
+		planned := &PlannedWorkout{
+			UserID:          enrollment.UserID,
+			EnrollmentID:    enrollment.ID,
+			ProgramID:       enrollment.ProgramID,
+			ProgramDayID:    &session.day.ID,
+			Title:           session.template.Title,
+			Description:     session.template.Description,
+			DurationMinutes: session.template.DurationMinutes,
+			WeekNumber:      session.week,
+			DayNumber:       session.day.DayNumber,
+			ScheduledOn:     enrollment.StartDate.AddDate(0, 0, (session.week-1)*7+session.day.DayNumber-1),
+			Entries:         planSession(session.template.Entries, session.day.Progression, session.week, lookup),
+		}
+		err = insertPlannedWorkout(tx, planned)
+		if err != nil {
+			return err
+		}
+		planned.Status = plannedStatus(planned.ScheduledOn, false, now)
+		enrollment.Sessions = append(enrollment.Sessions, planned)
+	}
+	return tx.Commit()
+}
+
+// programSessions loads every day of a program in calendar order together
+// with its template.
+func programSessions(tx *sql.Tx, programID int) ([]programSession, error) {
+	query := `
+	SELECT w.week_number, d.id, d.day_number, d.template_id, d.progression_type, d.progression_amount,
+		t.id, t.user_id, t.title, t.description, t.duration_minutes
+	FROM program_weeks w
+	INNER JOIN program_days d ON d.week_id = w.id
+	INNER JOIN workout_templates t ON t.id = d.template_id
+	WHERE w.program_id = $1
+	ORDER BY w.week_number, d.day_number;
+	`
+	rows, err := tx.Query(query, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []programSession{}
+	templates := map[int]*WorkoutTemplate{}
+	for rows.Next() {
+		var session programSession
+		var template WorkoutTemplate
+		err := rows.Scan(&session.week, &session.day.ID, &session.day.DayNumber, &session.day.TemplateID, &session.day.Progression.Type, &session.day.Progression.Amount,
+			&template.ID, &template.UserID, &template.Title, &template.Description, &template.DurationMinutes)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := templates[template.ID]; !ok {
+			templates[template.ID] = &template
+		}
+		session.template = templates[template.ID]
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// the rows have to be closed before the transaction runs another query
+	rows.Close()
+
+	list := make([]*WorkoutTemplate, 0, len(templates))
+	for _, template := range templates {
+		list = append(list, template)
+	}
+	err = loadTemplateEntries(tx, list)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// planSession prescribes the entries of a template for the given week of a
+// program. trainingMaxes is keyed by lower case exercise name, entries
+// without a training max keep the weight of the template under percent
+// progression.
+func planSession(entries []TemplateEntry, progression Progression, week int, trainingMaxes map[string]float64) []PlannedEntry {
+	planned := make([]PlannedEntry, 0, len(entries))
+	for _, entry := range entries {
+		weight := entry.WeightMin
+		switch progression.Type {
+		case ProgressionLinear:
+			if weight != nil {
+				progressed := round2(*weight + progression.Amount*float64(week-1))
+				weight = &progressed
+			}
+		case ProgressionPercent:
+			trainingMax, ok := trainingMaxes[strings.ToLower(strings.TrimSpace(entry.ExerciseName))]
+			if ok && entry.RepsMin != nil {
+				prescribed := round2(trainingMax * progression.Amount / 100)
+				weight = &prescribed
+			}
+		}
+		planned = append(planned, PlannedEntry{
+			ExerciseID:      entry.ExerciseID,
+			ExerciseName:    entry.ExerciseName,
+			Sets:            entry.SetsMin,
+			Reps:            entry.RepsMin,
+			Weight:          weight,
+			DurationSeconds: entry.DurationSeconds,
+			Notes:           entry.Notes,
+			OrderIndex:      entry.OrderIndex,
+		})
+	}
+	return planned
+}
+
+// insertProgramWeeks stores the weeks and days of a program. Days can only
+// schedule templates of the program's owner.
+func insertProgramWeeks(tx *sql.Tx, program *Program) error {
+	weekQuery := `
+	INSERT INTO program_weeks (program_id, week_number, notes)
+	VALUES ($1, $2, $3)
+	RETURNING id;
+	`
+	dayQuery := `
+	INSERT INTO program_days (week_id, day_number, template_id, progression_type, progression_amount)
+	SELECT $1, $2, $3, $4, $5
+	WHERE EXISTS (SELECT 1 FROM workout_templates WHERE id = $3 AND user_id = $6)
+	RETURNING id;
+	`
+	for i := range program.Weeks {
+		week := &program.Weeks[i]
+		err := tx.QueryRow(weekQuery, program.ID, week.WeekNumber, week.Notes).Scan(&week.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		for j := range week.Days {
+			day := &week.Days[j]
+			err := tx.QueryRow(dayQuery, week.ID, day.DayNumber, day.TemplateID, day.Progression.Type, day.Progression.Amount, program.UserID).Scan(&day.ID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return &ConstraintError{Constraint: "program_days_template_id_fkey"}
+			}
+			if err != nil {
+				return mapError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadProgramWeeks fetches the weeks and days of all given programs in a
+// single query.
+func loadProgramWeeks(q querier, programs []*Program) error {
+	if len(programs) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(programs))
+	byID := make(map[int64]*Program, len(programs))
+	for i, program := range programs {
+		program.Weeks = []ProgramWeek{}
+		ids[i] = int64(program.ID)
+		byID[int64(program.ID)] = program
+	}
+
+	query := `
+	SELECT w.program_id, w.id, w.week_number, w.notes, d.id, d.day_number, d.template_id, d.progression_type, d.progression_amount
+	FROM program_weeks w
+	LEFT JOIN program_days d ON d.week_id = w.id
+	WHERE w.program_id = ANY($1)
+	ORDER BY w.program_id, w.week_number, d.day_number;
+	`
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var programID int64
+		var week ProgramWeek
+		var dayID, dayNumber, templateID *int
+		var progressionType *string
+		var progressionAmount *float64
+		err := rows.Scan(&programID, &week.ID, &week.WeekNumber, &week.Notes, &dayID, &dayNumber, &templateID, &progressionType, &progressionAmount)
+		if err != nil {
+			return err
+		}
+		program := byID[programID]
+		if len(program.Weeks) == 0 || program.Weeks[len(program.Weeks)-1].ID != week.ID {
+			week.Days = []ProgramDay{}
+			program.Weeks = append(program.Weeks, week)
+		}
+		if dayID == nil {
+			continue
+		}
+		current := &program.Weeks[len(program.Weeks)-1]
+		current.Days = append(current.Days, ProgramDay{
+			ID:          *dayID,
+			DayNumber:   *dayNumber,
+			TemplateID:  *templateID,
+			Progression: Progression{Type: *progressionType, Amount: *progressionAmount},
+		})
+	}
+	return rows.Err()
+}
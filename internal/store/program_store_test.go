@@ -0,0 +1,64 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanSession(t *testing.T) {
+	entries := []TemplateEntry{
+		{ExerciseName: "Squat", SetsMin: 5, SetsMax: IntPtr(6), RepsMin: IntPtr(5), WeightMin: FloatPtr(100), OrderIndex: 1},
+		{ExerciseName: "Plank", SetsMin: 3, DurationSeconds: IntPtr(60), OrderIndex: 2},
+		{ExerciseName: "Chin Up", SetsMin: 3, RepsMin: IntPtr(8), OrderIndex: 3},
+	}
+	trainingMaxes := map[string]float64{"squat": 140}
+
+	tests := []struct {
+		name        string
+		progression Progression
+		week        int
+		weights     []*float64
+	}{
+		{"None", Progression{Type: ProgressionNone}, 3, []*float64{FloatPtr(100), nil, nil}},
+		{"Linear First Week", Progression{Type: ProgressionLinear, Amount: 2.5}, 1, []*float64{FloatPtr(100), nil, nil}},
+		{"Linear Fourth Week", Progression{Type: ProgressionLinear, Amount: 2.5}, 4, []*float64{FloatPtr(107.5), nil, nil}},
+		{"Percent Of Training Max", Progression{Type: ProgressionPercent, Amount: 72.5}, 2, []*float64{FloatPtr(101.5), nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planned := planSession(entries, tt.progression, tt.week, trainingMaxes)
+			assert.Len(t, planned, len(entries))
+			for i, entry := range planned {
+				assert.Equal(t, entries[i].SetsMin, entry.Sets)
+				assert.Equal(t, entries[i].RepsMin, entry.Reps)
+				assert.Equal(t, tt.weights[i], entry.Weight)
+			}
+		})
+	}
+}
+
+func TestValidateProgram(t *testing.T) {
+	v := validator.New()
+	ValidateProgram(v, &Program{
+		Title: "Strength Block",
+		Weeks: []ProgramWeek{
+			{WeekNumber: 1, Days: []ProgramDay{
+				{DayNumber: 1, TemplateID: 4, Progression: Progression{Type: ProgressionLinear, Amount: 2.5}},
+				{DayNumber: 1, TemplateID: 5, Progression: Progression{Type: ProgressionPercent, Amount: 120}},
+			}},
+			{WeekNumber: 1, Days: []ProgramDay{
+				{DayNumber: 8, Progression: Progression{Type: "wave"}},
+			}},
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"weeks[0].days[1].day_number":         "must be unique within the week",
+		"weeks[0].days[1].progression.amount": "must be greater than zero and at most 100",
+		"weeks[1].week_number":                "must be unique",
+		"weeks[1].days[0].day_number":         "must be between 1 and 7",
+		"weeks[1].days[0].template_id":        "must be provided",
+		"weeks[1].days[0].progression.type":   "must be one of none, linear, percent",
+	}, v.Errors)
+}
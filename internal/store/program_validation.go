@@ -0,0 +1,50 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// maxProgramWeeks bounds the length of a program to a year.
+const maxProgramWeeks = 52
+
+// ValidateProgram checks a program with all of its weeks and days before it
+// reaches the database, recording every violation in v.
+func ValidateProgram(v *validator.Validator, program *Program) {
+	v.Check(validator.NotBlank(program.Title), "title", "must be provided")
+	v.Check(validator.MaxChars(program.Title, 255), "title", "must not be more than 255 characters long")
+	v.Check(len(program.Weeks) > 0, "weeks", "must contain at least one week")
+
+	weeks := make(map[int]bool, len(program.Weeks))
+	for i, week := range program.Weeks {
+		key := fmt.Sprintf("weeks[%d]", i)
+		v.Check(week.WeekNumber >= 1 && week.WeekNumber <= maxProgramWeeks, key+".week_number", fmt.Sprintf("must be between 1 and %d", maxProgramWeeks))
+		v.Check(!weeks[week.WeekNumber], key+".week_number", "must be unique")
+		weeks[week.WeekNumber] = true
+
+		days := make(map[int]bool, len(week.Days))
+		for j, day := range week.Days {
+			dayKey := fmt.Sprintf("%s.days[%d]", key, j)
+			v.Check(day.DayNumber >= 1 && day.DayNumber <= 7, dayKey+".day_number", "must be between 1 and 7")
+			v.Check(!days[day.DayNumber], dayKey+".day_number", "must be unique within the week")
+			days[day.DayNumber] = true
+			v.Check(day.TemplateID > 0, dayKey+".template_id", "must be provided")
+			validateProgression(v, dayKey+".progression", day.Progression)
+		}
+	}
+}
+
+func validateProgression(v *validator.Validator, key string, progression Progression) {
+	switch progression.Type {
+	case ProgressionNone:
+		v.Check(progression.Amount == 0, key+".amount", "must be zero without progression")
+	case ProgressionLinear:
+		v.Check(progression.Amount >= 0, key+".amount", "must not be negative")
+	case ProgressionPercent:
+		v.Check(progression.Amount > 0 && progression.Amount <= 100, key+".amount", "must be greater than zero and at most 100")
+	default:
+		v.AddError(key+".type", "must be one of "+strings.Join(ProgressionTypes, ", "))
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+// personal_records started out empty, so the workouts logged before records
+// were tracked never counted. Detecting records takes the formulas and set
+// rules of detectRecords, so this migration runs refreshRecords in Go instead
+// of repeating them in SQL. It comes after 0016 because records are read from
+// the sets of an entry.
+func init() {
+	goose.AddNamedMigrationContext("0022_seed_personal_records.go", seedPersonalRecords, nil)
+}
+
+// seedPersonalRecords computes the records of every user on every exercise
+// they logged.
+func seedPersonalRecords(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	SELECT DISTINCT w.user_id, COALESCE(e.exercise_id, 0), CASE WHEN e.exercise_id IS NULL THEN LOWER(TRIM(e.exercise_name)) ELSE '' END
+	FROM workout_entries e
+	INNER JOIN workouts w ON w.id = e.workout_id;
+	`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	keys := map[int][]exerciseKey{}
+	for rows.Next() {
+		var userID int
+		var key exerciseKey
+		err := rows.Scan(&userID, &key.ExerciseID, &key.Name)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		keys[userID] = append(keys[userID], key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for userID, userKeys := range keys {
+		_, err := refreshRecords(tx, userID, 0, userKeys)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
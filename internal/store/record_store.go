@@ -0,0 +1,425 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RecordHeaviestWeight  = "heaviest_weight"
+	RecordEstimated1RM    = "estimated_1rm"
+	RecordMostReps        = "most_reps"
+	RecordLongestDuration = "longest_duration"
+
+	FormulaEpley   = "epley"
+	FormulaBrzycki = "brzycki"
+
+	SexMale   = "male"
+	SexFemale = "female"
+)
+
+// OneRepMaxFormulas holds the formulas accepted to estimate a one rep max.
+var OneRepMaxFormulas = []string{FormulaEpley, FormulaBrzycki}
+
+// PersonalRecord is a best performance of a user on one exercise. Weight and
+// Reps describe the set behind the record, Formula is only set for estimated
+// one rep maxes. Weight records also carry the body weight of the user when
+// they were set and the Wilks and DOTS scores of the lift.
+type PersonalRecord struct {
+	ID           int       `json:"id"`
+	ExerciseID   *int      `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	Type         string    `json:"type"`
+	Formula      string    `json:"formula,omitempty"`
+	Value        float64   `json:"value"`
+	Weight       *float64  `json:"weight,omitempty"`
+	Reps         *int      `json:"reps,omitempty"`
+	WorkoutID    int       `json:"workout_id"`
+	EntryID      int       `json:"entry_id"`
+	AchievedAt   time.Time `json:"achieved_at"`
+	BodyWeight   *float64  `json:"body_weight,omitempty"`
+	Wilks        *float64  `json:"wilks,omitempty"`
+	DOTS         *float64  `json:"dots,omitempty"`
+}
+
+// EstimateOneRepMax estimates the weight that could be lifted once from a set
+// of reps at weight. Brzycki is undefined from 37 reps on, ok is false then.
+func EstimateOneRepMax(formula string, weight float64, reps int) (float64, bool) {
+	if reps < 1 || weight <= 0 {
+		return 0, false
+	}
+	if reps == 1 {
+		return weight, true
+	}
+	switch formula {
+	case FormulaEpley:
+		return round2(weight * (1 + float64(reps)/30)), true
+	case FormulaBrzycki:
+		if reps >= 37 {
+			return 0, false
+		}
+		return round2(weight * 36 / float64(37-reps)), true
+	}
+	return 0, false
+}
+
+// wilksCoefficients and dotsCoefficients are the polynomial coefficients of
+// the relative strength scores, lowest degree first, with the body weights in
+// kg they are defined for.
+var (
+	wilksCoefficients = map[string]strengthPolynomial{
+		SexMale:   {40, 201.9, []float64{-216.0475144, 16.2606339, -0.002388645, -0.00113732, 7.01863e-06, -1.291e-08}},
+		SexFemale: {26.51, 154.53, []float64{594.31747775582, -27.23842536447, 0.82112226871, -0.00930733913, 4.731582e-05, -9.054e-08}},
+	}
+	dotsCoefficients = map[string]strengthPolynomial{
+		SexMale:   {40, 210, []float64{-307.75076, 24.0900756, -0.1918759221, 0.0007391293, -0.000001093}},
+		SexFemale: {40, 150, []float64{-57.96288, 13.6175032, -0.1126655495, 0.0005158568, -0.0000010706}},
+	}
+)
+
+type strengthPolynomial struct {
+	MinBodyWeight float64
+	MaxBodyWeight float64
+	Coefficients  []float64
+}
+
+// score returns lifted x 500 over the polynomial of the body weight, which is
+// clamped to the range the polynomial is defined for.
+func (p strengthPolynomial) score(bodyWeight, lifted float64) float64 {
+	x := min(max(bodyWeight, p.MinBodyWeight), p.MaxBodyWeight)
+	denominator, power := 0.0, 1.0
+	for _, c := range p.Coefficients {
+		denominator += c * power
+		power *= x
+	}
+	return round2(lifted * 500 / denominator)
+}
+
+// WilksScore scores the weight lifted by a lifter of sex and body weight, both
+// in kg, with the original Wilks formula. ok is false for unknown sexes.
+func WilksScore(sex string, bodyWeight, lifted float64) (float64, bool) {
+	p, ok := wilksCoefficients[sex]
+	if !ok || bodyWeight <= 0 {
+		return 0, false
+	}
+	return p.score(bodyWeight, lifted), true
+}
+
+// DOTSScore is WilksScore with the DOTS formula.
+func DOTSScore(sex string, bodyWeight, lifted float64) (float64, bool) {
+	p, ok := dotsCoefficients[sex]
+	if !ok || bodyWeight <= 0 {
+		return 0, false
+	}
+	return p.score(bodyWeight, lifted), true
+}
+
+// scoreStrength sets the body weight and relative strength scores of weight
+// records, the ones of a lifter whose body weight or sex is unknown have none.
+func (record *PersonalRecord) scoreStrength(sex string, bodyWeight *float64) {
+	if bodyWeight == nil || (record.Type != RecordHeaviestWeight && record.Type != RecordEstimated1RM) {
+		return
+	}
+	wilks, ok := WilksScore(sex, *bodyWeight, record.Value)
+	if !ok {
+		return
+	}
+	dots, _ := DOTSScore(sex, *bodyWeight, record.Value)
+	record.BodyWeight = bodyWeight
+	record.Wilks = &wilks
+	record.DOTS = &dots
+}
+
+// round2 rounds to the two decimals the records are stored with.
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// recordEntry is a workout entry as far as records are concerned.
+type recordEntry struct {
+	EntryID         int
+	WorkoutID       int
+	AchievedAt      time.Time
+	ExerciseID      *int
+	ExerciseName    string
+	Reps            *int
+	Weight          *float64
+	DurationSeconds *int
+}
+
+// detectRecords walks the entries of one exercise in chronological order and
+// returns every record they set, each one beating the record before it.
+func detectRecords(entries []recordEntry) []PersonalRecord {
+	var records []PersonalRecord
+	best := map[string]float64{}
+	set := func(key string, value float64, entry recordEntry, record PersonalRecord) {
+		if current, ok := best[key]; ok && value <= current {
+			return
+		}
+		best[key] = value
+		record.ExerciseID = entry.ExerciseID
+		record.ExerciseName = entry.ExerciseName
+		record.Value = value
+		record.WorkoutID = entry.WorkoutID
+		record.EntryID = entry.EntryID
+		record.AchievedAt = entry.AchievedAt
+		records = append(records, record)
+	}
+
+	for _, entry := range entries {
+		if entry.Weight != nil && *entry.Weight > 0 {
+			set(RecordHeaviestWeight, *entry.Weight, entry, PersonalRecord{Type: RecordHeaviestWeight, Weight: entry.Weight, Reps: entry.Reps})
+		}
+		if entry.Reps != nil {
+			// a missing weight is a bodyweight set, which has its own rep record
+			weightKey := "bodyweight"
+			if entry.Weight != nil {
+				weightKey = strconv.FormatFloat(*entry.Weight, 'f', 2, 64)
+			}
+			set(RecordMostReps+"@"+weightKey, float64(*entry.Reps), entry, PersonalRecord{Type: RecordMostReps, Weight: entry.Weight, Reps: entry.Reps})
+
+			for _, formula := range OneRepMaxFormulas {
+				if entry.Weight == nil {
+					break
+				}
+				estimate, ok := EstimateOneRepMax(formula, *entry.Weight, *entry.Reps)
+				if ok {
+					set(RecordEstimated1RM+"@"+formula, estimate, entry, PersonalRecord{Type: RecordEstimated1RM, Formula: formula, Weight: entry.Weight, Reps: entry.Reps})
+				}
+			}
+		}
+		if entry.DurationSeconds != nil {
+			set(RecordLongestDuration, float64(*entry.DurationSeconds), entry, PersonalRecord{Type: RecordLongestDuration, Weight: entry.Weight})
+		}
+	}
+	return records
+}
+
+// exerciseKey identifies an exercise for records: the catalog exercise when
+// the entry is linked to one, its normalized name otherwise.
+type exerciseKey struct {
+	ExerciseID int
+	Name       string
+}
+
+// exerciseKeyCondition matches the rows of an exerciseKey, whose ID and name
+// are bound to the given placeholders.
+func exerciseKeyCondition(alias string, idArg, nameArg int) string {
+	return fmt.Sprintf(`CASE WHEN $%[2]d::bigint = 0
+		THEN %[1]s.exercise_id IS NULL AND LOWER(TRIM(%[1]s.exercise_name)) = $%[3]d
+		ELSE %[1]s.exercise_id = $%[2]d END`, alias, idArg, nameArg)
+}
+
+// workoutExerciseKeys returns the exercises used by the entries of a workout.
+func workoutExerciseKeys(tx *sql.Tx, workoutID int64) ([]exerciseKey, error) {
+	query := `
+	SELECT DISTINCT COALESCE(exercise_id, 0), CASE WHEN exercise_id IS NULL THEN LOWER(TRIM(exercise_name)) ELSE '' END
+	FROM workout_entries WHERE workout_id = $1;
+	`
+	rows, err := tx.Query(query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []exerciseKey
+	for rows.Next() {
+		var key exerciseKey
+		err := rows.Scan(&key.ExerciseID, &key.Name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// refreshWorkoutRecords refreshes the records on the exercises a workout used
+// before a change, passed as before, and on those it uses after it.
+func refreshWorkoutRecords(tx *sql.Tx, userID int, workoutID int64, before []exerciseKey) ([]PersonalRecord, error) {
+	after, err := workoutExerciseKeys(tx, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	return refreshRecords(tx, userID, int(workoutID), append(before, after...))
+}
+
+// refreshRecords recomputes the records of a user on the given exercises from
+// all of their entries, and returns the records set by workoutID that did not
+// exist before.
+func refreshRecords(tx *sql.Tx, userID int, workoutID int, keys []exerciseKey) ([]PersonalRecord, error) {
+	newRecords := []PersonalRecord{}
+	seen := map[exerciseKey]bool{}
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		before, err := recordSignatures(tx, userID, key)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := loadRecordEntries(tx, userID, key)
+		if err != nil {
+			return nil, err
+		}
+
+		query := `DELETE FROM personal_records r WHERE r.user_id = $1 AND ` + exerciseKeyCondition("r", 2, 3) + `;`
+		_, err = tx.Exec(query, userID, key.ExerciseID, key.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range detectRecords(entries) {
+			query := `
+			INSERT INTO personal_records (user_id, exercise_id, exercise_name, type, formula, value, weight, reps, workout_id, entry_id, achieved_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11)
+			RETURNING id;
+			`
+			err := tx.QueryRow(query, userID, record.ExerciseID, record.ExerciseName, record.Type, record.Formula, record.Value, record.Weight, record.Reps, record.WorkoutID, record.EntryID, record.AchievedAt).Scan(&record.ID)
+			if err != nil {
+				return nil, err
+			}
+			if record.WorkoutID == workoutID && !before[record.signature()] {
+				newRecords = append(newRecords, record)
+			}
+		}
+	}
+	return newRecords, nil
+}
+
+// signature identifies a record across recomputations.
+func (r *PersonalRecord) signature() string {
+	weight := ""
+	if r.Weight != nil {
+		weight = strconv.FormatFloat(*r.Weight, 'f', 2, 64)
+	}
+	return fmt.Sprintf("%d|%s|%s|%s|%.2f", r.EntryID, r.Type, r.Formula, weight, r.Value)
+}
+
+func recordSignatures(tx *sql.Tx, userID int, key exerciseKey) (map[string]bool, error) {
+	query := `
+	SELECT r.entry_id, r.type, COALESCE(r.formula, ''), r.weight, r.value
+	FROM personal_records r
+	WHERE r.user_id = $1 AND ` + exerciseKeyCondition("r", 2, 3) + `;`
+	rows, err := tx.Query(query, userID, key.ExerciseID, key.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	signatures := map[string]bool{}
+	for rows.Next() {
+		var record PersonalRecord
+		err := rows.Scan(&record.EntryID, &record.Type, &record.Formula, &record.Weight, &record.Value)
+		if err != nil {
+			return nil, err
+		}
+		signatures[record.signature()] = true
+	}
+	return signatures, rows.Err()
+}
+
+// loadRecordEntries returns every set of the exercise that counts towards
+// records, one recordEntry per set. Warm-ups and sets that were not completed
+// do not count.
+func loadRecordEntries(tx *sql.Tx, userID int, key exerciseKey) ([]recordEntry, error) {
+	query := `
+	SELECT e.id, e.workout_id, w.created_at, e.exercise_id, e.exercise_name, s.reps, s.weight, s.duration_seconds
+	FROM workout_sets s
+	INNER JOIN workout_entries e ON e.id = s.entry_id
+	INNER JOIN workouts w ON w.id = e.workout_id
+	WHERE w.user_id = $1 AND s.completed AND s.set_type <> 'warmup' AND ` + exerciseKeyCondition("e", 2, 3) + `
+	ORDER BY w.created_at, w.id, e.order_index, s.set_number;
+	`
+	rows, err := tx.Query(query, userID, key.ExerciseID, key.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []recordEntry
+	for rows.Next() {
+		var entry recordEntry
+		err := rows.Scan(&entry.EntryID, &entry.WorkoutID, &entry.AchievedAt, &entry.ExerciseID, &entry.ExerciseName, &entry.Reps, &entry.Weight, &entry.DurationSeconds)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+type PostgresRecordStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRecordStore(db *sql.DB) *PostgresRecordStore {
+	return &PostgresRecordStore{db: db}
+}
+
+type RecordStore interface {
+	GetCurrentRecords(userID int64, formula string) ([]*PersonalRecord, error)
+	GetRecordHistory(userID int64, exercise string, formula string) ([]*PersonalRecord, error)
+}
+
+// recordColumns also selects the body weight of the user when the record was
+// set and their sex, to score the strength of weight records.
+var recordColumns = `r.id, r.exercise_id, COALESCE(x.name, r.exercise_name), r.type, COALESCE(r.formula, ''), r.value, r.weight, r.reps, r.workout_id, r.entry_id, r.achieved_at, ` +
+	bodyWeightAt("r.user_id", "r.achieved_at") + `, (SELECT COALESCE(sex, '') FROM users WHERE id = r.user_id)`
+
+// GetCurrentRecords returns the standing records of a user on every exercise,
+// with the estimated one rep maxes of formula only. Rep records stand per
+// weight.
+func (pg *PostgresRecordStore) GetCurrentRecords(userID int64, formula string) ([]*PersonalRecord, error) {
+	query := `
+	SELECT ` + recordColumns + ` FROM (
+		SELECT DISTINCT ON (COALESCE(exercise_id, 0), CASE WHEN exercise_id IS NULL THEN LOWER(TRIM(exercise_name)) END, type, CASE WHEN type = 'most_reps' THEN weight END) *
+		FROM personal_records
+		WHERE user_id = $1 AND (type <> 'estimated_1rm' OR formula = $2)
+		ORDER BY COALESCE(exercise_id, 0), CASE WHEN exercise_id IS NULL THEN LOWER(TRIM(exercise_name)) END, type, CASE WHEN type = 'most_reps' THEN weight END, achieved_at DESC, id DESC
+	) r
+	LEFT JOIN exercises x ON x.id = r.exercise_id
+	ORDER BY COALESCE(x.name, r.exercise_name), r.type, r.weight;
+	`
+	return pg.queryRecords(query, userID, formula)
+}
+
+// GetRecordHistory returns every record a user set on one exercise, oldest
+// first. exercise is a catalog exercise ID or the name of unlinked entries.
+func (pg *PostgresRecordStore) GetRecordHistory(userID int64, exercise string, formula string) ([]*PersonalRecord, error) {
+	key := exerciseKey{Name: strings.ToLower(strings.TrimSpace(exercise))}
+	if id, err := strconv.Atoi(exercise); err == nil {
+		key = exerciseKey{ExerciseID: id}
+	}
+	query := `
+	SELECT ` + recordColumns + `
+	FROM personal_records r
+	LEFT JOIN exercises x ON x.id = r.exercise_id
+	WHERE r.user_id = $1 AND (r.type <> 'estimated_1rm' OR r.formula = $4) AND ` + exerciseKeyCondition("r", 2, 3) + `
+	ORDER BY r.achieved_at, r.id;
+	`
+	return pg.queryRecords(query, userID, key.ExerciseID, key.Name, formula)
+}
+
+func (pg *PostgresRecordStore) queryRecords(query string, args ...interface{}) ([]*PersonalRecord, error) {
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []*PersonalRecord{}
+	for rows.Next() {
+		record := &PersonalRecord{}
+		var bodyWeight *float64
+		var sex string
+		err := rows.Scan(&record.ID, &record.ExerciseID, &record.ExerciseName, &record.Type, &record.Formula, &record.Value, &record.Weight, &record.Reps, &record.WorkoutID, &record.EntryID, &record.AchievedAt, &bodyWeight, &sex)
+		if err != nil {
+			return nil, err
+		}
+		record.scoreStrength(sex, bodyWeight)
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
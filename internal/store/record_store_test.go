@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateOneRepMax(t *testing.T) {
+	estimate, ok := EstimateOneRepMax(FormulaEpley, 100, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 116.67, estimate)
+
+	estimate, ok = EstimateOneRepMax(FormulaBrzycki, 100, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 112.5, estimate)
+
+	estimate, ok = EstimateOneRepMax(FormulaBrzycki, 100, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, estimate)
+
+	_, ok = EstimateOneRepMax(FormulaBrzycki, 20, 40)
+	assert.False(t, ok)
+}
+
+func TestDetectRecords(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []recordEntry{
+		{EntryID: 1, WorkoutID: 1, AchievedAt: day, ExerciseName: "Squat", Reps: IntPtr(5), Weight: FloatPtr(100)},
+		// heavier but the estimate does not beat 5x100
+		{EntryID: 2, WorkoutID: 2, AchievedAt: day.AddDate(0, 0, 2), ExerciseName: "Squat", Reps: IntPtr(1), Weight: FloatPtr(110)},
+		// equal to an existing record, which does not count
+		{EntryID: 3, WorkoutID: 3, AchievedAt: day.AddDate(0, 0, 4), ExerciseName: "Squat", Reps: IntPtr(5), Weight: FloatPtr(100)},
+		{EntryID: 4, WorkoutID: 4, AchievedAt: day.AddDate(0, 0, 6), ExerciseName: "Squat", Reps: IntPtr(8), Weight: FloatPtr(100)},
+	}
+
+	type got struct {
+		Entry   int
+		Type    string
+		Formula string
+		Value   float64
+	}
+	var records []got
+	for _, record := range detectRecords(entries) {
+		records = append(records, got{record.EntryID, record.Type, record.Formula, record.Value})
+	}
+	assert.Equal(t, []got{
+		{1, RecordHeaviestWeight, "", 100},
+		{1, RecordMostReps, "", 5},
+		{1, RecordEstimated1RM, FormulaEpley, 116.67},
+		{1, RecordEstimated1RM, FormulaBrzycki, 112.5},
+		{2, RecordHeaviestWeight, "", 110},
+		{2, RecordMostReps, "", 1},
+		{4, RecordMostReps, "", 8},
+		{4, RecordEstimated1RM, FormulaEpley, 126.67},
+		{4, RecordEstimated1RM, FormulaBrzycki, 124.14},
+	}, records)
+
+	timed := detectRecords([]recordEntry{
+		{EntryID: 5, DurationSeconds: IntPtr(60)},
+		{EntryID: 6, DurationSeconds: IntPtr(45)},
+		{EntryID: 7, DurationSeconds: IntPtr(90)},
+	})
+	assert.Len(t, timed, 2)
+	assert.Equal(t, 7, timed[1].EntryID)
+}
+
+func TestStrengthScores(t *testing.T) {
+	wilks, ok := WilksScore(SexMale, 100, 200)
+	assert.True(t, ok)
+	assert.Equal(t, 121.72, wilks)
+	dots, ok := DOTSScore(SexMale, 100, 200)
+	assert.True(t, ok)
+	assert.Equal(t, 123.1, dots)
+
+	wilks, _ = WilksScore(SexFemale, 60, 100)
+	assert.Equal(t, 111.49, wilks)
+	dots, _ = DOTSScore(SexFemale, 60, 100)
+	assert.Equal(t, 110.85, dots)
+
+	// body weights past the range of the formula count as its bound
+	heavy, _ := DOTSScore(SexMale, 250, 300)
+	bound, _ := DOTSScore(SexMale, 210, 300)
+	assert.Equal(t, bound, heavy)
+
+	_, ok = WilksScore("", 100, 200)
+	assert.False(t, ok)
+
+	record := &PersonalRecord{Type: RecordMostReps, Value: 12}
+	record.scoreStrength(SexMale, FloatPtr(100))
+	assert.Nil(t, record.Wilks)
+	record = &PersonalRecord{Type: RecordHeaviestWeight, Value: 200}
+	record.scoreStrength(SexMale, FloatPtr(100))
+	assert.Equal(t, FloatPtr(121.72), record.Wilks)
+	assert.Equal(t, FloatPtr(123.1), record.DOTS)
+}
+
+func TestSeedPersonalRecords(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestUser(t, db)
+	_, err := NewPostgresWorkoutStore(db).CreateWorkout(&Workout{
+		UserID:          user.ID,
+		Title:           "Legs",
+		DurationMinutes: 30,
+		Entries:         []WorkoutEntry{{ExerciseName: "Squat", Reps: IntPtr(5), Sets: 3, Weight: FloatPtr(100), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+	records := NewPostgresRecordStore(db)
+	logged, err := records.GetCurrentRecords(int64(user.ID), FormulaEpley)
+	require.NoError(t, err)
+	require.NotEmpty(t, logged)
+
+	// workouts logged before records were tracked have none
+	_, err = db.Exec(`DELETE FROM personal_records;`)
+	require.NoError(t, err)
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, seedPersonalRecords(context.Background(), tx))
+	require.NoError(t, tx.Commit())
+
+	seeded, err := records.GetCurrentRecords(int64(user.ID), FormulaEpley)
+	require.NoError(t, err)
+	require.Len(t, seeded, len(logged))
+	for i := range logged {
+		assert.Equal(t, logged[i].Type, seeded[i].Type)
+		assert.Equal(t, logged[i].Value, seeded[i].Value)
+		assert.Equal(t, logged[i].EntryID, seeded[i].EntryID)
+	}
+}
@@ -0,0 +1,345 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statuses of a planned workout.
+const (
+	PlannedStatusPending   = "pending"
+	PlannedStatusOverdue   = "overdue"
+	PlannedStatusCompleted = "completed"
+)
+
+// ScheduleStatuses holds the values accepted for ScheduleFilter.Status, due
+// selects the planned workouts that are not completed yet.
+var ScheduleStatuses = []string{"due", PlannedStatusCompleted, "all"}
+
+// PlannedWorkout is a session of a program scheduled for a user. It is
+// completed by the workout linked to it, AsPrescribed records whether that
+// workout met every prescribed entry.
+type PlannedWorkout struct {
+	ID              int            `json:"id"`
+	UserID          int            `json:"user_id"`
+	EnrollmentID    int            `json:"enrollment_id"`
+	ProgramID       *int           `json:"program_id"`
+	ProgramDayID    *int           `json:"program_day_id"`
+	Title           string         `json:"title"`
+	Description     string         `json:"description"`
+	DurationMinutes int            `json:"duration_minutes"`
+	WeekNumber      int            `json:"week_number"`
+	DayNumber       int            `json:"day_number"`
+	ScheduledOn     time.Time      `json:"scheduled_on"`
+	Status          string         `json:"status"`
+	WorkoutID       *int           `json:"workout_id"`
+	AsPrescribed    *bool          `json:"as_prescribed"`
+	Entries         []PlannedEntry `json:"entries"`
+}
+
+// PlannedEntry is the prescription for one exercise of a planned workout.
+type PlannedEntry struct {
+	ID              int      `json:"id"`
+	ExerciseID      *int     `json:"exercise_id"`
+	ExerciseName    string   `json:"exercise_name"`
+	Sets            int      `json:"sets"`
+	Reps            *int     `json:"reps"`
+	Weight          *float64 `json:"weight"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	Notes           string   `json:"notes"`
+	OrderIndex      int      `json:"order_index"`
+}
+
+// ScheduleFilter selects the planned workouts returned by ListSchedule, From
+// and To are inclusive dates.
+type ScheduleFilter struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+	Status string
+}
+
+// NewWorkout creates the workout completing the planned workout exactly as
+// prescribed.
+func (p *PlannedWorkout) NewWorkout() *Workout {
+	plannedID := p.ID
+	asPrescribed := true
+	workout := &Workout{
+		UserID:           p.UserID,
+		Title:            p.Title,
+		Description:      p.Description,
+		DurationMinutes:  p.DurationMinutes,
+		PlannedWorkoutID: &plannedID,
+		AsPrescribed:     &asPrescribed,
+		Entries:          make([]WorkoutEntry, 0, len(p.Entries)),
+	}
+	for _, planned := range p.Entries {
+		workout.Entries = append(workout.Entries, WorkoutEntry{
+			ExerciseID:      planned.ExerciseID,
+			ExerciseName:    planned.ExerciseName,
+			Sets:            planned.Sets,
+			Reps:            planned.Reps,
+			Weight:          planned.Weight,
+			DurationSeconds: planned.DurationSeconds,
+			Notes:           planned.Notes,
+			OrderIndex:      planned.OrderIndex,
+		})
+	}
+	return workout
+}
+
+// DoneAsPrescribed tells whether every prescribed entry was met by an entry of
+// the workout on the same exercise with at least the prescribed sets, reps,
+// weight and duration. Extra entries do not matter.
+func (p *PlannedWorkout) DoneAsPrescribed(workout *Workout) bool {
+	used := make([]bool, len(workout.Entries))
+	for _, planned := range p.Entries {
+		met := false
+		for i, entry := range workout.Entries {
+			if used[i] || !sameExercise(planned, entry) || !meetsPrescription(planned, entry) {
+				continue
+			}
+			used[i] = true
+			met = true
+			break
+		}
+		if !met {
+			return false
+		}
+	}
+	return true
+}
+
+func sameExercise(planned PlannedEntry, entry WorkoutEntry) bool {
+	if planned.ExerciseID != nil && entry.ExerciseID != nil {
+		return *planned.ExerciseID == *entry.ExerciseID
+	}
+	return strings.EqualFold(strings.TrimSpace(planned.ExerciseName), strings.TrimSpace(entry.ExerciseName))
+}
+
+func meetsPrescription(planned PlannedEntry, entry WorkoutEntry) bool {
+	if entry.Sets < planned.Sets {
+		return false
+	}
+	if planned.Reps != nil && (entry.Reps == nil || *entry.Reps < *planned.Reps) {
+		return false
+	}
+	if planned.DurationSeconds != nil && (entry.DurationSeconds == nil || *entry.DurationSeconds < *planned.DurationSeconds) {
+		return false
+	}
+	if planned.Weight != nil && (entry.Weight == nil || *entry.Weight < *planned.Weight) {
+		return false
+	}
+	return true
+}
+
+// plannedStatus tells whether a planned workout is completed, still to come or
+// overdue. Dates are compared in UTC, the zone scheduled dates are read in.
+func plannedStatus(scheduledOn time.Time, completed bool, now time.Time) string {
+	if completed {
+		return PlannedStatusCompleted
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	if scheduledOn.Before(today) {
+		return PlannedStatusOverdue
+	}
+	return PlannedStatusPending
+}
+
+type PostgresScheduleStore struct {
+	db *sql.DB
+}
+
+func NewPostgresScheduleStore(db *sql.DB) *PostgresScheduleStore {
+	return &PostgresScheduleStore{db: db}
+}
+
+type ScheduleStore interface {
+	ListSchedule(filter ScheduleFilter) ([]*PlannedWorkout, error)
+	GetPlannedWorkout(id int64) (*PlannedWorkout, error)
+	CompletePlannedWorkout(plannedID, workoutID int64, asPrescribed bool) error
+}
+
+const plannedWorkoutColumns = `p.id, p.user_id, p.enrollment_id, e.program_id, p.program_day_id, p.title, p.description, p.duration_minutes,
+	p.week_number, p.day_number, p.scheduled_on, w.id, w.as_prescribed`
+
+func scanPlannedWorkout(row scanner, now time.Time) (*PlannedWorkout, error) {
+	planned := &PlannedWorkout{Entries: []PlannedEntry{}}
+	err := row.Scan(&planned.ID, &planned.UserID, &planned.EnrollmentID, &planned.ProgramID, &planned.ProgramDayID, &planned.Title, &planned.Description, &planned.DurationMinutes,
+		&planned.WeekNumber, &planned.DayNumber, &planned.ScheduledOn, &planned.WorkoutID, &planned.AsPrescribed)
+	if err != nil {
+		return nil, err
+	}
+	planned.Status = plannedStatus(planned.ScheduledOn, planned.WorkoutID != nil, now)
+	return planned, nil
+}
+
+// ListSchedule returns the planned workouts of a user matching the filter in
+// calendar order.
+func (pg *PostgresScheduleStore) ListSchedule(filter ScheduleFilter) ([]*PlannedWorkout, error) {
+	conditions := []string{"p.user_id = $1"}
+	args := []interface{}{filter.UserID}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("p.scheduled_on >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("p.scheduled_on <= $%d", len(args)))
+	}
+	switch filter.Status {
+	case "due":
+		conditions = append(conditions, "w.id IS NULL")
+	case PlannedStatusCompleted:
+		conditions = append(conditions, "w.id IS NOT NULL")
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM planned_workouts p
+	INNER JOIN program_enrollments e ON e.id = p.enrollment_id
+	LEFT JOIN workouts w ON w.planned_workout_id = p.id
+	WHERE %s
+	ORDER BY p.scheduled_on, p.id;
+	`, plannedWorkoutColumns, strings.Join(conditions, " AND "))
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	schedule := []*PlannedWorkout{}
+	for rows.Next() {
+		planned, err := scanPlannedWorkout(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, planned)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = loadPlannedEntries(pg.db, schedule)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (pg *PostgresScheduleStore) GetPlannedWorkout(id int64) (*PlannedWorkout, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM planned_workouts p
+	INNER JOIN program_enrollments e ON e.id = p.enrollment_id
+	LEFT JOIN workouts w ON w.planned_workout_id = p.id
+	WHERE p.id = $1;
+	`, plannedWorkoutColumns)
+	planned, err := scanPlannedWorkout(pg.db.QueryRow(query, id), time.Now())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	err = loadPlannedEntries(pg.db, []*PlannedWorkout{planned})
+	if err != nil {
+		return nil, err
+	}
+	return planned, nil
+}
+
+// CompletePlannedWorkout links a workout to the planned workout it completes.
+// Linking the same workout again records asPrescribed anew, which keeps it
+// right after the workout was edited. A workout that already completes another
+// planned workout is not moved, that would silently mark the other one due
+// again.
+func (pg *PostgresScheduleStore) CompletePlannedWorkout(plannedID, workoutID int64, asPrescribed bool) error {
+	query := `
+	UPDATE workouts SET planned_workout_id = $1, as_prescribed = $2, version = version + 1, updated_at = NOW()
+	WHERE id = $3 AND (planned_workout_id IS NULL OR planned_workout_id = $1);
+	`
+	result, err := pg.db.Exec(query, plannedID, asPrescribed, workoutID)
+	if err != nil {
+		return mapError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1);`, workoutID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return &ConflictError{Field: "workout_id", Reason: "already completes another planned workout", Err: ErrConflict}
+}
+
+// insertPlannedWorkout stores a planned workout and its entries, linking the
+// entries to the exercises the enrolled user can see.
+func insertPlannedWorkout(tx *sql.Tx, planned *PlannedWorkout) error {
+	query := `
+	INSERT INTO planned_workouts (enrollment_id, user_id, program_day_id, title, description, duration_minutes, week_number, day_number, scheduled_on)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id;
+	`
+	err := tx.QueryRow(query, planned.EnrollmentID, planned.UserID, planned.ProgramDayID, planned.Title, planned.Description, planned.DurationMinutes, planned.WeekNumber, planned.DayNumber, planned.ScheduledOn).Scan(&planned.ID)
+	if err != nil {
+		return mapError(err)
+	}
+
+	entryQuery := `
+	INSERT INTO planned_entries (planned_workout_id, exercise_name, sets, reps, weight, duration_seconds, notes, order_index, exercise_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, resolve_exercise($9, $10, $2))
+	RETURNING id, exercise_id;
+	`
+	for i := range planned.Entries {
+		entry := &planned.Entries[i]
+		err := tx.QueryRow(entryQuery, planned.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.Weight, entry.DurationSeconds, entry.Notes, entry.OrderIndex, planned.UserID, entry.ExerciseID).Scan(&entry.ID, &entry.ExerciseID)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// loadPlannedEntries fetches the entries of all given planned workouts in a
+// single query.
+func loadPlannedEntries(q querier, schedule []*PlannedWorkout) error {
+	if len(schedule) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(schedule))
+	byID := make(map[int64]*PlannedWorkout, len(schedule))
+	for i, planned := range schedule {
+		ids[i] = int64(planned.ID)
+		byID[int64(planned.ID)] = planned
+	}
+
+	query := `
+	SELECT planned_workout_id, id, exercise_id, exercise_name, sets, reps, weight, duration_seconds, notes, order_index
+	FROM planned_entries WHERE planned_workout_id = ANY($1) ORDER BY planned_workout_id, order_index;
+	`
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var plannedID int64
+		var entry PlannedEntry
+		err := rows.Scan(&plannedID, &entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.Sets, &entry.Reps, &entry.Weight, &entry.DurationSeconds, &entry.Notes, &entry.OrderIndex)
+		if err != nil {
+			return err
+		}
+		planned := byID[plannedID]
+		planned.Entries = append(planned.Entries, entry)
+	}
+	return rows.Err()
+}
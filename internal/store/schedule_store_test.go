@@ -0,0 +1,115 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoneAsPrescribed(t *testing.T) {
+	planned := &PlannedWorkout{
+		ID:     9,
+		UserID: 2,
+		Title:  "Lower A",
+		Entries: []PlannedEntry{
+			{ExerciseID: IntPtr(1), ExerciseName: "Back Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+			{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 2},
+		},
+	}
+
+	workout := planned.NewWorkout()
+	assert.Equal(t, IntPtr(9), workout.PlannedWorkoutID)
+	assert.True(t, planned.DoneAsPrescribed(workout))
+
+	tests := []struct {
+		name    string
+		entries []WorkoutEntry
+		want    bool
+	}{
+		{
+			name: "More Than Prescribed",
+			entries: []WorkoutEntry{
+				{ExerciseName: "plank", Sets: 4, DurationSeconds: IntPtr(90)},
+				{ExerciseID: IntPtr(1), ExerciseName: "Squat", Sets: 5, Reps: IntPtr(6), Weight: FloatPtr(102.5)},
+				{ExerciseName: "Lunge", Sets: 3, Reps: IntPtr(10)},
+			},
+			want: true,
+		},
+		{
+			name: "Lighter Than Prescribed",
+			entries: []WorkoutEntry{
+				{ExerciseID: IntPtr(1), ExerciseName: "Back Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(95)},
+				{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60)},
+			},
+			want: false,
+		},
+		{
+			name: "Exercise Skipped",
+			entries: []WorkoutEntry{
+				{ExerciseID: IntPtr(1), ExerciseName: "Back Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100)},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, planned.DoneAsPrescribed(&Workout{Entries: tt.entries}))
+		})
+	}
+}
+
+func TestPlannedStatus(t *testing.T) {
+	now := time.Date(2026, 3, 10, 18, 30, 0, 0, time.UTC)
+	assert.Equal(t, PlannedStatusOverdue, plannedStatus(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), false, now))
+	assert.Equal(t, PlannedStatusPending, plannedStatus(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), false, now))
+	assert.Equal(t, PlannedStatusCompleted, plannedStatus(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), true, now))
+}
+
+func TestCompletePlannedWorkoutKeepsOtherLinks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestUser(t, db)
+	template := &WorkoutTemplate{
+		UserID:  user.ID,
+		Title:   "Full Body",
+		Entries: []TemplateEntry{{ExerciseName: "Squat", SetsMin: 3, RepsMin: IntPtr(5), OrderIndex: 1}},
+	}
+	require.NoError(t, NewPostgresTemplateStore(db).CreateTemplate(template))
+	programs := NewPostgresProgramStore(db)
+	program := &Program{
+		UserID: user.ID,
+		Title:  "Twice a week",
+		Weeks: []ProgramWeek{{WeekNumber: 1, Days: []ProgramDay{
+			{DayNumber: 1, TemplateID: template.ID, Progression: Progression{Type: ProgressionNone}},
+			{DayNumber: 4, TemplateID: template.ID, Progression: Progression{Type: ProgressionNone}},
+		}}},
+	}
+	require.NoError(t, programs.CreateProgram(program))
+	enrollment := &Enrollment{UserID: user.ID, ProgramID: &program.ID, StartDate: time.Now()}
+	require.NoError(t, programs.Enroll(enrollment))
+	require.Len(t, enrollment.Sessions, 2)
+	first, second := int64(enrollment.Sessions[0].ID), int64(enrollment.Sessions[1].ID)
+
+	workout, err := NewPostgresWorkoutStore(db).CreateWorkout(&Workout{UserID: user.ID, Title: "Full Body", DurationMinutes: 30})
+	require.NoError(t, err)
+	schedule := NewPostgresScheduleStore(db)
+	require.NoError(t, schedule.CompletePlannedWorkout(first, int64(workout.ID), false))
+	// linking it again only records how it was done
+	require.NoError(t, schedule.CompletePlannedWorkout(first, int64(workout.ID), true))
+
+	err = schedule.CompletePlannedWorkout(second, int64(workout.ID), true)
+	assert.ErrorIs(t, err, ErrConflict)
+	var conflictErr *ConflictError
+	if assert.ErrorAs(t, err, &conflictErr) {
+		assert.Equal(t, "workout_id", conflictErr.Field)
+	}
+	planned, err := schedule.GetPlannedWorkout(first)
+	require.NoError(t, err)
+	assert.Equal(t, PlannedStatusCompleted, planned.Status)
+	assert.Equal(t, &workout.ID, planned.WorkoutID)
+
+	assert.ErrorIs(t, schedule.CompletePlannedWorkout(second, 0, true), ErrNotFound)
+}
@@ -0,0 +1,422 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// Progression models suggesting the load of the next session of an exercise.
+const (
+	ModelDoubleProgression = "double_progression"
+	ModelRPE               = "rpe"
+	ModelLinear            = "linear"
+)
+
+// ProgressionModels holds the values accepted for ProgressionModel.Type.
+var ProgressionModels = []string{ModelDoubleProgression, ModelRPE, ModelLinear}
+
+// Actions of a suggestion, comparing it to the last session.
+const (
+	SuggestStart    = "start"
+	SuggestIncrease = "increase"
+	SuggestRepeat   = "repeat"
+	SuggestDecrease = "decrease"
+	SuggestDeload   = "deload"
+)
+
+// suggestionSessions is the number of recent sessions of an exercise a
+// suggestion looks at, which bounds ProgressionModel.DeloadAfter.
+const suggestionSessions = 10
+
+// ProgressionModel configures how the next session of an exercise progresses
+// from the last ones.
+//
+// Double progression keeps the weight until every working set reaches RepsMax,
+// then adds Increment and starts again at RepsMin. The RPE model estimates a
+// one rep max from the effort ratings of the last session and picks the weight
+// for TargetReps at TargetRPE from an RPE percentage table. Linear progression
+// adds Increment after every session without misses and takes DeloadPercent
+// off after DeloadAfter missed sessions in a row.
+type ProgressionModel struct {
+	Type          string
+	RepsMin       int
+	RepsMax       int
+	Increment     float64
+	TargetReps    *int
+	TargetRPE     float64
+	DeloadAfter   int
+	DeloadPercent float64
+}
+
+// DefaultProgressionModel returns a model of the given type with the settings
+// most programs start from.
+func DefaultProgressionModel(modelType string) ProgressionModel {
+	return ProgressionModel{
+		Type:          modelType,
+		RepsMin:       8,
+		RepsMax:       12,
+		Increment:     2.5,
+		TargetRPE:     8,
+		DeloadAfter:   3,
+		DeloadPercent: 10,
+	}
+}
+
+// ValidateProgressionModel checks the settings of a model, the error keys are
+// the names of the query parameters setting them.
+func ValidateProgressionModel(v *validator.Validator, m *ProgressionModel) {
+	v.Check(slices.Contains(ProgressionModels, m.Type), "model", "must be one of "+strings.Join(ProgressionModels, ", "))
+	v.Check(m.RepsMin >= 1, "reps_min", "must be at least 1")
+	v.Check(m.RepsMax >= m.RepsMin, "reps_max", "must not be less than reps_min")
+	v.Check(m.Increment > 0, "increment", "must be greater than zero")
+	if m.TargetReps != nil {
+		v.Check(*m.TargetReps >= 1, "reps", "must be at least 1")
+		if m.Type == ModelRPE {
+			v.Check(*m.TargetReps <= 12, "reps", "must not be more than 12 for the rpe model")
+		}
+	}
+	v.Check(m.TargetRPE >= 6 && m.TargetRPE <= 10 && m.TargetRPE*2 == math.Trunc(m.TargetRPE*2), "rpe", "must be between 6 and 10 in steps of 0.5")
+	v.Check(m.DeloadAfter >= 1 && m.DeloadAfter <= suggestionSessions, "deload_after", fmt.Sprintf("must be between 1 and %d", suggestionSessions))
+	v.Check(m.DeloadPercent > 0 && m.DeloadPercent < 100, "deload_percent", "must be greater than 0 and less than 100")
+}
+
+// TrainingSession holds the sets of one exercise done in one workout, warm-ups
+// left out.
+type TrainingSession struct {
+	WorkoutID int
+	Date      time.Time
+	Sets      []WorkoutSet
+}
+
+// ExerciseHistory holds the recent sessions of one exercise, newest first.
+type ExerciseHistory struct {
+	ExerciseID   *int
+	ExerciseName string
+	Sessions     []TrainingSession
+}
+
+// Suggestion is the target of the next session of an exercise. OrderIndex is
+// set when the suggestion belongs to an entry of a workout.
+type Suggestion struct {
+	ExerciseID       *int     `json:"exercise_id"`
+	ExerciseName     string   `json:"exercise_name"`
+	OrderIndex       int      `json:"order_index,omitempty"`
+	Model            string   `json:"model"`
+	Action           string   `json:"action"`
+	Sets             int      `json:"sets"`
+	Reps             *int     `json:"reps"`
+	Weight           *float64 `json:"weight"`
+	DurationSeconds  *int     `json:"duration_seconds"`
+	RPE              *float64 `json:"rpe"`
+	Reason           string   `json:"reason"`
+	BasedOnWorkoutID *int     `json:"based_on_workout_id"`
+}
+
+// Suggest works out the next session of the exercise of history.
+func (m ProgressionModel) Suggest(history *ExerciseHistory) *Suggestion {
+	suggestion := &Suggestion{ExerciseID: history.ExerciseID, ExerciseName: history.ExerciseName, Model: m.Type}
+	if len(history.Sessions) == 0 {
+		suggestion.Action = SuggestStart
+		suggestion.Reason = "no sets of this exercise were logged yet"
+		return suggestion
+	}
+	last := history.Sessions[0]
+	workoutID := last.WorkoutID
+	suggestion.BasedOnWorkoutID = &workoutID
+	weight, top := topSets(last)
+
+	if top[0].Reps == nil {
+		longest := 0
+		for _, set := range top {
+			if set.DurationSeconds != nil {
+				longest = max(longest, *set.DurationSeconds)
+			}
+		}
+		suggestion.Action = SuggestRepeat
+		suggestion.Sets = len(top)
+		suggestion.Weight = weight
+		suggestion.DurationSeconds = &longest
+		suggestion.Reason = "timed sets are repeated at their longest duration"
+		return suggestion
+	}
+	// the same exercise logged timed elsewhere in the workout does not count
+	top = slices.DeleteFunc(top, func(set WorkoutSet) bool { return set.Reps == nil })
+	suggestion.Sets = len(top)
+
+	switch m.Type {
+	case ModelDoubleProgression:
+		m.suggestDoubleProgression(suggestion, weight, top)
+	case ModelRPE:
+		m.suggestRPE(suggestion, last, weight, top)
+	case ModelLinear:
+		m.suggestLinear(suggestion, history, weight, top)
+	}
+	return suggestion
+}
+
+func (m ProgressionModel) suggestDoubleProgression(suggestion *Suggestion, weight *float64, top []WorkoutSet) {
+	low := minReps(top)
+	if missed(top, &m.RepsMax) {
+		reps := min(max(low+1, m.RepsMin), m.RepsMax)
+		suggestion.Action = SuggestRepeat
+		suggestion.Weight = weight
+		suggestion.Reps = &reps
+		suggestion.Reason = fmt.Sprintf("work up to %d reps on every set before adding weight", m.RepsMax)
+		return
+	}
+	suggestion.Action = SuggestIncrease
+	if weight == nil {
+		reps := low + 1
+		suggestion.Reps = &reps
+		suggestion.Reason = fmt.Sprintf("every set reached %d reps, add a rep", m.RepsMax)
+		return
+	}
+	next := round2(*weight + m.Increment)
+	reps := m.RepsMin
+	suggestion.Weight = &next
+	suggestion.Reps = &reps
+	suggestion.Reason = fmt.Sprintf("every set reached %d reps at %s, add weight and start again at %d reps", m.RepsMax, formatWeight(*weight), m.RepsMin)
+}
+
+func (m ProgressionModel) suggestRPE(suggestion *Suggestion, last TrainingSession, weight *float64, top []WorkoutSet) {
+	oneRepMax := 0.0
+	for _, set := range last.Sets {
+		rpe, ok := setRPE(set)
+		if !ok || !set.counts() || set.Weight == nil || *set.Weight <= 0 || set.Reps == nil || *set.Reps > 12 {
+			continue
+		}
+		oneRepMax = max(oneRepMax, *set.Weight/rpePercentage(*set.Reps, rpe))
+	}
+	if oneRepMax == 0 {
+		reps := maxReps(top)
+		suggestion.Action = SuggestRepeat
+		suggestion.Weight = weight
+		suggestion.Reps = &reps
+		suggestion.Reason = "log rpe or rir on completed weighted sets of 12 reps or fewer to get a load from them"
+		return
+	}
+
+	reps := min(maxReps(top), 12)
+	if m.TargetReps != nil {
+		reps = *m.TargetReps
+	}
+	percentage := rpePercentage(reps, m.TargetRPE)
+	next := roundToStep(oneRepMax*percentage, m.Increment)
+	rpe := m.TargetRPE
+	suggestion.Weight = &next
+	suggestion.Reps = &reps
+	suggestion.RPE = &rpe
+	switch {
+	case next > *weight:
+		suggestion.Action = SuggestIncrease
+	case next < *weight:
+		suggestion.Action = SuggestDecrease
+	default:
+		suggestion.Action = SuggestRepeat
+	}
+	suggestion.Reason = fmt.Sprintf("%s%% of an estimated one rep max of %s is %d reps at rpe %s",
+		formatWeight(round2(percentage*100)), formatWeight(round2(oneRepMax)), reps, strconv.FormatFloat(rpe, 'f', -1, 64))
+}
+
+func (m ProgressionModel) suggestLinear(suggestion *Suggestion, history *ExerciseHistory, weight *float64, top []WorkoutSet) {
+	misses := 0
+	for _, session := range history.Sessions {
+		sessionWeight, sets := topSets(session)
+		if !sameWeight(sessionWeight, weight) || !missed(sets, m.TargetReps) {
+			break
+		}
+		misses++
+	}
+	reps := maxReps(top)
+	if m.TargetReps != nil {
+		reps = *m.TargetReps
+	}
+	suggestion.Reps = &reps
+	suggestion.Weight = weight
+
+	switch {
+	case misses == 0 && weight == nil:
+		reps++
+		suggestion.Action = SuggestIncrease
+		suggestion.Reason = "every set was completed, add a rep"
+	case misses == 0:
+		next := round2(*weight + m.Increment)
+		suggestion.Weight = &next
+		suggestion.Action = SuggestIncrease
+		suggestion.Reason = fmt.Sprintf("every set was completed at %s, add %s", formatWeight(*weight), formatWeight(m.Increment))
+	case misses >= m.DeloadAfter && weight != nil:
+		next := roundToStep(*weight*(1-m.DeloadPercent/100), m.Increment)
+		suggestion.Weight = &next
+		suggestion.Action = SuggestDeload
+		suggestion.Reason = fmt.Sprintf("missed %d sessions in a row at %s, take %s%% off", misses, formatWeight(*weight), formatWeight(m.DeloadPercent))
+	default:
+		suggestion.Action = SuggestRepeat
+		suggestion.Reason = fmt.Sprintf("missed %d of %d sessions before a deload, repeat the last load", misses, m.DeloadAfter)
+	}
+}
+
+// topSets returns the heaviest weight of a session and its sets at that
+// weight, the working sets as far as progression is concerned.
+func topSets(session TrainingSession) (*float64, []WorkoutSet) {
+	var weight *float64
+	for _, set := range session.Sets {
+		if set.Weight != nil && (weight == nil || *set.Weight > *weight) {
+			weight = set.Weight
+		}
+	}
+	var sets []WorkoutSet
+	for _, set := range session.Sets {
+		if sameWeight(set.Weight, weight) {
+			sets = append(sets, set)
+		}
+	}
+	return weight, sets
+}
+
+func sameWeight(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// missed tells whether a set was not completed or, with a target, fell short
+// of the target reps.
+func missed(sets []WorkoutSet, targetReps *int) bool {
+	for _, set := range sets {
+		if !set.counts() || (targetReps != nil && set.Reps != nil && *set.Reps < *targetReps) {
+			return true
+		}
+	}
+	return false
+}
+
+func minReps(sets []WorkoutSet) int {
+	low := *sets[0].Reps
+	for _, set := range sets[1:] {
+		low = min(low, *set.Reps)
+	}
+	return low
+}
+
+func maxReps(sets []WorkoutSet) int {
+	high := *sets[0].Reps
+	for _, set := range sets[1:] {
+		high = max(high, *set.Reps)
+	}
+	return high
+}
+
+// setRPE returns the effort of a set on the half steps of the RPE table, from
+// its RPE or else its reps in reserve.
+func setRPE(set WorkoutSet) (float64, bool) {
+	rpe := 0.0
+	switch {
+	case set.RPE != nil:
+		rpe = math.Round(*set.RPE*2) / 2
+	case set.RIR != nil:
+		rpe = float64(10 - *set.RIR)
+	}
+	return rpe, rpe >= 6
+}
+
+// rpeTable holds the percentages of a one rep max that can be lifted for a
+// number of reps at RPE 10, in half rep steps from 1 to 16 reps. Every RPE
+// point below 10 is one more rep in reserve, so reps at an RPE read the table
+// at reps + 10 - RPE.
+var rpeTable = []float64{
+	100, 97.8, 95.5, 93.9, 92.2, 90.7, 89.2, 87.8, 86.3, 85.0, 83.7,
+	82.4, 81.1, 79.9, 78.6, 77.4, 76.2, 75.1, 73.9, 72.3, 70.7,
+	69.4, 68.0, 66.7, 65.3, 64.0, 62.6, 61.3, 59.9, 58.6, 57.4,
+}
+
+// rpePercentage returns the fraction of a one rep max lifted for reps at rpe,
+// for 1 to 12 reps and an RPE between 6 and 10 in half steps.
+func rpePercentage(reps int, rpe float64) float64 {
+	return rpeTable[int((float64(reps)+10-rpe-1)*2)] / 100
+}
+
+// roundToStep rounds a weight to the nearest multiple of step.
+func roundToStep(weight, step float64) float64 {
+	return round2(math.Round(weight/step) * step)
+}
+
+func formatWeight(weight float64) string {
+	return strconv.FormatFloat(weight, 'f', -1, 64)
+}
+
+type PostgresSuggestionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSuggestionStore(db *sql.DB) *PostgresSuggestionStore {
+	return &PostgresSuggestionStore{db: db}
+}
+
+type SuggestionStore interface {
+	GetExerciseHistory(userID int, exerciseID *int, name string) (*ExerciseHistory, error)
+}
+
+// GetExerciseHistory returns the recent sessions of a user on an exercise. The
+// exercise is the catalog exercise exerciseID, or else the one name resolves
+// to, or else the entries not linked to the catalog under that name.
+func (pg *PostgresSuggestionStore) GetExerciseHistory(userID int, exerciseID *int, name string) (*ExerciseHistory, error) {
+	query := `
+	WITH target AS (
+		SELECT COALESCE($2::bigint, resolve_exercise($1, NULL, $3)) AS exercise_id
+	),
+	entries AS (
+		SELECT e.id, e.workout_id, e.order_index, e.exercise_id, e.exercise_name, w.created_at
+		FROM workout_entries e
+		INNER JOIN workouts w ON w.id = e.workout_id
+		CROSS JOIN target t
+		WHERE w.user_id = $1 AND CASE WHEN t.exercise_id IS NULL
+			THEN e.exercise_id IS NULL AND LOWER(TRIM(e.exercise_name)) = LOWER(TRIM($3))
+			ELSE e.exercise_id = t.exercise_id END
+		AND EXISTS (SELECT 1 FROM workout_sets s WHERE s.entry_id = e.id AND s.set_type <> 'warmup')
+	),
+	recent AS (
+		SELECT DISTINCT workout_id, created_at FROM entries
+		ORDER BY created_at DESC, workout_id DESC
+		LIMIT $4
+	)
+	SELECT en.workout_id, en.created_at, en.exercise_id, COALESCE(x.name, en.exercise_name),
+		s.set_number, s.set_type, s.reps, s.weight, s.duration_seconds, s.rpe, s.rir, s.completed
+	FROM entries en
+	INNER JOIN recent r ON r.workout_id = en.workout_id
+	INNER JOIN workout_sets s ON s.entry_id = en.id AND s.set_type <> 'warmup'
+	LEFT JOIN exercises x ON x.id = en.exercise_id
+	ORDER BY en.created_at DESC, en.workout_id DESC, en.order_index, s.set_number;
+	`
+	rows, err := pg.db.Query(query, userID, exerciseID, name, suggestionSessions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &ExerciseHistory{ExerciseID: exerciseID, ExerciseName: strings.TrimSpace(name), Sessions: []TrainingSession{}}
+	for rows.Next() {
+		var workoutID int
+		var date time.Time
+		var set WorkoutSet
+		err := rows.Scan(&workoutID, &date, &history.ExerciseID, &history.ExerciseName,
+			&set.SetNumber, &set.Type, &set.Reps, &set.Weight, &set.DurationSeconds, &set.RPE, &set.RIR, &set.Completed)
+		if err != nil {
+			return nil, err
+		}
+		sessions := history.Sessions
+		if len(sessions) == 0 || sessions[len(sessions)-1].WorkoutID != workoutID {
+			history.Sessions = append(history.Sessions, TrainingSession{WorkoutID: workoutID, Date: date})
+		}
+		session := &history.Sessions[len(history.Sessions)-1]
+		session.Sets = append(session.Sets, set)
+	}
+	return history, rows.Err()
+}
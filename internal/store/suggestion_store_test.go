@@ -0,0 +1,137 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+// session builds a training session of sets of reps at weight, a nil weight
+// being bodyweight.
+func session(workoutID int, weight *float64, completed bool, reps ...int) TrainingSession {
+	s := TrainingSession{WorkoutID: workoutID}
+	for _, r := range reps {
+		s.Sets = append(s.Sets, WorkoutSet{Type: SetWorking, Reps: IntPtr(r), Weight: weight, Completed: BoolPtr(completed)})
+	}
+	return s
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    ProgressionModel
+		sessions []TrainingSession
+		action   string
+		reps     *int
+		weight   *float64
+	}{
+		{
+			name:   "No History",
+			model:  DefaultProgressionModel(ModelDoubleProgression),
+			action: SuggestStart,
+		},
+		{
+			name:     "Double Progression Adds Reps",
+			model:    DefaultProgressionModel(ModelDoubleProgression),
+			sessions: []TrainingSession{session(2, FloatPtr(60), true, 12, 10, 9)},
+			action:   SuggestRepeat,
+			reps:     IntPtr(10),
+			weight:   FloatPtr(60),
+		},
+		{
+			name:     "Double Progression Adds Weight",
+			model:    DefaultProgressionModel(ModelDoubleProgression),
+			sessions: []TrainingSession{session(2, FloatPtr(60), true, 12, 12, 13)},
+			action:   SuggestIncrease,
+			reps:     IntPtr(8),
+			weight:   FloatPtr(62.5),
+		},
+		{
+			name:     "Linear Progression Adds Weight",
+			model:    DefaultProgressionModel(ModelLinear),
+			sessions: []TrainingSession{session(3, FloatPtr(100), true, 5, 5, 5), session(2, FloatPtr(100), false, 5, 5, 3)},
+			action:   SuggestIncrease,
+			reps:     IntPtr(5),
+			weight:   FloatPtr(102.5),
+		},
+		{
+			name:     "Linear Progression Repeats A Miss",
+			model:    DefaultProgressionModel(ModelLinear),
+			sessions: []TrainingSession{session(3, FloatPtr(100), false, 5, 5, 3), session(2, FloatPtr(97.5), true, 5, 5, 5)},
+			action:   SuggestRepeat,
+			reps:     IntPtr(5),
+			weight:   FloatPtr(100),
+		},
+		{
+			name:  "Linear Progression Deloads",
+			model: DefaultProgressionModel(ModelLinear),
+			sessions: []TrainingSession{
+				session(4, FloatPtr(100), false, 5, 4),
+				session(3, FloatPtr(100), false, 5, 3),
+				session(2, FloatPtr(100), false, 4, 4),
+			},
+			action: SuggestDeload,
+			reps:   IntPtr(5),
+			weight: FloatPtr(90),
+		},
+		{
+			name:  "RPE Targets",
+			model: ProgressionModel{Type: ModelRPE, Increment: 2.5, TargetRPE: 8, TargetReps: IntPtr(3)},
+			sessions: []TrainingSession{{WorkoutID: 2, Sets: []WorkoutSet{
+				{Reps: IntPtr(5), Weight: FloatPtr(100), RPE: FloatPtr(9)},
+				{Reps: IntPtr(5), Weight: FloatPtr(100), RIR: IntPtr(2)},
+			}}},
+			action: SuggestIncrease,
+			reps:   IntPtr(3),
+			// 5 reps with 2 in reserve are 81.1% of 123.3 kg, 3 reps at RPE 8
+			// are 86.3% of it
+			weight: FloatPtr(107.5),
+		},
+		{
+			name:     "RPE Without Ratings",
+			model:    DefaultProgressionModel(ModelRPE),
+			sessions: []TrainingSession{session(2, FloatPtr(100), true, 5, 5)},
+			action:   SuggestRepeat,
+			reps:     IntPtr(5),
+			weight:   FloatPtr(100),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion := tt.model.Suggest(&ExerciseHistory{ExerciseName: "Squat", Sessions: tt.sessions})
+			assert.Equal(t, tt.action, suggestion.Action)
+			assert.Equal(t, tt.reps, suggestion.Reps)
+			assert.Equal(t, tt.weight, suggestion.Weight)
+			assert.NotEmpty(t, suggestion.Reason)
+		})
+	}
+}
+
+func TestRPEPercentage(t *testing.T) {
+	assert.Equal(t, 1.0, rpePercentage(1, 10))
+	assert.Equal(t, 0.863, rpePercentage(5, 10))
+	// one rep in reserve is the next rep of the RPE 10 column
+	assert.Equal(t, rpePercentage(6, 10), rpePercentage(5, 9))
+	assert.Equal(t, 0.574, rpePercentage(12, 6))
+}
+
+func TestValidateProgressionModel(t *testing.T) {
+	model := ProgressionModel{Type: "wave", RepsMin: 10, RepsMax: 8, TargetReps: IntPtr(15), TargetRPE: 8.3, DeloadAfter: 20}
+	v := validator.New()
+	ValidateProgressionModel(v, &model)
+	assert.Equal(t, map[string]string{
+		"model":          "must be one of double_progression, rpe, linear",
+		"reps_max":       "must not be less than reps_min",
+		"increment":      "must be greater than zero",
+		"rpe":            "must be between 6 and 10 in steps of 0.5",
+		"deload_after":   "must be between 1 and 10",
+		"deload_percent": "must be greater than 0 and less than 100",
+	}, v.Errors)
+
+	model = DefaultProgressionModel(ModelRPE)
+	model.TargetReps = IntPtr(15)
+	v = validator.New()
+	ValidateProgressionModel(v, &model)
+	assert.Equal(t, map[string]string{"reps": "must not be more than 12 for the rpe model"}, v.Errors)
+}
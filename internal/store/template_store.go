@@ -0,0 +1,293 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// WorkoutTemplate is a planned session that workouts can be started from.
+type WorkoutTemplate struct {
+	ID              int             `json:"id"`
+	UserID          int             `json:"user_id"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"`
+	DurationMinutes int             `json:"duration_minutes"`
+	Entries         []TemplateEntry `json:"entries"`
+	Version         int             `json:"version"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// TemplateEntry plans one exercise of a template as ranges of sets, reps and
+// weight. A nil maximum means the minimum is the target.
+type TemplateEntry struct {
+	ID              int      `json:"id"`
+	ExerciseID      *int     `json:"exercise_id"`
+	ExerciseName    string   `json:"exercise_name"`
+	SetsMin         int      `json:"sets_min"`
+	SetsMax         *int     `json:"sets_max"`
+	RepsMin         *int     `json:"reps_min"`
+	RepsMax         *int     `json:"reps_max"`
+	WeightMin       *float64 `json:"weight_min"`
+	WeightMax       *float64 `json:"weight_max"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	Notes           string   `json:"notes"`
+	OrderIndex      int      `json:"order_index"`
+}
+
+// NewWorkout prefills a workout of the template's owner from the template,
+// taking the lower end of every range.
+func (t *WorkoutTemplate) NewWorkout() *Workout {
+	templateID := t.ID
+	workout := &Workout{
+		UserID:          t.UserID,
+		Title:           t.Title,
+		Description:     t.Description,
+		DurationMinutes: t.DurationMinutes,
+		TemplateID:      &templateID,
+		Entries:         make([]WorkoutEntry, 0, len(t.Entries)),
+	}
+	for _, planned := range t.Entries {
+		workout.Entries = append(workout.Entries, WorkoutEntry{
+			ExerciseID:      planned.ExerciseID,
+			ExerciseName:    planned.ExerciseName,
+			Sets:            planned.SetsMin,
+			Reps:            planned.RepsMin,
+			Weight:          planned.WeightMin,
+			DurationSeconds: planned.DurationSeconds,
+			Notes:           planned.Notes,
+			OrderIndex:      planned.OrderIndex,
+		})
+	}
+	return workout
+}
+
+// TemplateFromWorkout captures a workout as a template whose ranges are the
+// values that were logged.
+func TemplateFromWorkout(workout *Workout) *WorkoutTemplate {
+	template := &WorkoutTemplate{
+		UserID:          workout.UserID,
+		Title:           workout.Title,
+		Description:     workout.Description,
+		DurationMinutes: workout.DurationMinutes,
+		Entries:         make([]TemplateEntry, 0, len(workout.Entries)),
+	}
+	for _, entry := range workout.Entries {
+		template.Entries = append(template.Entries, TemplateEntry{
+			ExerciseID:      entry.ExerciseID,
+			ExerciseName:    entry.ExerciseName,
+			SetsMin:         entry.Sets,
+			RepsMin:         entry.Reps,
+			WeightMin:       entry.Weight,
+			DurationSeconds: entry.DurationSeconds,
+			Notes:           entry.Notes,
+			OrderIndex:      entry.OrderIndex,
+		})
+	}
+	return template
+}
+
+type PostgresTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTemplateStore(db *sql.DB) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: db}
+}
+
+type TemplateStore interface {
+	CreateTemplate(template *WorkoutTemplate) error
+	GetTemplateByID(id int64) (*WorkoutTemplate, error)
+	ListTemplates(userID int) ([]*WorkoutTemplate, error)
+	UpdateTemplate(template *WorkoutTemplate) error
+	DeleteTemplate(id int64) error
+}
+
+func (pg *PostgresTemplateStore) CreateTemplate(template *WorkoutTemplate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO workout_templates (user_id, title, description, duration_minutes)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, version, created_at, updated_at;
+	`
+	err = tx.QueryRow(query, template.UserID, template.Title, template.Description, template.DurationMinutes).Scan(&template.ID, &template.Version, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+	err = insertTemplateEntries(tx, template)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (pg *PostgresTemplateStore) GetTemplateByID(id int64) (*WorkoutTemplate, error) {
+	template := &WorkoutTemplate{}
+	query := `
+	SELECT id, user_id, title, description, duration_minutes, version, created_at, updated_at
+	FROM workout_templates WHERE id = $1;
+	`
+	err := pg.db.QueryRow(query, id).Scan(&template.ID, &template.UserID, &template.Title, &template.Description, &template.DurationMinutes, &template.Version, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	err = loadTemplateEntries(pg.db, []*WorkoutTemplate{template})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// ListTemplates returns the templates of a user ordered by title.
+func (pg *PostgresTemplateStore) ListTemplates(userID int) ([]*WorkoutTemplate, error) {
+	query := `
+	SELECT id, user_id, title, description, duration_minutes, version, created_at, updated_at
+	FROM workout_templates WHERE user_id = $1
+	ORDER BY title, id;
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*WorkoutTemplate{}
+	for rows.Next() {
+		template := &WorkoutTemplate{}
+		err := rows.Scan(&template.ID, &template.UserID, &template.Title, &template.Description, &template.DurationMinutes, &template.Version, &template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = loadTemplateEntries(pg.db, templates)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// UpdateTemplate saves a template and replaces its entries, nothing refers to
+// template entries so they do not need to keep their IDs.
+func (pg *PostgresTemplateStore) UpdateTemplate(template *WorkoutTemplate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE workout_templates SET title = $1, description = $2, duration_minutes = $3, version = version + 1, updated_at = NOW()
+	WHERE id = $4 AND version = $5
+	RETURNING version, updated_at;
+	`
+	err = tx.QueryRow(query, template.Title, template.Description, template.DurationMinutes, template.ID, template.Version).Scan(&template.Version, &template.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return mapError(err)
+	}
+	_, err = tx.Exec(`DELETE FROM template_entries WHERE template_id = $1;`, template.ID)
+	if err != nil {
+		return err
+	}
+	err = insertTemplateEntries(tx, template)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTemplate removes a template, workouts started from it are kept.
+// Templates scheduled by a program cannot be deleted.
+func (pg *PostgresTemplateStore) DeleteTemplate(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM workout_templates WHERE id = $1;`, id)
+	if isForeignKeyViolation(err) {
+		return &ConflictError{Field: "template", Reason: "is scheduled by a program", Err: err}
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// insertTemplateEntries stores the entries of a template, linking them to the
+// exercise catalog the same way workout entries are.
+func insertTemplateEntries(tx *sql.Tx, template *WorkoutTemplate) error {
+	query := `
+	INSERT INTO template_entries (template_id, exercise_name, sets_min, sets_max, reps_min, reps_max, weight_min, weight_max, duration_seconds, notes, order_index, exercise_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, resolve_exercise($12, $13, $2))
+	RETURNING id, exercise_id;
+	`
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		var exerciseID *int
+		err := tx.QueryRow(query, template.ID, entry.ExerciseName, entry.SetsMin, entry.SetsMax, entry.RepsMin, entry.RepsMax, entry.WeightMin, entry.WeightMax, entry.DurationSeconds, entry.Notes, entry.OrderIndex, template.UserID, entry.ExerciseID).Scan(&entry.ID, &exerciseID)
+		if err != nil {
+			return mapError(err)
+		}
+		if entry.ExerciseID != nil && exerciseID == nil {
+			return &ConstraintError{Constraint: "template_entries_exercise_id_fkey"}
+		}
+		entry.ExerciseID = exerciseID
+	}
+	return nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadTemplateEntries fetches the entries of all given templates in a single
+// query.
+func loadTemplateEntries(q querier, templates []*WorkoutTemplate) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(templates))
+	byID := make(map[int64]*WorkoutTemplate, len(templates))
+	for i, template := range templates {
+		template.Entries = []TemplateEntry{}
+		ids[i] = int64(template.ID)
+		byID[int64(template.ID)] = template
+	}
+
+	query := `
+	SELECT template_id, id, exercise_id, exercise_name, sets_min, sets_max, reps_min, reps_max, weight_min, weight_max, duration_seconds, notes, order_index
+	FROM template_entries WHERE template_id = ANY($1) ORDER BY template_id, order_index;
+	`
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var templateID int64
+		var entry TemplateEntry
+		err := rows.Scan(&templateID, &entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.SetsMin, &entry.SetsMax, &entry.RepsMin, &entry.RepsMax, &entry.WeightMin, &entry.WeightMax, &entry.DurationSeconds, &entry.Notes, &entry.OrderIndex)
+		if err != nil {
+			return err
+		}
+		template := byID[templateID]
+		template.Entries = append(template.Entries, entry)
+	}
+	return rows.Err()
+}
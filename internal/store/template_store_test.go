@@ -0,0 +1,100 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	v := validator.New()
+	ValidateTemplate(v, &WorkoutTemplate{
+		Title:           "Upper A",
+		DurationMinutes: 45,
+		Entries: []TemplateEntry{
+			{ExerciseName: "Bench Press", SetsMin: 3, SetsMax: IntPtr(2), RepsMin: IntPtr(8), RepsMax: IntPtr(6), WeightMin: FloatPtr(60), OrderIndex: 1},
+			{ExerciseName: "Plank", SetsMin: 3, RepsMax: IntPtr(10), WeightMax: FloatPtr(5), OrderIndex: 2},
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"entries[0].sets_max":   "must not be less than sets_min",
+		"entries[0].reps_max":   "must not be less than reps_min",
+		"entries[1]":            "must have exactly one of reps_min or duration_seconds",
+		"entries[1].reps_max":   "must not be set without reps_min",
+		"entries[1].weight_max": "must not be set without weight_min",
+	}, v.Errors)
+}
+
+func TestTemplateConversion(t *testing.T) {
+	template := &WorkoutTemplate{
+		ID:              7,
+		UserID:          3,
+		Title:           "Lower A",
+		DurationMinutes: 50,
+		Entries: []TemplateEntry{
+			{ExerciseID: IntPtr(12), ExerciseName: "Squat", SetsMin: 3, SetsMax: IntPtr(5), RepsMin: IntPtr(5), RepsMax: IntPtr(8), WeightMin: FloatPtr(100), WeightMax: FloatPtr(110), OrderIndex: 1},
+			{ExerciseName: "Plank", SetsMin: 2, DurationSeconds: IntPtr(60), Notes: "hold", OrderIndex: 2},
+		},
+	}
+
+	workout := template.NewWorkout()
+	assert.Equal(t, 3, workout.UserID)
+	assert.Equal(t, IntPtr(7), workout.TemplateID)
+	assert.Equal(t, []WorkoutEntry{
+		{ExerciseID: IntPtr(12), ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		{ExerciseName: "Plank", Sets: 2, DurationSeconds: IntPtr(60), Notes: "hold", OrderIndex: 2},
+	}, workout.Entries)
+
+	v := validator.New()
+	ValidateWorkout(v, workout)
+	assert.True(t, v.Valid())
+
+	captured := TemplateFromWorkout(workout)
+	assert.Equal(t, 0, captured.ID)
+	assert.Equal(t, "Lower A", captured.Title)
+	assert.Equal(t, []TemplateEntry{
+		{ExerciseID: IntPtr(12), ExerciseName: "Squat", SetsMin: 3, RepsMin: IntPtr(5), WeightMin: FloatPtr(100), OrderIndex: 1},
+		{ExerciseName: "Plank", SetsMin: 2, DurationSeconds: IntPtr(60), Notes: "hold", OrderIndex: 2},
+	}, captured.Entries)
+}
+
+func TestDeleteScheduledTemplate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestUser(t, db)
+	templateStore := NewPostgresTemplateStore(db)
+	programStore := NewPostgresProgramStore(db)
+
+	template := &WorkoutTemplate{
+		UserID:  user.ID,
+		Title:   "Upper A",
+		Entries: []TemplateEntry{{ExerciseName: "Bench Press", SetsMin: 3, RepsMin: IntPtr(8), OrderIndex: 1}},
+	}
+	require.NoError(t, templateStore.CreateTemplate(template))
+	program := &Program{
+		UserID: user.ID,
+		Title:  "Upper days",
+		Weeks: []ProgramWeek{{WeekNumber: 1, Days: []ProgramDay{
+			{DayNumber: 1, TemplateID: template.ID, Progression: Progression{Type: ProgressionNone}},
+		}}},
+	}
+	require.NoError(t, programStore.CreateProgram(program))
+
+	err := templateStore.DeleteTemplate(int64(template.ID))
+	assert.ErrorIs(t, err, ErrConflict)
+	var conflictErr *ConflictError
+	if assert.ErrorAs(t, err, &conflictErr) {
+		assert.Equal(t, "template", conflictErr.Field)
+		assert.Equal(t, "is scheduled by a program", conflictErr.Reason)
+	}
+	_, err = templateStore.GetTemplateByID(int64(template.ID))
+	require.NoError(t, err)
+
+	// once no program schedules it the template can go
+	require.NoError(t, programStore.DeleteProgram(int64(program.ID)))
+	require.NoError(t, templateStore.DeleteTemplate(int64(template.ID)))
+	assert.ErrorIs(t, templateStore.DeleteTemplate(int64(template.ID)), ErrNotFound)
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// ValidateTemplate checks a template and all of its planned entries before it
+// reaches the database, recording every violation in v.
+func ValidateTemplate(v *validator.Validator, template *WorkoutTemplate) {
+	v.Check(validator.NotBlank(template.Title), "title", "must be provided")
+	v.Check(validator.MaxChars(template.Title, 255), "title", "must not be more than 255 characters long")
+	v.Check(template.DurationMinutes > 0, "duration_minutes", "must be greater than zero")
+
+	indexes := make([]int, len(template.Entries))
+	for i := range template.Entries {
+		validateTemplateEntry(v, fmt.Sprintf("entries[%d]", i), &template.Entries[i])
+		indexes[i] = template.Entries[i].OrderIndex
+	}
+	validatePositions(v, "entries", "order_index", indexes)
+}
+
+func validateTemplateEntry(v *validator.Validator, prefix string, entry *TemplateEntry) {
+	v.Check(validator.NotBlank(entry.ExerciseName), prefix+".exercise_name", "must be provided")
+	v.Check(validator.MaxChars(entry.ExerciseName, 255), prefix+".exercise_name", "must not be more than 255 characters long")
+	v.Check(entry.SetsMin >= 1, prefix+".sets_min", "must be at least 1")
+	if entry.SetsMax != nil {
+		v.Check(*entry.SetsMax >= entry.SetsMin, prefix+".sets_max", "must not be less than sets_min")
+	}
+	v.Check(validator.ExactlyOne(entry.RepsMin != nil, entry.DurationSeconds != nil), prefix, "must have exactly one of reps_min or duration_seconds")
+	if entry.RepsMin != nil {
+		v.Check(*entry.RepsMin > 0, prefix+".reps_min", "must be greater than zero")
+		if entry.RepsMax != nil {
+			v.Check(*entry.RepsMax >= *entry.RepsMin, prefix+".reps_max", "must not be less than reps_min")
+		}
+	} else {
+		v.Check(entry.RepsMax == nil, prefix+".reps_max", "must not be set without reps_min")
+	}
+	if entry.DurationSeconds != nil {
+		v.Check(*entry.DurationSeconds > 0, prefix+".duration_seconds", "must be greater than zero")
+	}
+	if entry.WeightMin != nil {
+		v.Check(*entry.WeightMin >= 0, prefix+".weight_min", "must not be negative")
+		if entry.WeightMax != nil {
+			v.Check(*entry.WeightMax >= *entry.WeightMin, prefix+".weight_max", "must not be less than weight_min")
+		}
+	} else {
+		v.Check(entry.WeightMax == nil, prefix+".weight_max", "must not be set without weight_min")
+	}
+}
@@ -1,11 +1,34 @@
 package store
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
 	"time"
 	"github.com/makhammatovb/femProject/internal/tokens"
 )
 
+// Session describes an active authentication token without exposing it.
+type Session struct {
+	ID         int64      `json:"id"`
+	Hash       []byte     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Expiry     time.Time  `json:"expiry"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UserAgent  string     `json:"user_agent"`
+	Current    bool       `json:"current"`
+}
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrTokenReused  = errors.New("refresh token reused")
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 type PostgresTokenStore struct {
 	db *sql.DB
 }
@@ -18,6 +41,10 @@ type TokenStore interface {
 	Insert (tokens *tokens.Token) error
 	CreateNewToken (userID int64, ttl time.Duration, scope string) (*tokens.Token, error)
 	DeleteAllTokenForUser (userID int64, scope string) error
+	DeleteToken(plainText string) error
+	GetSessionsForUser(userID int64, currentPlainText string) ([]*Session, error)
+	CreateSession(userID int64, userAgent string) (*tokens.Token, *tokens.Token, error)
+	RotateRefreshToken(plainText, userAgent string) (*tokens.Token, *tokens.Token, error)
 }
 
 func (t *PostgresTokenStore) CreateNewToken(userID int64, ttl time.Duration, scope string) (*tokens.Token, error) {
@@ -35,10 +62,14 @@ func (t *PostgresTokenStore) CreateNewToken(userID int64, ttl time.Duration, sco
 }
 
 func (t *PostgresTokenStore) Insert(token *tokens.Token) error {
+	return insertToken(t.db, token)
+}
+
+func insertToken(db execer, token *tokens.Token) error {
 	query := `
-	INSERT INTO tokens (hash, user_id, expiry, scope)
-	VALUES ($1, $2, $3, $4);`
-	_, err := t.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	INSERT INTO tokens (hash, user_id, expiry, scope, user_agent, family)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''));`
+	_, err := db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.Family)
 	if err != nil {
 		return err
 	}
@@ -54,3 +85,153 @@ func (t *PostgresTokenStore) DeleteAllTokenForUser(userID int64, scope string) e
 	}
 	return err
 }
+
+// DeleteToken revokes a token together with every other token issued from
+// the same login.
+func (t *PostgresTokenStore) DeleteToken(plainText string) error {
+	query := `
+	DELETE FROM tokens
+	WHERE hash = $1 OR family = (SELECT family FROM tokens WHERE hash = $1);`
+	_, err := t.db.Exec(query, tokens.Hash(plainText))
+	return err
+}
+
+// GetSessionsForUser lists the unexpired authentication tokens of a user,
+// marking the one matching currentPlainText as the current session.
+func (t *PostgresTokenStore) GetSessionsForUser(userID int64, currentPlainText string) ([]*Session, error) {
+	query := `
+	SELECT id, hash, created_at, expiry, last_used_at, user_agent
+	FROM tokens
+	WHERE user_id = $1 AND scope = $2 AND expiry > $3
+	ORDER BY created_at DESC;`
+	rows, err := t.db.Query(query, userID, tokens.ScopeAuthentication, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currentHash := tokens.Hash(currentPlainText)
+	sessions := []*Session{}
+	for rows.Next() {
+		session := &Session{}
+		err := rows.Scan(&session.ID, &session.Hash, &session.CreatedAt, &session.Expiry, &session.LastUsedAt, &session.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+		session.Current = bytes.Equal(session.Hash, currentHash)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// CreateSession issues a new authentication and refresh token pair starting a
+// new token family.
+func (t *PostgresTokenStore) CreateSession(userID int64, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	family, err := tokens.GenerateFamily()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	access, refresh, err := insertTokenPair(tx, userID, userAgent, family)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair of the
+// same family. A refresh token can only be used once, presenting it again
+// revokes the whole family and returns ErrTokenReused.
+func (t *PostgresTokenStore) RotateRefreshToken(plainText, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		userID int64
+		family sql.NullString
+		expiry time.Time
+		usedAt *time.Time
+	)
+	hash := tokens.Hash(plainText)
+	query := `
+	SELECT user_id, family, expiry, used_at
+	FROM tokens
+	WHERE hash = $1 AND scope = $2
+	FOR UPDATE;`
+	err = tx.QueryRow(query, hash, tokens.ScopeRefresh).Scan(&userID, &family, &expiry, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if usedAt != nil {
+		_, err = tx.Exec(`DELETE FROM tokens WHERE family = $1;`, family.String)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrTokenReused
+	}
+	if expiry.Before(time.Now()) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	_, err = tx.Exec(`UPDATE tokens SET used_at = NOW() WHERE hash = $1;`, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the authentication tokens issued earlier in this family are superseded
+	_, err = tx.Exec(`DELETE FROM tokens WHERE family = $1 AND scope = $2;`, family.String, tokens.ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err := insertTokenPair(tx, userID, userAgent, family.String)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+func insertTokenPair(db execer, userID int64, userAgent, family string) (*tokens.Token, *tokens.Token, error) {
+	access, err := tokens.GenerateToken(userID, tokens.AuthenticationTTL, tokens.ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh, err := tokens.GenerateToken(userID, tokens.RefreshTTL, tokens.ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, token := range []*tokens.Token{access, refresh} {
+		token.UserAgent = userAgent
+		token.Family = family
+		err = insertToken(db, token)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return access, refresh, nil
+}
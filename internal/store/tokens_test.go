@@ -0,0 +1,53 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresTokenStore(db)
+	userStore := NewPostgresUserStore(db)
+	user := createTestUser(t, db)
+
+	access, refresh, err := store.CreateSession(int64(user.ID), "test")
+	require.NoError(t, err)
+	_, err = userStore.GetUserToken(tokens.ScopeAuthentication, access.PlainText)
+	require.NoError(t, err)
+
+	// rotating issues a new pair and supersedes the old access token
+	newAccess, newRefresh, err := store.RotateRefreshToken(refresh.PlainText, "test")
+	require.NoError(t, err)
+	assert.NotEqual(t, refresh.PlainText, newRefresh.PlainText)
+	_, err = userStore.GetUserToken(tokens.ScopeAuthentication, access.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = userStore.GetUserToken(tokens.ScopeAuthentication, newAccess.PlainText)
+	require.NoError(t, err)
+
+	// a used refresh token is rejected and revokes its whole family
+	_, _, err = store.RotateRefreshToken(refresh.PlainText, "test")
+	assert.ErrorIs(t, err, ErrTokenReused)
+	_, err = userStore.GetUserToken(tokens.ScopeAuthentication, newAccess.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = store.RotateRefreshToken(newRefresh.PlainText, "test")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// other logins of the same user are not affected
+	otherAccess, otherRefresh, err := store.CreateSession(int64(user.ID), "other")
+	require.NoError(t, err)
+	_, _, err = store.RotateRefreshToken(refresh.PlainText, "test")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = userStore.GetUserToken(tokens.ScopeAuthentication, otherAccess.PlainText)
+	require.NoError(t, err)
+	_, _, err = store.RotateRefreshToken(otherRefresh.PlainText, "other")
+	require.NoError(t, err)
+
+	_, _, err = store.RotateRefreshToken("not-a-token", "test")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -43,6 +43,12 @@ type User struct {
 	PasswordHash password  `json:"-"`
 	Email    string    `json:"email"`
 	BIO     string    `json:"bio"`
+	Activated    bool      `json:"activated"`
+	PendingEmail string    `json:"pending_email,omitempty"`
+	BodyWeight   *float64  `json:"body_weight,omitempty"`
+	Sex          string    `json:"sex,omitempty"`
+	Units        string    `json:"units"`
+	Version      int       `json:"version"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -65,6 +71,7 @@ type UserStore interface {
 	CreateUser(user *User) error
 	GetUserByID(id int64) (*User, error)
 	GetUserByUsername(username string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
 	UpdateUser(user *User) error
 	DeleteUser(id int64) error
 	GetUserToken(scope, tokenPlainText string) (*User, error)
@@ -73,11 +80,11 @@ type UserStore interface {
 func (pg *PostgresUserStore) CreateUser(user *User) error {
 	query :=
 		`INSERT INTO users (username, email, password_hash, bio, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id;
+	VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id, units, version, created_at, updated_at;
 	`
-	err := pg.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.BIO, user.CreatedAt, user.UpdatedAt).Scan(&user.ID)
+	err := pg.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.BIO).Scan(&user.ID, &user.Units, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return err
+		return mapError(err)
 	}
 	return nil
 }
@@ -85,14 +92,11 @@ func (pg *PostgresUserStore) CreateUser(user *User) error {
 func (pg *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 	user := &User{PasswordHash: password{}}
 	query := `
-	SELECT id, username, email, password_hash, bio, created_at, updated_at from users where id = $1;
+	SELECT id, username, email, password_hash, bio, activated, COALESCE(pending_email, ''), body_weight, COALESCE(sex, ''), units, version, created_at, updated_at from users where id = $1;
 	`
-	err := pg.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.BIO, &user.CreatedAt, &user.UpdatedAt)
+	err := pg.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.BIO, &user.Activated, &user.PendingEmail, &user.BodyWeight, &user.Sex, &user.Units, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+		return nil, mapError(err)
 	}
 	return user, nil
 }
@@ -100,33 +104,39 @@ func (pg *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 func (pg *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	user := &User{PasswordHash: password{}}
 	query := `
-	SELECT id, username, email, password_hash, bio, created_at, updated_at from users where username = $1;
+	SELECT id, username, email, password_hash, bio, activated, COALESCE(pending_email, ''), body_weight, COALESCE(sex, ''), units, version, created_at, updated_at from users where username = $1;
 	`
-	err := pg.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.BIO, &user.CreatedAt, &user.UpdatedAt)
+	err := pg.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.BIO, &user.Activated, &user.PendingEmail, &user.BodyWeight, &user.Sex, &user.Units, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+		return nil, mapError(err)
 	}
 	return user, nil
 }
 
-func (pg *PostgresUserStore) UpdateUser(user *User) error {
+func (pg *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
+	user := &User{PasswordHash: password{}}
 	query := `
-	UPDATE users SET username = $1, email = $2, password_hash = $3, bio = $4, updated_at = NOW()
-	WHERE id = $5;
+	SELECT id, username, email, password_hash, bio, activated, COALESCE(pending_email, ''), body_weight, COALESCE(sex, ''), units, version, created_at, updated_at from users where email = $1;
 	`
-	result, err := pg.db.Exec(query, user.Username, user.Email, user.PasswordHash, user.BIO, user.ID)
+	err := pg.db.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.BIO, &user.Activated, &user.PendingEmail, &user.BodyWeight, &user.Sex, &user.Units, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return err
+		return nil, mapError(err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	return user, nil
+}
+
+func (pg *PostgresUserStore) UpdateUser(user *User) error {
+	query := `
+	UPDATE users SET username = $1, email = $2, password_hash = $3, bio = $4, activated = $5, pending_email = NULLIF($6, ''), body_weight = $7, body_weight_updated_at = CASE WHEN body_weight IS DISTINCT FROM $7 THEN NOW() ELSE body_weight_updated_at END, sex = NULLIF($8, ''), units = $9, version = version + 1, updated_at = NOW()
+	WHERE id = $10 AND version = $11
+	RETURNING version, updated_at;
+	`
+	err := pg.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.BIO, user.Activated, user.PendingEmail, user.BodyWeight, user.Sex, user.Units, user.ID, user.Version).Scan(&user.Version, &user.UpdatedAt)
 	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("user with ID %d not found", user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return mapError(err)
 	}
 	return nil
 }
@@ -144,7 +154,7 @@ func (pg *PostgresUserStore) DeleteUser(id int64) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("article with ID %d not found", id)
+		return ErrNotFound
 	}
 	return nil
 }
@@ -152,11 +162,16 @@ func (pg *PostgresUserStore) DeleteUser(id int64) error {
 func (s *PostgresUserStore) GetUserToken (scope, plaintextPassword string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(plaintextPassword))
 
+	// looking a token up counts as using it, so last_used_at is refreshed in the same statement
 	query := `
-	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+	WITH t AS (
+		UPDATE tokens SET last_used_at = NOW()
+		WHERE hash = $1 AND scope = $2 AND expiry > $3
+		RETURNING user_id
+	)
+	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.activated, COALESCE(u.pending_email, ''), u.body_weight, COALESCE(u.sex, ''), u.units, u.version, u.created_at, u.updated_at
 	FROM users u
-	INNER JOIN tokens t ON u.id = t.user_id
-	WHERE t.hash = $1 AND t.scope = $2 and t.expiry > $3;
+	INNER JOIN t ON u.id = t.user_id;
 	`
 
 	user := &User{
@@ -166,18 +181,20 @@ func (s *PostgresUserStore) GetUserToken (scope, plaintextPassword string) (*Use
 		&user.ID,
 		&user.Username,
 		&user.Email,
-		&user.PasswordHash,
+		&user.PasswordHash.hash,
 		&user.BIO,
+		&user.Activated,
+		&user.PendingEmail,
+		&user.BodyWeight,
+		&user.Sex,
+		&user.Units,
+		&user.Version,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return user, nil
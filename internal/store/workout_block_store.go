@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+)
+
+// Block types of a workout block.
+const (
+	BlockStraight = "straight"
+	BlockSuperset = "superset"
+	BlockCircuit  = "circuit"
+	BlockEMOM     = "emom"
+	BlockAMRAP    = "amrap"
+	BlockTabata   = "tabata"
+)
+
+// BlockTypes holds the values accepted for WorkoutBlock.Type.
+var BlockTypes = []string{BlockStraight, BlockSuperset, BlockCircuit, BlockEMOM, BlockAMRAP, BlockTabata}
+
+// WorkoutBlock groups the entries of a workout that are done together, such as
+// a superset or an EMOM. Entries join a block by naming its position, and the
+// members of a block follow each other in order_index order.
+//
+// Every member is done once per round, so its sets count Rounds times: a
+// superset of 3 rounds with members of 1 set each is 3 sets of each member.
+// WorkSeconds is the length of a work interval (a minute for an EMOM, the time
+// cap for an AMRAP) and RestSeconds the rest after it.
+type WorkoutBlock struct {
+	Position    int    `json:"position"`
+	Type        string `json:"type"`
+	Rounds      int    `json:"rounds"`
+	WorkSeconds *int   `json:"work_seconds"`
+	RestSeconds *int   `json:"rest_seconds"`
+	Notes       string `json:"notes"`
+}
+
+// replaceBlocks stores blocks as the blocks of a workout. Entries keep
+// pointing at the old blocks until they are written again, which callers do
+// right after.
+func replaceBlocks(tx *sql.Tx, workoutID int64, blocks []WorkoutBlock) error {
+	_, err := tx.Exec(`DELETE FROM workout_blocks WHERE workout_id = $1;`, workoutID)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO workout_blocks (workout_id, position, block_type, rounds, work_seconds, rest_seconds, notes)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+	for _, block := range blocks {
+		_, err := tx.Exec(query, workoutID, block.Position, block.Type, block.Rounds, block.WorkSeconds, block.RestSeconds, block.Notes)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// linkBlock checks that an entry naming a block position was linked to a
+// block of its workout.
+func linkBlock(entry *WorkoutEntry, blockID *int64) error {
+	if entry.Block != nil && blockID == nil {
+		return &ConstraintError{Constraint: "workout_entries_block_id_fkey"}
+	}
+	return nil
+}
+
+// loadBlocks fetches the blocks of all given workouts in a single query.
+func loadBlocks(q querier, workouts []*Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(workouts))
+	byID := make(map[int64]*Workout, len(workouts))
+	for i, workout := range workouts {
+		workout.Blocks = []WorkoutBlock{}
+		ids[i] = int64(workout.ID)
+		byID[int64(workout.ID)] = workout
+	}
+
+	query := `
+	SELECT workout_id, position, block_type, rounds, work_seconds, rest_seconds, notes
+	FROM workout_blocks WHERE workout_id = ANY($1) ORDER BY workout_id, position;
+	`
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var workoutID int64
+		var block WorkoutBlock
+		err := rows.Scan(&workoutID, &block.Position, &block.Type, &block.Rounds, &block.WorkSeconds, &block.RestSeconds, &block.Notes)
+		if err != nil {
+			return err
+		}
+		workout := byID[workoutID]
+		workout.Blocks = append(workout.Blocks, block)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,361 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrInvalidEntryOrder is returned when a new entry order does not list every
+// entry of the workout exactly once.
+var ErrInvalidEntryOrder = errors.New("entry order must list every entry of the workout exactly once")
+
+// AddWorkoutEntry inserts an entry at entry.OrderIndex, moving the entries at
+// and after that position down by one. An order index of 0 or past the end
+// appends the entry.
+//
+// Like the other entry changes it only applies to the given version of the
+// workout, returning ErrEditConflict otherwise, and returns the version the
+// change bumped the workout to.
+func (pg *PostgresWorkoutStore) AddWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := lockWorkoutVersion(tx, workoutID, version)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM workout_entries WHERE workout_id = $1;`, workoutID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if entry.OrderIndex < 1 || entry.OrderIndex > count+1 {
+		entry.OrderIndex = count + 1
+	}
+
+	query := `
+	UPDATE workout_entries SET order_index = order_index + 1
+	WHERE workout_id = $1 AND order_index >= $2;
+	`
+	_, err = tx.Exec(query, workoutID, entry.OrderIndex)
+	if err != nil {
+		return 0, err
+	}
+	err = insertEntry(tx, workoutID, entry)
+	if err != nil {
+		return 0, err
+	}
+	_, err = refreshWorkoutRecords(tx, userID, workoutID, nil)
+	if err != nil {
+		return 0, err
+	}
+	err = refreshEstimatedCalories(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	version, err = touchWorkout(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// UpdateWorkoutEntry saves the fields of an existing entry. Its position is
+// left alone, ReorderWorkoutEntries moves entries around.
+func (pg *PostgresWorkoutStore) UpdateWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := lockWorkoutVersion(tx, workoutID, version)
+	if err != nil {
+		return 0, err
+	}
+	before, err := workoutExerciseKeys(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	prepareSets(entry)
+	query := `
+	UPDATE workout_entries SET exercise_name = $1, reps = $2, sets = $3, weight = $4, duration_seconds = $5, notes = $6, updated_at = NOW(),
+		exercise_id = resolve_exercise((SELECT user_id FROM workouts WHERE id = $8), $9, $1),
+		block_id = (SELECT id FROM workout_blocks WHERE workout_id = $8 AND position = $10)
+	WHERE id = $7 AND workout_id = $8
+	RETURNING exercise_id, block_id, order_index, created_at, updated_at;
+	`
+	var exerciseID *int
+	var blockID *int64
+	err = tx.QueryRow(query, entry.ExerciseName, entry.Reps, entry.Sets, entry.Weight, entry.DurationSeconds, entry.Notes, entry.ID, workoutID, entry.ExerciseID, entry.Block).Scan(&exerciseID, &blockID, &entry.OrderIndex, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	err = linkBlock(entry, blockID)
+	if err != nil {
+		return 0, err
+	}
+	err = linkExercise(entry, exerciseID)
+	if err != nil {
+		return 0, err
+	}
+	err = replaceSets(tx, entry)
+	if err != nil {
+		return 0, err
+	}
+	_, err = refreshWorkoutRecords(tx, userID, workoutID, before)
+	if err != nil {
+		return 0, err
+	}
+	err = refreshEstimatedCalories(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	version, err = touchWorkout(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// DeleteWorkoutEntry removes an entry and closes the gap it leaves in the
+// order of the remaining entries.
+func (pg *PostgresWorkoutStore) DeleteWorkoutEntry(workoutID int64, version int, entryID int64) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := lockWorkoutVersion(tx, workoutID, version)
+	if err != nil {
+		return 0, err
+	}
+	before, err := workoutExerciseKeys(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	var orderIndex int
+	query := `
+	DELETE FROM workout_entries WHERE id = $1 AND workout_id = $2
+	RETURNING order_index;
+	`
+	err = tx.QueryRow(query, entryID, workoutID).Scan(&orderIndex)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	query = `
+	UPDATE workout_entries SET order_index = order_index - 1
+	WHERE workout_id = $1 AND order_index > $2;
+	`
+	_, err = tx.Exec(query, workoutID, orderIndex)
+	if err != nil {
+		return 0, err
+	}
+	_, err = refreshWorkoutRecords(tx, userID, workoutID, before)
+	if err != nil {
+		return 0, err
+	}
+	err = refreshEstimatedCalories(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	version, err = touchWorkout(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// ReorderWorkoutEntries numbers the entries of a workout in the order of
+// entryIDs, which must hold the ID of every entry exactly once.
+func (pg *PostgresWorkoutStore) ReorderWorkoutEntries(workoutID int64, version int, entryIDs []int64) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := lockWorkoutVersion(tx, workoutID, version)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.Query(`SELECT id FROM workout_entries WHERE workout_id = $1;`, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	current := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(entryIDs) != len(current) {
+		return 0, ErrInvalidEntryOrder
+	}
+	seen := make(map[int64]bool, len(entryIDs))
+	for _, id := range entryIDs {
+		if !current[id] || seen[id] {
+			return 0, ErrInvalidEntryOrder
+		}
+		seen[id] = true
+	}
+
+	query := `
+	UPDATE workout_entries e SET order_index = o.position, updated_at = NOW()
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS o(id, position)
+	WHERE e.workout_id = $1 AND e.id = o.id;
+	`
+	_, err = tx.Exec(query, workoutID, entryIDs)
+	if err != nil {
+		return 0, err
+	}
+	// the order decides which of two equal sets in the workout holds a record
+	_, err = refreshWorkoutRecords(tx, userID, workoutID, nil)
+	if err != nil {
+		return 0, err
+	}
+	version, err = touchWorkout(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// lockWorkout takes a row lock on the workout so concurrent changes to its
+// entries renumber order_index one after the other, and returns its owner.
+func lockWorkout(tx *sql.Tx, workoutID int64) (int, error) {
+	var userID int
+	err := tx.QueryRow(`SELECT user_id FROM workouts WHERE id = $1 FOR UPDATE;`, workoutID).Scan(&userID)
+	return userID, mapError(err)
+}
+
+// lockWorkoutVersion is lockWorkout for a change made against the given
+// version of the workout. It returns ErrEditConflict once the workout has
+// moved on, so the change is not applied to entries the client has not seen.
+func lockWorkoutVersion(tx *sql.Tx, workoutID int64, version int) (int, error) {
+	var userID int
+	err := tx.QueryRow(`SELECT user_id FROM workouts WHERE id = $1 AND version = $2 FOR UPDATE;`, workoutID, version).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEditConflict
+	}
+	return userID, mapError(err)
+}
+
+// touchWorkout bumps the version of a workout whose entries changed, so ETags
+// and If-Match checks on the workout notice the change, and returns the new
+// version.
+func touchWorkout(tx *sql.Tx, workoutID int64) (int, error) {
+	var version int
+	err := tx.QueryRow(`UPDATE workouts SET version = version + 1, updated_at = NOW() WHERE id = $1 RETURNING version;`, workoutID).Scan(&version)
+	return version, err
+}
+
+// insertEntry stores a new entry of a workout with its sets, linking it to the
+// exercise it names when it has no exercise_id and to the block at its block
+// position.
+func insertEntry(tx *sql.Tx, workoutID int64, entry *WorkoutEntry) error {
+	prepareSets(entry)
+	query := `
+	INSERT INTO workout_entries (workout_id, exercise_name, reps, sets, weight, duration_seconds, notes, order_index, exercise_id, block_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, resolve_exercise((SELECT user_id FROM workouts WHERE id = $1), $9, $2),
+		(SELECT id FROM workout_blocks WHERE workout_id = $1 AND position = $10))
+	RETURNING id, exercise_id, block_id, created_at, updated_at;
+	`
+	var exerciseID *int
+	var blockID *int64
+	err := tx.QueryRow(query, workoutID, entry.ExerciseName, entry.Reps, entry.Sets, entry.Weight, entry.DurationSeconds, entry.Notes, entry.OrderIndex, entry.ExerciseID, entry.Block).Scan(&entry.ID, &exerciseID, &blockID, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+	err = linkBlock(entry, blockID)
+	if err != nil {
+		return err
+	}
+	err = linkExercise(entry, exerciseID)
+	if err != nil {
+		return err
+	}
+	return replaceSets(tx, entry)
+}
+
+// linkExercise records the exercise an entry was linked to. An exercise_id the
+// workout owner cannot use resolves to nothing and is rejected.
+func linkExercise(entry *WorkoutEntry, exerciseID *int) error {
+	if entry.ExerciseID != nil && exerciseID == nil {
+		return &ConstraintError{Constraint: "workout_entries_exercise_id_fkey"}
+	}
+	entry.ExerciseID = exerciseID
+	return nil
+}
+
+// syncEntries makes the stored entries of a workout match entries. Entries
+// with an ID are updated in place, entries without one are inserted and the
+// stored entries missing from the list are deleted, so IDs and created_at
+// survive a full update.
+func syncEntries(tx *sql.Tx, workoutID int64, entries []WorkoutEntry) error {
+	keep := []int64{}
+	for _, entry := range entries {
+		if entry.ID != 0 {
+			keep = append(keep, int64(entry.ID))
+		}
+	}
+	_, err := tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1 AND NOT (id = ANY($2));`, workoutID, keep)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE workout_entries SET exercise_name = $1, reps = $2, sets = $3, weight = $4, duration_seconds = $5, notes = $6, order_index = $7, updated_at = NOW(),
+		exercise_id = resolve_exercise((SELECT user_id FROM workouts WHERE id = $9), $10, $1),
+		block_id = (SELECT id FROM workout_blocks WHERE workout_id = $9 AND position = $11)
+	WHERE id = $8 AND workout_id = $9
+	RETURNING exercise_id, block_id, created_at, updated_at;
+	`
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ID == 0 {
+			err = insertEntry(tx, workoutID, entry)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		prepareSets(entry)
+		var exerciseID *int
+		var blockID *int64
+		err = tx.QueryRow(query, entry.ExerciseName, entry.Reps, entry.Sets, entry.Weight, entry.DurationSeconds, entry.Notes, entry.OrderIndex, entry.ID, workoutID, entry.ExerciseID, entry.Block).Scan(&exerciseID, &blockID, &entry.CreatedAt, &entry.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the ID belongs to another workout or to an entry that no longer exists
+			return &ConstraintError{Constraint: "workout_entries_id", Err: err}
+		}
+		if err != nil {
+			return mapError(err)
+		}
+		err = linkBlock(entry, blockID)
+		if err != nil {
+			return err
+		}
+		err = linkExercise(entry, exerciseID)
+		if err != nil {
+			return err
+		}
+		err = replaceSets(tx, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
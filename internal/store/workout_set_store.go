@@ -0,0 +1,194 @@
+package store
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// Set types of a workout set.
+const (
+	SetWarmup  = "warmup"
+	SetWorking = "working"
+	SetDrop    = "drop"
+	SetFailure = "failure"
+)
+
+// SetTypes holds the values accepted for WorkoutSet.Type.
+var SetTypes = []string{SetWarmup, SetWorking, SetDrop, SetFailure}
+
+// WorkoutSet is one logged set of an entry. SetNumber follows the position of
+// the set in the set list. An empty Type is a working set and a nil Completed
+// a completed one.
+type WorkoutSet struct {
+	SetNumber       int      `json:"set_number"`
+	Type            string   `json:"type"`
+	Reps            *int     `json:"reps"`
+	Weight          *float64 `json:"weight"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	RPE             *float64 `json:"rpe"`
+	RIR             *int     `json:"rir"`
+	Completed       *bool    `json:"completed"`
+}
+
+// counts tells whether a set counts towards the summary of its entry, volume
+// and records. Warm-ups and sets that were not completed do not.
+func (s *WorkoutSet) counts() bool {
+	return s.Type != SetWarmup && (s.Completed == nil || *s.Completed)
+}
+
+// prepareSets makes the set list and the summary fields of an entry agree
+// before it is stored. An entry without a set list gets Sets identical working
+// sets, otherwise its summary is derived from the set list.
+func prepareSets(entry *WorkoutEntry) {
+	if len(entry.SetList) == 0 {
+		entry.SetList = make([]WorkoutSet, entry.Sets)
+		for i := range entry.SetList {
+			entry.SetList[i] = WorkoutSet{Reps: entry.Reps, Weight: entry.Weight, DurationSeconds: entry.DurationSeconds}
+		}
+	}
+	for i := range entry.SetList {
+		set := &entry.SetList[i]
+		set.SetNumber = i + 1
+		if set.Type == "" {
+			set.Type = SetWorking
+		}
+		if set.Completed == nil {
+			completed := true
+			set.Completed = &completed
+		}
+	}
+	summarizeSets(entry)
+}
+
+// summarizeSets derives the summary fields of an entry from its set list:
+// Sets counts the sets that count, Reps and Weight come from the heaviest of
+// them and DurationSeconds from the longest. When no set counts yet, as while
+// a workout is being logged, every set is summed up instead.
+func summarizeSets(entry *WorkoutEntry) {
+	pool := make([]WorkoutSet, 0, len(entry.SetList))
+	for _, set := range entry.SetList {
+		if set.counts() {
+			pool = append(pool, set)
+		}
+	}
+	if len(pool) == 0 {
+		pool = entry.SetList
+	}
+
+	entry.Sets = len(pool)
+	entry.Reps, entry.Weight, entry.DurationSeconds = nil, nil, nil
+	for _, set := range pool {
+		if set.DurationSeconds != nil && (entry.DurationSeconds == nil || *set.DurationSeconds > *entry.DurationSeconds) {
+			entry.DurationSeconds = set.DurationSeconds
+		}
+		if set.Reps == nil {
+			continue
+		}
+		if entry.Reps == nil || heavierSet(set, entry.Weight, *entry.Reps) {
+			entry.Reps, entry.Weight = set.Reps, set.Weight
+		}
+	}
+	// a timed entry keeps the weight of its heaviest set, e.g. a loaded carry
+	if entry.Reps == nil {
+		for _, set := range pool {
+			if set.Weight != nil && (entry.Weight == nil || *set.Weight > *entry.Weight) {
+				entry.Weight = set.Weight
+			}
+		}
+	}
+}
+
+// heavierSet tells whether set beats the top set so far, by weight and then by
+// reps.
+func heavierSet(set WorkoutSet, weight *float64, reps int) bool {
+	setWeight, topWeight := 0.0, 0.0
+	if set.Weight != nil {
+		setWeight = *set.Weight
+	}
+	if weight != nil {
+		topWeight = *weight
+	}
+	if setWeight != topWeight {
+		return setWeight > topWeight
+	}
+	return *set.Reps > reps
+}
+
+// ReconcileSetList drops the set list of an updated entry when its summary
+// fields were edited but its set list came back as it was, so the store
+// rebuilds the sets from the summary. Clients that do not know about set lists
+// keep working this way. old is the entry as stored.
+func ReconcileSetList(old, entry *WorkoutEntry) {
+	summaryEdited := entry.Sets != old.Sets ||
+		!reflect.DeepEqual(entry.Reps, old.Reps) ||
+		!reflect.DeepEqual(entry.Weight, old.Weight) ||
+		!reflect.DeepEqual(entry.DurationSeconds, old.DurationSeconds)
+	if !summaryEdited {
+		return
+	}
+	sent := WorkoutEntry{SetList: append([]WorkoutSet(nil), entry.SetList...)}
+	if len(sent.SetList) > 0 {
+		prepareSets(&sent)
+	}
+	if len(sent.SetList) == 0 || reflect.DeepEqual(sent.SetList, old.SetList) {
+		entry.SetList = nil
+	}
+}
+
+// replaceSets stores the set list of an entry in place of its previous sets.
+func replaceSets(tx *sql.Tx, entry *WorkoutEntry) error {
+	_, err := tx.Exec(`DELETE FROM workout_sets WHERE entry_id = $1;`, entry.ID)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO workout_sets (entry_id, set_number, set_type, reps, weight, duration_seconds, rpe, rir, completed)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+	for _, set := range entry.SetList {
+		_, err := tx.Exec(query, entry.ID, set.SetNumber, set.Type, set.Reps, set.Weight, set.DurationSeconds, set.RPE, set.RIR, set.Completed)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// loadSets fetches the sets of every entry of the given workouts in a single
+// query.
+func loadSets(q querier, workouts []*Workout) error {
+	byID := map[int64]*WorkoutEntry{}
+	ids := []int64{}
+	for _, workout := range workouts {
+		for i := range workout.Entries {
+			entry := &workout.Entries[i]
+			entry.SetList = []WorkoutSet{}
+			ids = append(ids, int64(entry.ID))
+			byID[int64(entry.ID)] = entry
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+	SELECT entry_id, set_number, set_type, reps, weight, duration_seconds, rpe, rir, completed
+	FROM workout_sets WHERE entry_id = ANY($1) ORDER BY entry_id, set_number;
+	`
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entryID int64
+		var set WorkoutSet
+		err := rows.Scan(&entryID, &set.SetNumber, &set.Type, &set.Reps, &set.Weight, &set.DurationSeconds, &set.RPE, &set.RIR, &set.Completed)
+		if err != nil {
+			return err
+		}
+		entry := byID[entryID]
+		entry.SetList = append(entry.SetList, set)
+	}
+	return rows.Err()
+}
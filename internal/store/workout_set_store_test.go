@@ -0,0 +1,84 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func BoolPtr(b bool) *bool {
+	return &b
+}
+
+func TestPrepareSets(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry WorkoutEntry
+		want  WorkoutEntry
+	}{
+		{
+			name:  "Expands The Summary",
+			entry: WorkoutEntry{Sets: 2, Reps: IntPtr(5), Weight: FloatPtr(100)},
+			want: WorkoutEntry{Sets: 2, Reps: IntPtr(5), Weight: FloatPtr(100), SetList: []WorkoutSet{
+				{SetNumber: 1, Type: SetWorking, Reps: IntPtr(5), Weight: FloatPtr(100), Completed: BoolPtr(true)},
+				{SetNumber: 2, Type: SetWorking, Reps: IntPtr(5), Weight: FloatPtr(100), Completed: BoolPtr(true)},
+			}},
+		},
+		{
+			name: "Summarizes The Top Set",
+			entry: WorkoutEntry{Sets: 9, Reps: IntPtr(1), SetList: []WorkoutSet{
+				{Type: SetWarmup, Reps: IntPtr(10), Weight: FloatPtr(60)},
+				{Reps: IntPtr(5), Weight: FloatPtr(100), RPE: FloatPtr(8)},
+				{Reps: IntPtr(6), Weight: FloatPtr(100)},
+				{Reps: IntPtr(3), Weight: FloatPtr(110), Completed: BoolPtr(false)},
+				{Type: SetDrop, Reps: IntPtr(10), Weight: FloatPtr(80)},
+			}},
+			want: WorkoutEntry{Sets: 3, Reps: IntPtr(6), Weight: FloatPtr(100), SetList: []WorkoutSet{
+				{SetNumber: 1, Type: SetWarmup, Reps: IntPtr(10), Weight: FloatPtr(60), Completed: BoolPtr(true)},
+				{SetNumber: 2, Type: SetWorking, Reps: IntPtr(5), Weight: FloatPtr(100), RPE: FloatPtr(8), Completed: BoolPtr(true)},
+				{SetNumber: 3, Type: SetWorking, Reps: IntPtr(6), Weight: FloatPtr(100), Completed: BoolPtr(true)},
+				{SetNumber: 4, Type: SetWorking, Reps: IntPtr(3), Weight: FloatPtr(110), Completed: BoolPtr(false)},
+				{SetNumber: 5, Type: SetDrop, Reps: IntPtr(10), Weight: FloatPtr(80), Completed: BoolPtr(true)},
+			}},
+		},
+		{
+			name: "Nothing Completed Yet",
+			entry: WorkoutEntry{SetList: []WorkoutSet{
+				{DurationSeconds: IntPtr(45), Completed: BoolPtr(false)},
+				{DurationSeconds: IntPtr(60), Weight: FloatPtr(24), Completed: BoolPtr(false)},
+			}},
+			want: WorkoutEntry{Sets: 2, Weight: FloatPtr(24), DurationSeconds: IntPtr(60), SetList: []WorkoutSet{
+				{SetNumber: 1, Type: SetWorking, DurationSeconds: IntPtr(45), Completed: BoolPtr(false)},
+				{SetNumber: 2, Type: SetWorking, DurationSeconds: IntPtr(60), Weight: FloatPtr(24), Completed: BoolPtr(false)},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prepareSets(&tt.entry)
+			assert.Equal(t, tt.want, tt.entry)
+		})
+	}
+}
+
+func TestReconcileSetList(t *testing.T) {
+	old := WorkoutEntry{Sets: 2, Reps: IntPtr(5), Weight: FloatPtr(100)}
+	prepareSets(&old)
+
+	// a client unaware of set lists edits the summary and sends the sets back
+	entry := old
+	entry.Weight = FloatPtr(105)
+	ReconcileSetList(&old, &entry)
+	assert.Nil(t, entry.SetList)
+
+	// editing the sets wins over the summary that came along unchanged
+	entry = old
+	entry.SetList = []WorkoutSet{{Reps: IntPtr(5), Weight: FloatPtr(110)}}
+	ReconcileSetList(&old, &entry)
+	assert.Len(t, entry.SetList, 1)
+
+	// editing both keeps the sets, the summary is derived from them
+	entry.Reps = IntPtr(8)
+	ReconcileSetList(&old, &entry)
+	assert.Len(t, entry.SetList, 1)
+}
@@ -2,36 +2,78 @@ package store
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/ydb-platform/ydb-go-sdk/v3/query"
 )
 
 type Workout struct {
-	ID              int            `json:"id"`
-	Title           string         `json:"title"`
-	Description     string         `json:"description"`
-	DurationMinutes int            `json:"duration_minutes"`
-	CaloriesBurned  int            `json:"calories_burned"`
-	Entries         []WorkoutEntry `json:"entries"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	ID                int              `json:"id"`
+	UserID            int              `json:"user_id"`
+	Title             string           `json:"title"`
+	Description       string           `json:"description"`
+	DurationMinutes   int              `json:"duration_minutes"`
+	CaloriesBurned    int              `json:"calories_burned"`
+	CaloriesEstimated bool             `json:"calories_estimated"`
+	SessionRPE        *float64         `json:"session_rpe"`
+	TemplateID        *int             `json:"template_id"`
+	PlannedWorkoutID  *int             `json:"planned_workout_id"`
+	AsPrescribed      *bool            `json:"as_prescribed"`
+	Entries           []WorkoutEntry   `json:"entries"`
+	Blocks            []WorkoutBlock   `json:"blocks"`
+	NewRecords        []PersonalRecord `json:"new_records,omitempty"`
+	Version           int              `json:"version"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
 }
 
 type WorkoutEntry struct {
-	ID              int       `json:"id"`
-	ExerciseName    string    `json:"exercise_name"`
-	Reps            *int      `json:"reps"`
-	Sets            int       `json:"sets"`
-	Weight          *float64  `json:"weight"`
-	DurationSeconds *int      `json:"duration_seconds"`
-	Notes           string    `json:"notes"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
-	OrderIndex      int       `json:"order_index"`
+	ID              int          `json:"id"`
+	ExerciseID      *int         `json:"exercise_id"`
+	ExerciseName    string       `json:"exercise_name"`
+	Reps            *int         `json:"reps"`
+	Sets            int          `json:"sets"`
+	Weight          *float64     `json:"weight"`
+	DurationSeconds *int         `json:"duration_seconds"`
+	Notes           string       `json:"notes"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	OrderIndex      int          `json:"order_index"`
+	Block           *int         `json:"block"`
+	SetList         []WorkoutSet `json:"set_list"`
 }
 
+// WorkoutFilter narrows down and orders the workouts returned by ListWorkouts.
+// CreatedFrom is inclusive and CreatedTo is exclusive.
+type WorkoutFilter struct {
+	UserID      int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	MinDuration *int
+	MaxDuration *int
+	Title       string
+	Exercise    string
+	Sort        string
+	Limit       int
+	Cursor      string
+}
+
+// WorkoutSortSafelist holds the values accepted for WorkoutFilter.Sort, a
+// leading "-" sorts in descending order.
+var WorkoutSortSafelist = []string{
+	"created_at", "-created_at",
+	"duration_minutes", "-duration_minutes",
+	"title", "-title",
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type PostgresWorkoutStore struct {
 	db *sql.DB
 }
@@ -45,6 +87,14 @@ type WorkoutStore interface {
 	GetWorkoutByID(id int64) (*Workout, error)
 	UpdateWorkout(*Workout) error
 	DeleteWorkout(id int64) error
+	GetWorkoutOwner(id int64) (int, error)
+	ListWorkouts(filter WorkoutFilter) ([]*Workout, string, error)
+	AddWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error)
+	UpdateWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error)
+	DeleteWorkoutEntry(workoutID int64, version int, entryID int64) (int, error)
+	ReorderWorkoutEntries(workoutID int64, version int, entryIDs []int64) (int, error)
+	EstimateCalories(workoutID int64) error
+	ReestimateCalories(userID int) (int, error)
 }
 
 func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
@@ -55,20 +105,29 @@ func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error
 	defer tx.Rollback()
 
 	query :=
-		`INSERT INTO workouts (title, description, duration_minutes, calories_burned)
-	VALUES ($1, $2, $3, $4) RETURNING id;
+		`INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned, calories_estimated, session_rpe, template_id, planned_workout_id, as_prescribed)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, version, created_at, updated_at;
 	`
-	err = tx.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
+	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesEstimated, workout.SessionRPE, workout.TemplateID, workout.PlannedWorkoutID, workout.AsPrescribed).Scan(&workout.ID, &workout.Version, &workout.CreatedAt, &workout.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	err = replaceBlocks(tx, int64(workout.ID), workout.Blocks)
 	if err != nil {
 		return nil, err
 	}
-	for i, entry := range workout.Entries {
-		fmt.Printf("DEBUG: Inserting entry %d: %+v\n", i, entry)
-		query :=
-			`INSERT INTO workout_entries (workout_id, exercise_name, reps, sets, weight, duration_seconds, notes, order_index)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;
-		`
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Reps, entry.Sets, entry.Weight, entry.DurationSeconds, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	for i := range workout.Entries {
+		err = insertEntry(tx, int64(workout.ID), &workout.Entries[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	workout.NewRecords, err = refreshWorkoutRecords(tx, workout.UserID, int64(workout.ID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if workout.CaloriesEstimated {
+		workout.CaloriesBurned, err = estimateWorkoutCalories(tx, int64(workout.ID))
 		if err != nil {
 			return nil, err
 		}
@@ -83,19 +142,18 @@ func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error
 func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 	workout := &Workout{}
 	query := `
-	SELECT id, title, description, duration_minutes, calories_burned, created_at, updated_at
+	SELECT id, user_id, title, description, duration_minutes, calories_burned, calories_estimated, session_rpe, template_id, planned_workout_id, as_prescribed, version, created_at, updated_at
 	FROM workouts WHERE id = $1;
 	`
-	err := pg.db.QueryRow(query, id).Scan(&workout.ID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.CreatedAt, &workout.UpdatedAt)
+	err := pg.db.QueryRow(query, id).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.CaloriesEstimated, &workout.SessionRPE, &workout.TemplateID, &workout.PlannedWorkoutID, &workout.AsPrescribed, &workout.Version, &workout.CreatedAt, &workout.UpdatedAt)
 	if err != nil {
-		return nil, err
-	}
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("workout with ID %d not found", id)
+		return nil, mapError(err)
 	}
 	entriesQuery := `
-	SELECT id, exercise_name, reps, sets, weight, duration_seconds, notes, order_index, created_at, updated_at
-	FROM workout_entries WHERE workout_id = $1 ORDER BY order_index;
+	SELECT e.id, e.exercise_id, e.exercise_name, e.reps, e.sets, e.weight, e.duration_seconds, e.notes, e.order_index, b.position, e.created_at, e.updated_at
+	FROM workout_entries e
+	LEFT JOIN workout_blocks b ON b.id = e.block_id
+	WHERE e.workout_id = $1 ORDER BY e.order_index;
 	`
 	rows, err := pg.db.Query(entriesQuery, id)
 	if err != nil {
@@ -104,12 +162,23 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 	defer rows.Close()
 	for rows.Next() {
 		var entry WorkoutEntry
-		err := rows.Scan(&entry.ID, &entry.ExerciseName, &entry.Reps, &entry.Sets, &entry.Weight, &entry.DurationSeconds, &entry.Notes, &entry.OrderIndex, &entry.CreatedAt, &entry.UpdatedAt)
+		err := rows.Scan(&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.Reps, &entry.Sets, &entry.Weight, &entry.DurationSeconds, &entry.Notes, &entry.OrderIndex, &entry.Block, &entry.CreatedAt, &entry.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		workout.Entries = append(workout.Entries, entry)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = loadBlocks(pg.db, []*Workout{workout})
+	if err != nil {
+		return nil, err
+	}
+	err = loadSets(pg.db, []*Workout{workout})
+	if err != nil {
+		return nil, err
+	}
 	return workout, nil
 }
 
@@ -119,35 +188,37 @@ func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
 		return err
 	}
 	defer tx.Rollback()
+	// the version check makes concurrent updates fail instead of overwriting each other
 	query := `
-	UPDATE workouts SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, updated_at = NOW()
-	WHERE id = $5;
+	UPDATE workouts SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, calories_estimated = $5, session_rpe = $6, version = version + 1, updated_at = NOW()
+	WHERE id = $7 AND user_id = $8 AND version = $9
+	RETURNING version, updated_at;
 	`
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	err = tx.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesEstimated, workout.SessionRPE, workout.ID, workout.UserID, workout.Version).Scan(&workout.Version, &workout.UpdatedAt)
 	if err != nil {
-		return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return mapError(err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	before, err := workoutExerciseKeys(tx, int64(workout.ID))
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("workout with ID %d not found", workout.ID)
+	err = replaceBlocks(tx, int64(workout.ID), workout.Blocks)
+	if err != nil {
+		return err
 	}
-	err = tx.Commit()
+	err = syncEntries(tx, int64(workout.ID), workout.Entries)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1;`, workout.ID)
+	workout.NewRecords, err = refreshWorkoutRecords(tx, workout.UserID, int64(workout.ID), before)
 	if err != nil {
 		return err
 	}
-	for _, entry := range workout.Entries {
-		query :=
-			`INSERT INTO workout_entries (workout_id, exercise_name, reps, sets, weight, duration_seconds, notes, order_index)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
-		`
-		_, err := tx.Exec(query, workout.ID, entry.ExerciseName, entry.Reps, entry.Sets, entry.Weight, entry.DurationSeconds, entry.Notes, entry.OrderIndex)
+	if workout.CaloriesEstimated {
+		workout.CaloriesBurned, err = estimateWorkoutCalories(tx, int64(workout.ID))
 		if err != nil {
 			return err
 		}
@@ -155,20 +226,234 @@ func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
 	return tx.Commit()
 }
 
+// DeleteWorkout removes a workout and recomputes the records it was part of.
 func (pg *PostgresWorkoutStore) DeleteWorkout(id int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, err := lockWorkout(tx, id)
+	if err != nil {
+		return err
+	}
+	keys, err := workoutExerciseKeys(tx, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM workouts WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	_, err = refreshRecords(tx, userID, int(id), keys)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetWorkoutOwner returns the ID of the user who created the workout.
+func (pg *PostgresWorkoutStore) GetWorkoutOwner(workoutID int64) (int, error) {
+	var userID int
 	query := `
-	DELETE FROM workouts WHERE id = $1;
+	SELECT user_id FROM workouts WHERE id = $1;
 	`
-	result, err := pg.db.Exec(query, id)
+	err := pg.db.QueryRow(query, workoutID).Scan(&userID)
 	if err != nil {
-		return err
+		return 0, mapError(err)
+	}
+	return userID, nil
+}
+
+// workoutCursor marks the last workout of a page so the next page can continue
+// right after it.
+type workoutCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeWorkoutCursor(sort string, workout *Workout) string {
+	cursor := workoutCursor{Sort: sort, ID: int64(workout.ID)}
+	switch strings.TrimPrefix(sort, "-") {
+	case "created_at":
+		cursor.Value = workout.CreatedAt.Format(time.RFC3339Nano)
+	case "duration_minutes":
+		cursor.Value = strconv.Itoa(workout.DurationMinutes)
+	case "title":
+		cursor.Value = workout.Title
+	}
+	js, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// decodeWorkoutCursor returns the sort value and ID stored in the cursor.
+func decodeWorkoutCursor(sort, encoded string) (interface{}, int64, error) {
+	js, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var cursor workoutCursor
+	err = json.Unmarshal(js, &cursor)
+	if err != nil || cursor.Sort != sort {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	switch strings.TrimPrefix(sort, "-") {
+	case "created_at":
+		value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		return value, cursor.ID, nil
+	case "duration_minutes":
+		value, err := strconv.Atoi(cursor.Value)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		return value, cursor.ID, nil
+	default:
+		return cursor.Value, cursor.ID, nil
+	}
+}
+
+// likeEscaper escapes the wildcards of a search term so LIKE patterns built
+// from it with ESCAPE '\' match it literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListWorkouts returns one page of workouts matching the filter together with
+// the cursor of the next page, which is empty on the last page.
+func (pg *PostgresWorkoutStore) ListWorkouts(filter WorkoutFilter) ([]*Workout, string, error) {
+	if filter.Sort == "" {
+		filter.Sort = "-created_at"
+	}
+	column := strings.TrimPrefix(filter.Sort, "-")
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(filter.Sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	addCondition("w.user_id = $%d", filter.UserID)
+	if filter.CreatedFrom != nil {
+		addCondition("w.created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		addCondition("w.created_at < $%d", *filter.CreatedTo)
+	}
+	if filter.MinDuration != nil {
+		addCondition("w.duration_minutes >= $%d", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		addCondition("w.duration_minutes <= $%d", *filter.MaxDuration)
+	}
+	if filter.Title != "" {
+		addCondition(`w.title ILIKE '%%' || $%d || '%%' ESCAPE '\'`, likeEscaper.Replace(filter.Title))
+	}
+	if filter.Exercise != "" {
+		addCondition(`EXISTS (
+			SELECT 1 FROM workout_entries e
+			WHERE e.workout_id = w.id AND e.exercise_name ILIKE '%%' || $%d || '%%' ESCAPE '\'
+		)`, likeEscaper.Replace(filter.Exercise))
+	}
+	if filter.Cursor != "" {
+		value, id, err := decodeWorkoutCursor(filter.Sort, filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, value, id)
+		conditions = append(conditions, fmt.Sprintf("(w.%s, w.id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+	}
+
+	// one extra row tells us whether there is a next page
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+	SELECT w.id, w.user_id, w.title, w.description, w.duration_minutes, w.calories_burned, w.calories_estimated, w.session_rpe, w.template_id, w.planned_workout_id, w.as_prescribed, w.version, w.created_at, w.updated_at
+	FROM workouts w
+	WHERE %s
+	ORDER BY w.%s %s, w.id %s
+	LIMIT $%d;
+	`, strings.Join(conditions, " AND "), column, direction, direction, len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	for rows.Next() {
+		workout := &Workout{Entries: []WorkoutEntry{}}
+		err := rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.CaloriesEstimated, &workout.SessionRPE, &workout.TemplateID, &workout.PlannedWorkoutID, &workout.AsPrescribed, &workout.Version, &workout.CreatedAt, &workout.UpdatedAt)
+		if err != nil {
+			return nil, "", err
+		}
+		workouts = append(workouts, workout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(workouts) > filter.Limit {
+		workouts = workouts[:filter.Limit]
+		nextCursor = encodeWorkoutCursor(filter.Sort, workouts[len(workouts)-1])
+	}
+
+	err = pg.loadEntries(workouts)
+	if err != nil {
+		return nil, "", err
+	}
+	err = loadBlocks(pg.db, workouts)
+	if err != nil {
+		return nil, "", err
+	}
+	err = loadSets(pg.db, workouts)
+	if err != nil {
+		return nil, "", err
+	}
+	return workouts, nextCursor, nil
+}
+
+// loadEntries fetches the entries of all given workouts in a single query.
+func (pg *PostgresWorkoutStore) loadEntries(workouts []*Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(workouts))
+	byID := make(map[int64]*Workout, len(workouts))
+	for i, workout := range workouts {
+		ids[i] = int64(workout.ID)
+		byID[int64(workout.ID)] = workout
+	}
+
+	query := `
+	SELECT e.workout_id, e.id, e.exercise_id, e.exercise_name, e.reps, e.sets, e.weight, e.duration_seconds, e.notes, e.order_index, b.position, e.created_at, e.updated_at
+	FROM workout_entries e
+	LEFT JOIN workout_blocks b ON b.id = e.block_id
+	WHERE e.workout_id = ANY($1) ORDER BY e.workout_id, e.order_index;
+	`
+	rows, err := pg.db.Query(query, ids)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("workout with ID %d not found", id)
+	defer rows.Close()
+	for rows.Next() {
+		var workoutID int64
+		var entry WorkoutEntry
+		err := rows.Scan(&workoutID, &entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.Reps, &entry.Sets, &entry.Weight, &entry.DurationSeconds, &entry.Notes, &entry.OrderIndex, &entry.Block, &entry.CreatedAt, &entry.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		workout := byID[workoutID]
+		workout.Entries = append(workout.Entries, entry)
 	}
-	return nil
+	return rows.Err()
 }
@@ -22,14 +22,30 @@ func setupTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatalf("Failed to truncate tables: %v", err)
 	}
+	// exercises holds the seeded catalog, deleting users only removes their own exercises
+	_, err = db.Exec("DELETE FROM users")
+	if err != nil {
+		t.Fatalf("Failed to delete users: %v", err)
+	}
 	return db
 }
 
+func createTestUser(t *testing.T, db *sql.DB) *User {
+	user := &User{Username: "tester", Email: "tester@example.com"}
+	err := user.PasswordHash.Set("password")
+	require.NoError(t, err)
+	query := `INSERT INTO users (username, email, password_hash, bio) VALUES ($1, $2, $3, $4) RETURNING id;`
+	err = db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.BIO).Scan(&user.ID)
+	require.NoError(t, err)
+	return user
+}
+
 func TestCreateWorkout(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
 	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
 
 	tests := []struct {
 		name   string
@@ -96,6 +112,7 @@ func TestCreateWorkout(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			tt.workout.UserID = user.ID
 			createdWorkout, err := store.CreateWorkout(tt.workout)
 			if tt.wantErr {
 				assert.Error(t, err, "Expected error but got none")
@@ -109,6 +126,7 @@ func TestCreateWorkout(t *testing.T) {
 			retrieved, err := store.GetWorkoutByID(int64(createdWorkout.ID))
 			require.NoError(t, err)
 			assert.Equal(t, createdWorkout.ID, retrieved.ID)
+			assert.Equal(t, user.ID, retrieved.UserID)
 			assert.Equal(t, len(tt.workout.Entries), len(retrieved.Entries))
 
 			for i := range retrieved.Entries {
@@ -124,6 +142,221 @@ func TestCreateWorkout(t *testing.T) {
 	}
 }
 
+func TestListWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
+
+	for i := 1; i <= 5; i++ {
+		_, err := store.CreateWorkout(&Workout{
+			UserID:          user.ID,
+			Title:           "Workout",
+			DurationMinutes: i * 10,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Reps: IntPtr(5), Sets: 5, OrderIndex: 1},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	filter := WorkoutFilter{UserID: user.ID, Sort: "duration_minutes", Limit: 2}
+	var durations []int
+	for {
+		workouts, nextCursor, err := store.ListWorkouts(filter)
+		require.NoError(t, err)
+		for _, workout := range workouts {
+			durations = append(durations, workout.DurationMinutes)
+			assert.Len(t, workout.Entries, 1)
+		}
+		if nextCursor == "" {
+			break
+		}
+		filter.Cursor = nextCursor
+	}
+	assert.Equal(t, []int{10, 20, 30, 40, 50}, durations)
+
+	minDuration := 25
+	workouts, nextCursor, err := store.ListWorkouts(WorkoutFilter{UserID: user.ID, MinDuration: &minDuration, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, workouts, 3)
+	assert.Empty(t, nextCursor)
+
+	_, _, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Cursor: "not-a-cursor", Limit: 10})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// wildcards in the search are matched literally
+	_, err = store.CreateWorkout(&Workout{UserID: user.ID, Title: "100% effort", DurationMinutes: 30})
+	require.NoError(t, err)
+	for _, title := range []string{"%", "100%"} {
+		workouts, _, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Title: title, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, workouts, 1)
+		assert.Equal(t, "100% effort", workouts[0].Title)
+	}
+	workouts, _, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Title: "_", Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, workouts)
+}
+
+func TestLikeEscaper(t *testing.T) {
+	assert.Equal(t, `100\% \_ a\\b`, likeEscaper.Replace(`100% _ a\b`))
+}
+
+func TestWorkoutEntries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          user.ID,
+		Title:           "Full Body",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Reps: IntPtr(5), Sets: 5, OrderIndex: 1},
+			{ExerciseName: "Bench Press", Reps: IntPtr(5), Sets: 5, OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+	workoutID := int64(workout.ID)
+	squatID := workout.Entries[0].ID
+
+	names := func() []string {
+		current, err := store.GetWorkoutByID(workoutID)
+		require.NoError(t, err)
+		var names []string
+		for i, entry := range current.Entries {
+			assert.Equal(t, i+1, entry.OrderIndex)
+			names = append(names, entry.ExerciseName)
+		}
+		return names
+	}
+
+	row := &WorkoutEntry{ExerciseName: "Row", Reps: IntPtr(8), Sets: 3, OrderIndex: 1}
+	version, err := store.AddWorkoutEntry(workoutID, workout.Version, row)
+	require.NoError(t, err)
+	assert.Equal(t, workout.Version+1, version)
+	assert.Equal(t, []string{"Row", "Squat", "Bench Press"}, names())
+
+	// a change made against an older version is rejected
+	_, err = store.DeleteWorkoutEntry(workoutID, workout.Version, int64(squatID))
+	assert.ErrorIs(t, err, ErrEditConflict)
+
+	version, err = store.DeleteWorkoutEntry(workoutID, version, int64(squatID))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Row", "Bench Press"}, names())
+	_, err = store.DeleteWorkoutEntry(workoutID, version, int64(squatID))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	current, err := store.GetWorkoutByID(workoutID)
+	require.NoError(t, err)
+	assert.Equal(t, version, current.Version)
+	benchID := int64(current.Entries[1].ID)
+	version, err = store.ReorderWorkoutEntries(workoutID, version, []int64{benchID, int64(row.ID)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bench Press", "Row"}, names())
+	_, err = store.ReorderWorkoutEntries(workoutID, version, []int64{benchID})
+	assert.ErrorIs(t, err, ErrInvalidEntryOrder)
+
+	// a full update keeps the IDs of the entries it sends back
+	current, err = store.GetWorkoutByID(workoutID)
+	require.NoError(t, err)
+	current.Entries[0].Sets = 3
+	current.Entries = current.Entries[:1]
+	require.NoError(t, store.UpdateWorkout(current))
+	updated, err := store.GetWorkoutByID(workoutID)
+	require.NoError(t, err)
+	require.Len(t, updated.Entries, 1)
+	assert.Equal(t, int(benchID), updated.Entries[0].ID)
+	assert.Equal(t, 3, updated.Entries[0].Sets)
+	assert.Greater(t, updated.Version, workout.Version)
+}
+
+func TestWorkoutBlocks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          user.ID,
+		Title:           "Upper",
+		DurationMinutes: 30,
+		Blocks: []WorkoutBlock{
+			{Position: 1, Type: BlockSuperset, Rounds: 3, RestSeconds: IntPtr(90)},
+		},
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Pull Up", Reps: IntPtr(8), Sets: 1, OrderIndex: 1, Block: IntPtr(1)},
+			{ExerciseName: "Dips", Reps: IntPtr(10), Sets: 1, OrderIndex: 2, Block: IntPtr(1)},
+			{ExerciseName: "Curl", Reps: IntPtr(12), Sets: 2, OrderIndex: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	fetched, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, fetched.Blocks, 1)
+	assert.Equal(t, BlockSuperset, fetched.Blocks[0].Type)
+	assert.Equal(t, IntPtr(1), fetched.Entries[0].Block)
+	assert.Equal(t, IntPtr(1), fetched.Entries[1].Block)
+	assert.Nil(t, fetched.Entries[2].Block)
+
+	// entries can only join blocks of their own workout
+	_, err = store.AddWorkoutEntry(int64(workout.ID), fetched.Version, &WorkoutEntry{ExerciseName: "Row", Reps: IntPtr(8), Sets: 1, Block: IntPtr(2)})
+	assert.ErrorIs(t, err, ErrInvalidData)
+
+	// replacing the blocks keeps the entries in the block at the same position
+	fetched.Blocks = []WorkoutBlock{{Position: 1, Type: BlockCircuit, Rounds: 4}}
+	require.NoError(t, store.UpdateWorkout(fetched))
+	updated, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	assert.Equal(t, []WorkoutBlock{{Position: 1, Type: BlockCircuit, Rounds: 4}}, updated.Blocks)
+	assert.Equal(t, IntPtr(1), updated.Entries[1].Block)
+}
+
+func TestWorkoutSets(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db)
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          user.ID,
+		Title:           "Bench",
+		DurationMinutes: 40,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", OrderIndex: 1, SetList: []WorkoutSet{
+				{Type: SetWarmup, Reps: IntPtr(10), Weight: FloatPtr(40)},
+				{Reps: IntPtr(5), Weight: FloatPtr(80), RPE: FloatPtr(7.5)},
+				{Reps: IntPtr(4), Weight: FloatPtr(85), RIR: IntPtr(1)},
+			}},
+			{ExerciseName: "Push Up", Reps: IntPtr(20), Sets: 2, OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	fetched, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	assert.Equal(t, workout.Entries[0].SetList, fetched.Entries[0].SetList)
+	assert.Equal(t, 2, fetched.Entries[0].Sets)
+	assert.Equal(t, IntPtr(4), fetched.Entries[0].Reps)
+	assert.Equal(t, FloatPtr(85), fetched.Entries[0].Weight)
+	// entries logged without sets get a working set per set
+	require.Len(t, fetched.Entries[1].SetList, 2)
+	assert.Equal(t, IntPtr(20), fetched.Entries[1].SetList[1].Reps)
+
+	fetched.Entries[1].SetList[1].Completed = BoolPtr(false)
+	require.NoError(t, store.UpdateWorkout(fetched))
+	updated, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated.Entries[1].Sets)
+	assert.Equal(t, BoolPtr(false), updated.Entries[1].SetList[1].Completed)
+}
 
 func IntPtr(i int) *int {
 	return &i
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+)
+
+// ValidateWorkout checks a workout and all of its entries before it reaches
+// the database, recording every violation in v.
+func ValidateWorkout(v *validator.Validator, workout *Workout) {
+	v.Check(validator.NotBlank(workout.Title), "title", "must be provided")
+	v.Check(validator.MaxChars(workout.Title, 255), "title", "must not be more than 255 characters long")
+	v.Check(workout.DurationMinutes > 0, "duration_minutes", "must be greater than zero")
+	v.Check(workout.CaloriesBurned >= 0, "calories_burned", "must not be negative")
+	if workout.SessionRPE != nil {
+		v.Check(*workout.SessionRPE >= 1 && *workout.SessionRPE <= 10, "session_rpe", "must be between 1 and 10")
+	}
+
+	for i := range workout.Entries {
+		ValidateWorkoutEntry(v, fmt.Sprintf("entries[%d]", i), &workout.Entries[i])
+	}
+	indexes := make([]int, len(workout.Entries))
+	for i, entry := range workout.Entries {
+		indexes[i] = entry.OrderIndex
+	}
+	validatePositions(v, "entries", "order_index", indexes)
+	validateBlocks(v, workout)
+}
+
+// validateBlocks checks the blocks of a workout and that entries only join
+// blocks the workout has.
+func validateBlocks(v *validator.Validator, workout *Workout) {
+	positions := make([]int, len(workout.Blocks))
+	known := make(map[int]bool, len(workout.Blocks))
+	for i, block := range workout.Blocks {
+		key := fmt.Sprintf("blocks[%d]", i)
+		positions[i] = block.Position
+		known[block.Position] = true
+
+		v.Check(slices.Contains(BlockTypes, block.Type), key+".type", "must be one of "+strings.Join(BlockTypes, ", "))
+		v.Check(block.Rounds >= 1, key+".rounds", "must be at least 1")
+		if block.WorkSeconds != nil {
+			v.Check(*block.WorkSeconds > 0, key+".work_seconds", "must be greater than zero")
+		}
+		if block.RestSeconds != nil {
+			v.Check(*block.RestSeconds >= 0, key+".rest_seconds", "must not be negative")
+		}
+		switch block.Type {
+		case BlockEMOM, BlockAMRAP:
+			v.Check(block.WorkSeconds != nil, key+".work_seconds", "must be provided for "+block.Type+" blocks")
+		case BlockTabata:
+			v.Check(block.WorkSeconds != nil, key+".work_seconds", "must be provided for tabata blocks")
+			v.Check(block.RestSeconds != nil, key+".rest_seconds", "must be provided for tabata blocks")
+		}
+	}
+	validatePositions(v, "blocks", "position", positions)
+
+	for i, entry := range workout.Entries {
+		if entry.Block != nil {
+			v.Check(known[*entry.Block], fmt.Sprintf("entries[%d].block", i), "must be the position of a block of this workout")
+		}
+	}
+}
+
+// ValidateWorkoutEntry checks a single entry, prefix is the JSON path of the
+// entry used for the error keys and is empty when the entry is the whole
+// request body.
+func ValidateWorkoutEntry(v *validator.Validator, prefix string, entry *WorkoutEntry) {
+	key := func(field string) string {
+		if prefix == "" {
+			return field
+		}
+		return prefix + "." + field
+	}
+	entryKey := prefix
+	if entryKey == "" {
+		entryKey = "entry"
+	}
+
+	v.Check(validator.NotBlank(entry.ExerciseName), key("exercise_name"), "must be provided")
+	v.Check(validator.MaxChars(entry.ExerciseName, 255), key("exercise_name"), "must not be more than 255 characters long")
+	// the summary fields of an entry with a set list are derived from its sets
+	if len(entry.SetList) > 0 {
+		validateSets(v, key("set_list"), entry.SetList)
+		return
+	}
+	v.Check(entry.Sets >= 1, key("sets"), "must be at least 1")
+	v.Check(validator.ExactlyOne(entry.Reps != nil, entry.DurationSeconds != nil), entryKey, "must have exactly one of reps or duration_seconds")
+	if entry.Reps != nil {
+		v.Check(*entry.Reps > 0, key("reps"), "must be greater than zero")
+	}
+	if entry.DurationSeconds != nil {
+		v.Check(*entry.DurationSeconds > 0, key("duration_seconds"), "must be greater than zero")
+	}
+	if entry.Weight != nil {
+		v.Check(*entry.Weight >= 0, key("weight"), "must not be negative")
+	}
+}
+
+// validateSets checks the set list of an entry. All sets of an entry are
+// either counted in reps or timed.
+func validateSets(v *validator.Validator, key string, sets []WorkoutSet) {
+	timed := sets[0].DurationSeconds != nil
+	for i, set := range sets {
+		setKey := fmt.Sprintf("%s[%d]", key, i)
+		v.Check(set.Type == "" || slices.Contains(SetTypes, set.Type), setKey+".type", "must be one of "+strings.Join(SetTypes, ", "))
+		v.Check(validator.ExactlyOne(set.Reps != nil, set.DurationSeconds != nil), setKey, "must have exactly one of reps or duration_seconds")
+		if set.Reps != nil {
+			v.Check(*set.Reps > 0, setKey+".reps", "must be greater than zero")
+		}
+		if set.DurationSeconds != nil {
+			v.Check(*set.DurationSeconds > 0, setKey+".duration_seconds", "must be greater than zero")
+			v.Check(timed, setKey, "must be counted in reps like the first set")
+		} else if set.Reps != nil {
+			v.Check(!timed, setKey, "must be timed like the first set")
+		}
+		if set.Weight != nil {
+			v.Check(*set.Weight >= 0, setKey+".weight", "must not be negative")
+		}
+		if set.RPE != nil {
+			v.Check(*set.RPE >= 1 && *set.RPE <= 10, setKey+".rpe", "must be between 1 and 10")
+		}
+		if set.RIR != nil {
+			v.Check(*set.RIR >= 0, setKey+".rir", "must not be negative")
+		}
+	}
+}
+
+// validatePositions requires the field values of the elements of the list at
+// key, given in list order, to be unique and to run from 1 to the number of
+// elements without gaps.
+func validatePositions(v *validator.Validator, key, field string, positions []int) {
+	seen := make(map[int]bool, len(positions))
+	sorted := make([]int, 0, len(positions))
+	for i, position := range positions {
+		if seen[position] {
+			v.AddError(fmt.Sprintf("%s[%d].%s", key, i, field), "must be unique")
+			continue
+		}
+		seen[position] = true
+		sorted = append(sorted, position)
+	}
+	if len(sorted) != len(positions) {
+		return
+	}
+
+	sort.Ints(sorted)
+	for i, position := range sorted {
+		if position != i+1 {
+			v.AddError(key, field+" values must be contiguous starting at 1")
+			return
+		}
+	}
+}
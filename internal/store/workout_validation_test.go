@@ -0,0 +1,137 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/makhammatovb/femProject/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWorkout(t *testing.T) {
+	tests := []struct {
+		name    string
+		workout *Workout
+		errors  map[string]string
+	}{
+		{
+			name: "Valid Workout",
+			workout: &Workout{
+				Title:           "Leg Day",
+				DurationMinutes: 60,
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 2},
+					{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 1},
+				},
+			},
+			errors: map[string]string{},
+		},
+		{
+			name: "Every Violation Reported",
+			workout: &Workout{
+				DurationMinutes: 0,
+				CaloriesBurned:  -10,
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Squat", Sets: 0, Reps: IntPtr(5), DurationSeconds: IntPtr(30), Weight: FloatPtr(-5), OrderIndex: 1},
+					{ExerciseName: "", Sets: 3, OrderIndex: 1},
+				},
+			},
+			errors: map[string]string{
+				"title":                    "must be provided",
+				"duration_minutes":         "must be greater than zero",
+				"calories_burned":          "must not be negative",
+				"entries[0].sets":          "must be at least 1",
+				"entries[0]":               "must have exactly one of reps or duration_seconds",
+				"entries[0].weight":        "must not be negative",
+				"entries[1].exercise_name": "must be provided",
+				"entries[1]":               "must have exactly one of reps or duration_seconds",
+				"entries[1].order_index":   "must be unique",
+			},
+		},
+		{
+			name: "Gap In Order Index",
+			workout: &Workout{
+				Title:           "Push",
+				DurationMinutes: 30,
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), OrderIndex: 1},
+					{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10), OrderIndex: 3},
+				},
+			},
+			errors: map[string]string{
+				"entries": "order_index values must be contiguous starting at 1",
+			},
+		},
+		{
+			name: "Invalid Blocks",
+			workout: &Workout{
+				Title:           "Conditioning",
+				DurationMinutes: 20,
+				Blocks: []WorkoutBlock{
+					{Position: 1, Type: BlockEMOM, Rounds: 10},
+					{Position: 3, Type: BlockTabata, Rounds: 0, WorkSeconds: IntPtr(20)},
+				},
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Burpee", Sets: 1, Reps: IntPtr(10), OrderIndex: 1, Block: IntPtr(1)},
+					{ExerciseName: "Row", Sets: 1, DurationSeconds: IntPtr(20), OrderIndex: 2, Block: IntPtr(2)},
+				},
+			},
+			errors: map[string]string{
+				"blocks[0].work_seconds": "must be provided for emom blocks",
+				"blocks[1].rounds":       "must be at least 1",
+				"blocks[1].rest_seconds": "must be provided for tabata blocks",
+				"blocks":                 "position values must be contiguous starting at 1",
+				"entries[1].block":       "must be the position of a block of this workout",
+			},
+		},
+		{
+			name: "Invalid Session RPE",
+			workout: &Workout{
+				Title:           "Run",
+				DurationMinutes: 30,
+				SessionRPE:      FloatPtr(11),
+			},
+			errors: map[string]string{
+				"session_rpe": "must be between 1 and 10",
+			},
+		},
+		{
+			name: "Invalid Set List",
+			workout: &Workout{
+				Title:           "Legs",
+				DurationMinutes: 45,
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Squat", Sets: 0, OrderIndex: 1, SetList: []WorkoutSet{
+						{Type: SetWarmup, Reps: IntPtr(10), Weight: FloatPtr(60)},
+						{Type: "top", Reps: IntPtr(5), Weight: FloatPtr(-100), RPE: FloatPtr(11)},
+						{DurationSeconds: IntPtr(30)},
+						{RIR: IntPtr(-1)},
+					}},
+				},
+			},
+			errors: map[string]string{
+				"entries[0].set_list[1].type":   "must be one of warmup, working, drop, failure",
+				"entries[0].set_list[1].weight": "must not be negative",
+				"entries[0].set_list[1].rpe":    "must be between 1 and 10",
+				"entries[0].set_list[2]":        "must be counted in reps like the first set",
+				"entries[0].set_list[3]":        "must have exactly one of reps or duration_seconds",
+				"entries[0].set_list[3].rir":    "must not be negative",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateWorkout(v, tt.workout)
+			assert.Equal(t, tt.errors, v.Errors)
+		})
+	}
+}
+
+func TestValidateWorkoutEntryWithoutPrefix(t *testing.T) {
+	v := validator.New()
+	ValidateWorkoutEntry(v, "", &WorkoutEntry{ExerciseName: "Squat", Sets: 0})
+	assert.Equal(t, map[string]string{
+		"sets":  "must be at least 1",
+		"entry": "must have exactly one of reps or duration_seconds",
+	}, v.Errors)
+}
@@ -13,10 +13,24 @@ type Token struct {
 	UserID    int64  `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string `json:"-"`
+	UserAgent string `json:"-"`
+	Family    string `json:"-"`
 }
 
 const (
 	ScopeAuthentication = "authentication"
+	ScopeRefresh        = "refresh"
+	ScopePasswordReset  = "password-reset"
+	ScopeActivation     = "activation"
+	ScopeEmailChange    = "email-change"
+)
+
+const (
+	AuthenticationTTL = 24 * time.Hour
+	RefreshTTL        = 30 * 24 * time.Hour
+	PasswordResetTTL  = 45 * time.Minute
+	ActivationTTL     = 3 * 24 * time.Hour
+	EmailChangeTTL    = 24 * time.Hour
 )
 
 func GenerateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -26,15 +40,34 @@ func GenerateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 		Scope: scope,
 	}
 
-	emptryBytes := make([]byte, 32)
-	_, err := rand.Read(emptryBytes)
+	plainText, err := randomString()
 	if err != nil {
 		return nil, err
 	}
 
-	token.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptryBytes)
-	hash := sha256.Sum256([]byte(token.PlainText))
-	token.Hash = hash[:]
+	token.PlainText = plainText
+	token.Hash = Hash(token.PlainText)
 
 	return token, nil
 }
+
+// GenerateFamily returns a new identifier shared by an authentication token
+// and the refresh tokens that descend from the same login.
+func GenerateFamily() (string, error) {
+	return randomString()
+}
+
+// Hash returns the hash under which a plain text token is stored.
+func Hash(plainText string) []byte {
+	hash := sha256.Sum256([]byte(plainText))
+	return hash[:]
+}
+
+func randomString() (string, error) {
+	emptryBytes := make([]byte, 32)
+	_, err := rand.Read(emptryBytes)
+	if err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptryBytes), nil
+}
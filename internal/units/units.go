@@ -0,0 +1,73 @@
+// Package units converts weights between the unit system of a user and kg,
+// the unit they are stored in.
+//
+// Distances (km or mi) are not converted yet: nothing stores a distance, so
+// they are left until a distance field is added, which should store km and
+// convert it here like weights.
+package units
+
+import "math"
+
+// Unit systems a user can pick.
+const (
+	Metric   = "metric"
+	Imperial = "imperial"
+)
+
+// Systems holds the unit systems a user can pick.
+var Systems = []string{Metric, Imperial}
+
+const (
+	Kilogram = "kg"
+	Pound    = "lb"
+)
+
+// kilogramsPerPound is the exact definition of the international pound.
+const kilogramsPerPound = 0.45359237
+
+// Units names the units weights are given in.
+type Units struct {
+	System string `json:"system"`
+	Weight string `json:"weight"`
+}
+
+// Valid reports whether system is one of Systems.
+func Valid(system string) bool {
+	return system == Metric || system == Imperial
+}
+
+// Of returns the units of a system, the metric ones for unknown systems.
+func Of(system string) Units {
+	if system == Imperial {
+		return Units{System: Imperial, Weight: Pound}
+	}
+	return Units{System: Metric, Weight: Kilogram}
+}
+
+// IsCanonical reports whether the units are the ones values are stored in, so
+// nothing needs converting.
+func (u Units) IsCanonical() bool {
+	return u.Weight == Kilogram
+}
+
+// FromKilograms converts a stored weight to the units. Pounds are rounded to
+// the tenth, which undoes the rounding of the kg they were stored as.
+func (u Units) FromKilograms(kg float64) float64 {
+	if u.Weight == Pound {
+		return round(kg/kilogramsPerPound, 10)
+	}
+	return kg
+}
+
+// ToKilograms converts a weight in the units to kg, rounded to the hundredths
+// weights are stored with.
+func (u Units) ToKilograms(weight float64) float64 {
+	if u.Weight == Pound {
+		return round(weight*kilogramsPerPound, 100)
+	}
+	return weight
+}
+
+func round(value, precision float64) float64 {
+	return math.Round(value*precision) / precision
+}
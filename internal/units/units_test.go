@@ -0,0 +1,31 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOf(t *testing.T) {
+	assert.Equal(t, Units{System: Imperial, Weight: Pound}, Of(Imperial))
+	assert.Equal(t, Units{System: Metric, Weight: Kilogram}, Of(Metric))
+	assert.Equal(t, Of(Metric), Of(""))
+	assert.True(t, Of(Metric).IsCanonical())
+	assert.False(t, Of(Imperial).IsCanonical())
+	assert.False(t, Valid("stones"))
+}
+
+func TestConversions(t *testing.T) {
+	imperial := Of(Imperial)
+	assert.Equal(t, 102.06, imperial.ToKilograms(225))
+	assert.Equal(t, 220.5, imperial.FromKilograms(100))
+
+	// pounds survive being stored as kg to the hundredth
+	for _, lb := range []float64{0.5, 1, 2.5, 45, 135.5, 225, 315, 405.5} {
+		assert.Equal(t, lb, imperial.FromKilograms(imperial.ToKilograms(lb)), "%v lb", lb)
+	}
+
+	metric := Of(Metric)
+	assert.Equal(t, 102.5, metric.ToKilograms(102.5))
+	assert.Equal(t, 102.5, metric.FromKilograms(102.5))
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag formats the version of a record as a strong entity tag.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ETagIn formats the version of a record shown in a unit, as the same version
+// reads differently in each.
+func ETagIn(version int, unit string) string {
+	return `"` + strconv.Itoa(version) + "-" + unit + `"`
+}
+
+// IfMatch reports whether the If-Match header of the request lists etag, using
+// the strong comparison RFC 9110 requires for it.
+func IfMatch(r *http.Request, etag string) bool {
+	return etagListContains(r.Header.Get("If-Match"), etag, false)
+}
+
+// IfNoneMatch reports whether the If-None-Match header of the request lists
+// etag, using weak comparison.
+func IfNoneMatch(r *http.Request, etag string) bool {
+	return etagListContains(r.Header.Get("If-None-Match"), etag, true)
+}
+
+func etagListContains(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,11 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -23,13 +26,63 @@ func WriteJSON(w http.ResponseWriter, status int, data Envelope) {
 }
 
 func ReadIDParam(r *http.Request) (int64, error) {
-	idParam := chi.URLParam(r, "id")
-	if idParam == "" {
+	return ReadInt64Param(r, "id")
+}
+
+// ReadInt64Param returns the URL parameter name parsed as an integer.
+func ReadInt64Param(r *http.Request, name string) (int64, error) {
+	param := chi.URLParam(r, name)
+	if param == "" {
 		return 0, http.ErrNoLocation
 	}
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	id, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
 		return 0, http.ErrNoLocation
 	}
 	return id, nil
 }
+
+// ReadIntQuery returns the integer value of the query string parameter key,
+// or nil when the parameter is absent.
+func ReadIntQuery(r *http.Request, key string) (*int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.New("must be an integer")
+	}
+	return &i, nil
+}
+
+// ReadFloatQuery returns the numeric value of the query string parameter key,
+// or nil when the parameter is absent.
+func ReadFloatQuery(r *http.Request, key string) (*float64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.New("must be a number")
+	}
+	return &f, nil
+}
+
+// ReadTimeQuery returns the time value of the query string parameter key, or
+// nil when the parameter is absent. Both RFC 3339 timestamps and plain
+// YYYY-MM-DD dates are accepted.
+func ReadTimeQuery(r *http.Request, key string) (*time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+}
@@ -0,0 +1,54 @@
+package validator
+
+import "unicode/utf8"
+
+// Validator collects every problem found while checking a value, keyed by the
+// JSON path of the offending field such as "entries[2].sets".
+type Validator struct {
+	Errors map[string]string
+}
+
+// New creates a new instance of Validator with no errors.
+func New() *Validator {
+	return &Validator{Errors: make(map[string]string)}
+}
+
+// Valid reports whether no check has failed.
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// AddError records a problem for key, keeping the first one reported.
+func (v *Validator) AddError(key, message string) {
+	if _, exists := v.Errors[key]; !exists {
+		v.Errors[key] = message
+	}
+}
+
+// Check records the message for key when ok is false.
+func (v *Validator) Check(ok bool, key, message string) {
+	if !ok {
+		v.AddError(key, message)
+	}
+}
+
+// NotBlank reports whether s has at least one character.
+func NotBlank(s string) bool {
+	return s != ""
+}
+
+// MaxChars reports whether s is at most n characters long.
+func MaxChars(s string, n int) bool {
+	return utf8.RuneCountInString(s) <= n
+}
+
+// ExactlyOne reports whether exactly one of the given values is set.
+func ExactlyOne(set ...bool) bool {
+	count := 0
+	for _, ok := range set {
+		if ok {
+			count++
+		}
+	}
+	return count == 1
+}
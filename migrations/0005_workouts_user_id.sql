@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id) ON DELETE CASCADE;
+
+-- workouts created before ownership was tracked go to a dedicated legacy
+-- account, never to a real user who could then read and change them. The
+-- random suffix keeps it clear of any registered username or email, and the
+-- password hash matches no password so nobody can log in as it
+WITH legacy AS (
+    INSERT INTO users (username, email, password_hash, bio)
+    SELECT 'legacy-' || suffix, 'legacy-' || suffix || '@legacy.invalid', '!', 'Owner of workouts created before ownership was tracked'
+    FROM (SELECT substr(md5(random()::text || clock_timestamp()::text), 1, 12) AS suffix) s
+    WHERE EXISTS (SELECT 1 FROM workouts WHERE user_id IS NULL)
+    RETURNING id
+)
+UPDATE workouts SET user_id = (SELECT id FROM legacy) WHERE user_id IS NULL;
+
+ALTER TABLE workouts ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id ON workouts(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_id;
+ALTER TABLE workouts DROP COLUMN IF EXISTS user_id;
+-- +goose StatementEnd
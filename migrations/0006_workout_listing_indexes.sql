@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id_created_at ON workouts(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_workout_id ON workout_entries(workout_id, order_index);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workout_entries_workout_id;
+DROP INDEX IF EXISTS idx_workouts_user_id_created_at;
+-- +goose StatementEnd
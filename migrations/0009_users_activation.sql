@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS activated BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pending_email VARCHAR(255);
+
+-- accounts registered before activation existed keep working, the locked
+-- legacy owner of old workouts stays inactive
+UPDATE users SET activated = TRUE WHERE password_hash <> '!';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS pending_email,
+    DROP COLUMN IF EXISTS activated;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+ALTER TABLE workouts DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
@@ -0,0 +1,97 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- built-in exercises have no user_id, exercises with one are a user's own
+CREATE TABLE IF NOT EXISTS exercises (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    primary_muscles TEXT[] NOT NULL DEFAULT '{}',
+    secondary_muscles TEXT[] NOT NULL DEFAULT '{}',
+    equipment VARCHAR(50) NOT NULL,
+    movement_pattern VARCHAR(50) NOT NULL,
+    type VARCHAR(10) NOT NULL CHECK (type IN ('reps', 'timed')),
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_name_key ON exercises (COALESCE(user_id, 0), LOWER(name));
+
+INSERT INTO exercises (name, aliases, primary_muscles, secondary_muscles, equipment, movement_pattern, type) VALUES
+    ('Back Squat', '{"Squat","Barbell Squat","BS"}', '{"quadriceps","glutes"}', '{"hamstrings","core"}', 'barbell', 'squat', 'reps'),
+    ('Front Squat', '{"FS"}', '{"quadriceps"}', '{"glutes","core"}', 'barbell', 'squat', 'reps'),
+    ('Goblet Squat', '{}', '{"quadriceps","glutes"}', '{"core"}', 'dumbbell', 'squat', 'reps'),
+    ('Bodyweight Squat', '{"Air Squat"}', '{"quadriceps","glutes"}', '{}', 'bodyweight', 'squat', 'reps'),
+    ('Leg Press', '{}', '{"quadriceps","glutes"}', '{"hamstrings"}', 'machine', 'squat', 'reps'),
+    ('Deadlift', '{"Conventional Deadlift","DL"}', '{"hamstrings","glutes","back"}', '{"quadriceps","forearms","traps"}', 'barbell', 'hinge', 'reps'),
+    ('Romanian Deadlift', '{"RDL"}', '{"hamstrings","glutes"}', '{"back"}', 'barbell', 'hinge', 'reps'),
+    ('Hip Thrust', '{"Barbell Hip Thrust"}', '{"glutes"}', '{"hamstrings"}', 'barbell', 'hinge', 'reps'),
+    ('Kettlebell Swing', '{"KB Swing","Swing"}', '{"glutes","hamstrings"}', '{"core","shoulders"}', 'kettlebell', 'hinge', 'reps'),
+    ('Walking Lunge', '{"Lunge","Lunges"}', '{"quadriceps","glutes"}', '{"hamstrings"}', 'dumbbell', 'lunge', 'reps'),
+    ('Bulgarian Split Squat', '{"BSS","Split Squat"}', '{"quadriceps","glutes"}', '{"hamstrings"}', 'dumbbell', 'lunge', 'reps'),
+    ('Step Up', '{"Step Ups"}', '{"quadriceps","glutes"}', '{}', 'dumbbell', 'lunge', 'reps'),
+    ('Bench Press', '{"Barbell Bench Press","Flat Bench","BP"}', '{"chest"}', '{"triceps","shoulders"}', 'barbell', 'horizontal_push', 'reps'),
+    ('Incline Bench Press', '{"Incline Press"}', '{"chest","shoulders"}', '{"triceps"}', 'barbell', 'horizontal_push', 'reps'),
+    ('Dumbbell Bench Press', '{"DB Bench Press"}', '{"chest"}', '{"triceps","shoulders"}', 'dumbbell', 'horizontal_push', 'reps'),
+    ('Push Up', '{"Push Ups","Pushup","Pushups"}', '{"chest"}', '{"triceps","shoulders","core"}', 'bodyweight', 'horizontal_push', 'reps'),
+    ('Dip', '{"Dips"}', '{"triceps","chest"}', '{"shoulders"}', 'bodyweight', 'vertical_push', 'reps'),
+    ('Overhead Press', '{"OHP","Military Press","Shoulder Press"}', '{"shoulders"}', '{"triceps","core"}', 'barbell', 'vertical_push', 'reps'),
+    ('Dumbbell Shoulder Press', '{"DB Shoulder Press"}', '{"shoulders"}', '{"triceps"}', 'dumbbell', 'vertical_push', 'reps'),
+    ('Pull Up', '{"Pull Ups","Pullup","Pullups"}', '{"lats"}', '{"biceps","back"}', 'bodyweight', 'vertical_pull', 'reps'),
+    ('Chin Up', '{"Chin Ups","Chinup"}', '{"lats","biceps"}', '{"back"}', 'bodyweight', 'vertical_pull', 'reps'),
+    ('Lat Pulldown', '{"Pulldown"}', '{"lats"}', '{"biceps"}', 'cable', 'vertical_pull', 'reps'),
+    ('Barbell Row', '{"Bent Over Row","BB Row"}', '{"back","lats"}', '{"biceps","forearms"}', 'barbell', 'horizontal_pull', 'reps'),
+    ('Dumbbell Row', '{"One Arm Row","DB Row"}', '{"back","lats"}', '{"biceps"}', 'dumbbell', 'horizontal_pull', 'reps'),
+    ('Seated Cable Row', '{"Cable Row"}', '{"back","lats"}', '{"biceps"}', 'cable', 'horizontal_pull', 'reps'),
+    ('Face Pull', '{"Face Pulls"}', '{"shoulders"}', '{"traps","back"}', 'cable', 'horizontal_pull', 'reps'),
+    ('Biceps Curl', '{"Curl","Dumbbell Curl","Bicep Curl"}', '{"biceps"}', '{"forearms"}', 'dumbbell', 'isolation', 'reps'),
+    ('Triceps Pushdown', '{"Tricep Pushdown","Pushdown"}', '{"triceps"}', '{}', 'cable', 'isolation', 'reps'),
+    ('Lateral Raise', '{"Side Raise","Lateral Raises"}', '{"shoulders"}', '{}', 'dumbbell', 'isolation', 'reps'),
+    ('Leg Extension', '{}', '{"quadriceps"}', '{}', 'machine', 'isolation', 'reps'),
+    ('Leg Curl', '{"Hamstring Curl"}', '{"hamstrings"}', '{}', 'machine', 'isolation', 'reps'),
+    ('Calf Raise', '{"Calf Raises"}', '{"calves"}', '{}', 'machine', 'isolation', 'reps'),
+    ('Shrug', '{"Shrugs"}', '{"traps"}', '{"forearms"}', 'dumbbell', 'isolation', 'reps'),
+    ('Farmer''s Carry', '{"Farmers Walk","Farmer Carry"}', '{"forearms","traps"}', '{"core"}', 'dumbbell', 'carry', 'timed'),
+    ('Plank', '{"Front Plank"}', '{"core"}', '{"shoulders"}', 'bodyweight', 'core', 'timed'),
+    ('Side Plank', '{}', '{"core"}', '{}', 'bodyweight', 'core', 'timed'),
+    ('Crunch', '{"Crunches","Sit Up","Sit Ups"}', '{"core"}', '{}', 'bodyweight', 'core', 'reps'),
+    ('Hanging Leg Raise', '{"Leg Raise","Leg Raises"}', '{"core"}', '{"forearms"}', 'bodyweight', 'core', 'reps'),
+    ('Burpee', '{"Burpees"}', '{"full_body"}', '{"chest","quadriceps"}', 'bodyweight', 'cardio', 'reps'),
+    ('Jumping Jack', '{"Jumping Jacks"}', '{"full_body"}', '{"calves"}', 'bodyweight', 'cardio', 'timed'),
+    ('Running', '{"Run","Jogging","Treadmill"}', '{"cardio"}', '{"quadriceps","calves"}', 'none', 'cardio', 'timed'),
+    ('Cycling', '{"Bike","Stationary Bike"}', '{"cardio"}', '{"quadriceps"}', 'machine', 'cardio', 'timed'),
+    ('Rowing', '{"Rower","Erg","Rowing Machine"}', '{"cardio"}', '{"back","quadriceps"}', 'machine', 'cardio', 'timed'),
+    ('Jump Rope', '{"Skipping","Skipping Rope"}', '{"cardio"}', '{"calves"}', 'other', 'cardio', 'timed')
+ON CONFLICT DO NOTHING;
+
+-- resolve_exercise returns the exercise an entry of the owner's workout refers
+-- to: the given exercise when the owner can see it, otherwise the exercise
+-- whose name or alias matches term, preferring the owner's own exercises
+CREATE OR REPLACE FUNCTION resolve_exercise(owner BIGINT, exercise BIGINT, term TEXT) RETURNS BIGINT AS $$
+    SELECT id FROM exercises
+    WHERE (user_id IS NULL OR user_id = owner)
+      AND CASE
+          WHEN exercise IS NOT NULL THEN id = exercise
+          ELSE LOWER(name) = LOWER(TRIM(term))
+            OR LOWER(TRIM(term)) IN (SELECT LOWER(alias) FROM unnest(aliases) AS alias)
+      END
+    ORDER BY user_id NULLS LAST, id
+    LIMIT 1;
+$$ LANGUAGE SQL STABLE;
+
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_id ON workout_entries(exercise_id);
+
+UPDATE workout_entries e
+SET exercise_id = resolve_exercise(w.user_id, NULL, e.exercise_name)
+FROM workouts w
+WHERE w.id = e.workout_id AND e.exercise_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS exercise_id;
+DROP FUNCTION IF EXISTS resolve_exercise(BIGINT, BIGINT, TEXT);
+DROP TABLE IF EXISTS exercises;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- every row is a record set at the time, the latest row of a kind is the
+-- current record and the earlier ones form its progression. The workouts
+-- logged before this table existed are seeded by the Go migration 0022 in
+-- internal/store/record_migration.go
+CREATE TABLE IF NOT EXISTS personal_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    exercise_id BIGINT REFERENCES exercises(id) ON DELETE CASCADE,
+    exercise_name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('heaviest_weight', 'estimated_1rm', 'most_reps', 'longest_duration')),
+    formula VARCHAR(10) CHECK (formula IN ('epley', 'brzycki')),
+    value DECIMAL(10,2) NOT NULL,
+    weight DECIMAL(10,2),
+    reps INT,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+    achieved_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_id ON personal_records(user_id, exercise_id, type, achieved_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS personal_records;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS workout_templates (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    duration_minutes INT NOT NULL,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_workout_templates_user_id ON workout_templates(user_id, title);
+
+-- an entry targets a range of sets, reps and weight, a missing maximum means
+-- the minimum is the target
+CREATE TABLE IF NOT EXISTS template_entries (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE CASCADE,
+    exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL,
+    exercise_name VARCHAR(255) NOT NULL,
+    sets_min INT NOT NULL,
+    sets_max INT,
+    reps_min INT,
+    reps_max INT,
+    weight_min DECIMAL(10,2),
+    weight_max DECIMAL(10,2),
+    duration_seconds INT,
+    notes TEXT NOT NULL DEFAULT '',
+    order_index INT NOT NULL,
+    CONSTRAINT valid_template_entry CHECK (
+        (reps_min IS NOT NULL OR duration_seconds IS NOT NULL) AND
+        (reps_min IS NULL OR duration_seconds IS NULL)
+    )
+);
+CREATE INDEX IF NOT EXISTS idx_template_entries_template_id ON template_entries(template_id, order_index);
+
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS template_id;
+DROP TABLE IF EXISTS template_entries;
+DROP TABLE IF EXISTS workout_templates;
+-- +goose StatementEnd
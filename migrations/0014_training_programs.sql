@@ -0,0 +1,94 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS programs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_programs_user_id ON programs(user_id, title);
+
+CREATE TABLE IF NOT EXISTS program_weeks (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    week_number INT NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    CONSTRAINT program_weeks_week_number_key UNIQUE (program_id, week_number)
+);
+
+-- the progression amount is the weight added every week for linear
+-- progression and the percentage of the training max for percent progression,
+-- templates used by a program cannot be deleted (NO ACTION rather than
+-- RESTRICT so deleting a user removes their templates and programs together)
+CREATE TABLE IF NOT EXISTS program_days (
+    id BIGSERIAL PRIMARY KEY,
+    week_id BIGINT NOT NULL REFERENCES program_weeks(id) ON DELETE CASCADE,
+    day_number INT NOT NULL CHECK (day_number BETWEEN 1 AND 7),
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id),
+    progression_type VARCHAR(20) NOT NULL DEFAULT 'none' CHECK (progression_type IN ('none', 'linear', 'percent')),
+    progression_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    CONSTRAINT program_days_day_number_key UNIQUE (week_id, day_number)
+);
+
+-- enrollments keep their sessions when the program is deleted
+CREATE TABLE IF NOT EXISTS program_enrollments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    program_id BIGINT REFERENCES programs(id) ON DELETE SET NULL,
+    start_date DATE NOT NULL,
+    training_maxes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- planned workouts are a snapshot of the program taken on enrollment, later
+-- changes to the program or its templates do not move them
+CREATE TABLE IF NOT EXISTS planned_workouts (
+    id BIGSERIAL PRIMARY KEY,
+    enrollment_id BIGINT NOT NULL REFERENCES program_enrollments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    program_day_id BIGINT REFERENCES program_days(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    duration_minutes INT NOT NULL,
+    week_number INT NOT NULL,
+    day_number INT NOT NULL,
+    scheduled_on DATE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_planned_workouts_user_id ON planned_workouts(user_id, scheduled_on);
+
+CREATE TABLE IF NOT EXISTS planned_entries (
+    id BIGSERIAL PRIMARY KEY,
+    planned_workout_id BIGINT NOT NULL REFERENCES planned_workouts(id) ON DELETE CASCADE,
+    exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL,
+    exercise_name VARCHAR(255) NOT NULL,
+    sets INT NOT NULL,
+    reps INT,
+    weight DECIMAL(10,2),
+    duration_seconds INT,
+    notes TEXT NOT NULL DEFAULT '',
+    order_index INT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_planned_entries_planned_workout_id ON planned_entries(planned_workout_id, order_index);
+
+-- a planned workout is completed by the one workout linked to it
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS planned_workout_id BIGINT REFERENCES planned_workouts(id) ON DELETE SET NULL;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS as_prescribed BOOLEAN;
+CREATE UNIQUE INDEX IF NOT EXISTS workouts_planned_workout_id_key ON workouts(planned_workout_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS as_prescribed;
+ALTER TABLE workouts DROP COLUMN IF EXISTS planned_workout_id;
+DROP TABLE IF EXISTS planned_entries;
+DROP TABLE IF EXISTS planned_workouts;
+DROP TABLE IF EXISTS program_enrollments;
+DROP TABLE IF EXISTS program_days;
+DROP TABLE IF EXISTS program_weeks;
+DROP TABLE IF EXISTS programs;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a block groups entries of a workout that are done together, every member
+-- is done once per round so its sets count rounds times
+CREATE TABLE IF NOT EXISTS workout_blocks (
+    id BIGSERIAL PRIMARY KEY,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    block_type VARCHAR(20) NOT NULL CHECK (block_type IN ('straight', 'superset', 'circuit', 'emom', 'amrap', 'tabata')),
+    rounds INT NOT NULL DEFAULT 1 CHECK (rounds >= 1),
+    work_seconds INT CHECK (work_seconds > 0),
+    rest_seconds INT CHECK (rest_seconds >= 0),
+    notes TEXT NOT NULL DEFAULT '',
+    CONSTRAINT workout_blocks_position_key UNIQUE (workout_id, position)
+);
+
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS block_id BIGINT REFERENCES workout_blocks(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_workout_entries_block_id ON workout_entries(block_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS block_id;
+DROP TABLE IF EXISTS workout_blocks;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the sets, reps, weight and duration_seconds of an entry are a summary of
+-- its sets, kept for older clients and queries
+CREATE TABLE IF NOT EXISTS workout_sets (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+    set_number INT NOT NULL,
+    set_type VARCHAR(20) NOT NULL DEFAULT 'working' CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+    reps INT,
+    weight DECIMAL(10,2),
+    duration_seconds INT,
+    rpe DECIMAL(3,1) CHECK (rpe BETWEEN 1 AND 10),
+    rir INT CHECK (rir >= 0),
+    completed BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT workout_sets_set_number_key UNIQUE (entry_id, set_number),
+    CONSTRAINT valid_workout_set CHECK (
+        (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+        (reps IS NULL OR duration_seconds IS NULL)
+    )
+);
+
+-- every existing entry becomes that many identical working sets
+INSERT INTO workout_sets (entry_id, set_number, reps, weight, duration_seconds)
+SELECT e.id, n, e.reps, e.weight, e.duration_seconds
+FROM workout_entries e
+CROSS JOIN LATERAL generate_series(1, e.sets) AS n;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS workout_sets;
+-- +goose StatementEnd
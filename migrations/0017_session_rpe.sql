@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the effort of the whole session on the CR-10 scale, times the duration it
+-- is the session load used for training load tracking
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS session_rpe DECIMAL(3,1) CHECK (session_rpe BETWEEN 1 AND 10);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS session_rpe;
+-- +goose StatementEnd
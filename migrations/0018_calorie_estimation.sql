@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- body weight in kg, used to estimate the calories of workouts
+ALTER TABLE users ADD COLUMN IF NOT EXISTS body_weight DECIMAL(5,2) CHECK (body_weight > 0);
+
+-- estimated calories are recomputed whenever the workout changes, measured
+-- ones are whatever the client sent
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS calories_estimated BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS calories_estimated;
+ALTER TABLE users DROP COLUMN IF EXISTS body_weight;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a measurement holds whatever was measured at one time: body weight in kg,
+-- body fat in percent and circumferences in cm
+CREATE TABLE IF NOT EXISTS body_measurements (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    measured_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    body_weight DECIMAL(5,2) CHECK (body_weight > 0),
+    body_fat_percent DECIMAL(4,1) CHECK (body_fat_percent > 0 AND body_fat_percent < 100),
+    waist DECIMAL(5,1) CHECK (waist > 0),
+    hips DECIMAL(5,1) CHECK (hips > 0),
+    chest DECIMAL(5,1) CHECK (chest > 0),
+    neck DECIMAL(5,1) CHECK (neck > 0),
+    arm DECIMAL(5,1) CHECK (arm > 0),
+    thigh DECIMAL(5,1) CHECK (thigh > 0),
+    notes TEXT NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (COALESCE(body_weight, body_fat_percent, waist, hips, chest, neck, arm, thigh) IS NOT NULL)
+);
+CREATE INDEX IF NOT EXISTS idx_body_measurements_user_id ON body_measurements(user_id, measured_at);
+
+-- one goal per metric, start_value is the metric when the goal was set
+CREATE TABLE IF NOT EXISTS body_goals (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    metric VARCHAR(32) NOT NULL,
+    target DECIMAL(5,2) NOT NULL CHECK (target > 0),
+    target_date DATE,
+    start_value DECIMAL(5,2),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, metric)
+);
+
+-- the Wilks and DOTS coefficients differ between men and women
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sex VARCHAR(6) CHECK (sex IN ('male', 'female'));
+
+-- the body weight users already set becomes their first weigh-in
+INSERT INTO body_measurements (user_id, measured_at, body_weight)
+SELECT id, updated_at, body_weight FROM users WHERE body_weight IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS sex;
+DROP TABLE IF EXISTS body_goals;
+DROP TABLE IF EXISTS body_measurements;
+-- +goose StatementEnd
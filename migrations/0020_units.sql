@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- weights are stored in kg whatever the units of the user, which is what
+-- every weight logged so far was entered in
+COMMENT ON COLUMN workout_entries.weight IS 'kg';
+COMMENT ON COLUMN workout_sets.weight IS 'kg';
+COMMENT ON COLUMN template_entries.weight_min IS 'kg';
+COMMENT ON COLUMN template_entries.weight_max IS 'kg';
+COMMENT ON COLUMN planned_entries.weight IS 'kg';
+COMMENT ON COLUMN personal_records.weight IS 'kg';
+COMMENT ON COLUMN personal_records.value IS 'kg for weight records, reps or seconds for the others';
+COMMENT ON COLUMN program_enrollments.training_maxes IS 'kg by exercise';
+COMMENT ON COLUMN users.body_weight IS 'kg';
+COMMENT ON COLUMN body_measurements.body_weight IS 'kg';
+
+-- the unit system weights are shown and entered in, metric (kg) or imperial
+-- (lb). Distances (km or mi) are left until a distance is stored.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS units VARCHAR(8) NOT NULL DEFAULT 'metric' CHECK (units IN ('metric', 'imperial'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS units;
+
+COMMENT ON COLUMN body_measurements.body_weight IS NULL;
+COMMENT ON COLUMN users.body_weight IS NULL;
+COMMENT ON COLUMN program_enrollments.training_maxes IS NULL;
+COMMENT ON COLUMN personal_records.value IS NULL;
+COMMENT ON COLUMN personal_records.weight IS NULL;
+COMMENT ON COLUMN planned_entries.weight IS NULL;
+COMMENT ON COLUMN template_entries.weight_max IS NULL;
+COMMENT ON COLUMN template_entries.weight_min IS NULL;
+COMMENT ON COLUMN workout_sets.weight IS NULL;
+COMMENT ON COLUMN workout_entries.weight IS NULL;
+-- +goose StatementEnd
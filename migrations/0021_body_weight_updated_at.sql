@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- when the body weight on the profile was last changed, so it counts as a
+-- weigh-in of that time next to the logged body measurements
+ALTER TABLE users ADD COLUMN IF NOT EXISTS body_weight_updated_at TIMESTAMP WITH TIME ZONE;
+UPDATE users SET body_weight_updated_at = updated_at WHERE body_weight IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS body_weight_updated_at;
+-- +goose StatementEnd